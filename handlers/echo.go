@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"io"
 	"net"
 	"os"
 	"strconv"
@@ -16,6 +17,17 @@ import (
 // Version is injected from main package
 var Version string
 
+// methodsWithoutBodySemantics are the methods for which request content has no defined semantics (RFC 9110).
+// Bodies sent with them are still parsed, but flagged, as intermediaries may strip or reject them.
+var methodsWithoutBodySemantics = map[string]bool{
+	fiber.MethodGet:     true,
+	fiber.MethodHead:    true,
+	fiber.MethodDelete:  true,
+	fiber.MethodOptions: true,
+	fiber.MethodTrace:   true,
+	fiber.MethodConnect: true,
+}
+
 // TemplateData wraps the response with additional template data
 type TemplateData struct {
 	PageTitle string
@@ -35,8 +47,21 @@ func EchoHandler(jwtService *services.JWTService, bodyService *services.BodyServ
 		// Get custom status code if provided
 		statusCode := getCustomStatusCode(c)
 
+		// Bodies failing the schema bound to their route get the configured failure status
+		if body := response.Request.Body; body != nil && body.Schema != nil && !body.Schema.Valid {
+			if failureStatus := bodyService.SchemaFailureStatus(); failureStatus != 0 {
+				statusCode = failureStatus
+			}
+		}
+
 		// Content negotiation
 		acceptHeader := utils.UnsafeString(c.Request().Header.Peek("Accept"))
+
+		// SOAP envelopes get a SOAP-aware response unless HTML was requested
+		if body := response.Request.Body; body != nil && body.SOAP != nil && !strings.Contains(acceptHeader, "text/html") {
+			return respondSOAP(c, body.SOAP)
+		}
+
 		if strings.Contains(acceptHeader, "text/html") {
 			// Use Fiber template engine for HTML
 			pageTitle := os.Getenv("ECHO_PAGE_TITLE")
@@ -92,31 +117,112 @@ func buildEchoResponse(c *fiber.Ctx, jwtService *services.JWTService, bodyServic
 }
 
 func buildRequestInfo(c *fiber.Ctx, bodyService *services.BodyService) models.RequestInfo {
-	requestInfo := models.RequestInfo{
+	requestInfo := buildRequestMetadata(c)
+
+	// Parse body for any method that carries one
+	requestInfo.Body = buildBodyInfo(c, bodyService, requestInfo.Headers)
+
+	// Add compression info
+	requestInfo.Compression = getCompressionInfo(c)
+
+	return requestInfo
+}
+
+// buildRequestMetadata describes the request without reading its body
+func buildRequestMetadata(c *fiber.Ctx) models.RequestInfo {
+	return models.RequestInfo{
 		Method:        c.Method(),
 		Path:          c.Path(),
 		Query:         utils.UnsafeString(c.Request().URI().QueryString()),
+		QueryParams:   buildQueryParams(c),
 		Headers:       buildHeadersMap(c),
+		HeaderList:    buildHeaderList(c),
 		RemoteAddress: getRemoteAddress(c),
 		Compression:   getCompressionInfo(c),
 		Cookies:       parseCookies(c),
 		TLS:           getRequestTLSInfo(c),
+		Connection:    services.GetConnectionInfo(c.Context().Conn()),
 	}
+}
+
+// buildBodyInfo parses the request body, or returns nil when the request has none
+func buildBodyInfo(c *fiber.Ctx, bodyService *services.BodyService, headers map[string]string) *models.BodyInfo {
+	var bodyInfo *models.BodyInfo
+	hasher := services.NewBodyHasher(headers)
+	var bodyBytes []byte
+	var stream *models.StreamInfo
+	if c.Request().IsBodyStream() {
+		// Streamed bodies are hashed and measured as they are read, keeping only a preview in memory
+		var sink io.Writer = hasher
+		if mirror, ok := c.Locals(bodyMirrorKey).(io.Writer); ok {
+			// In tee mode the stream is forwarded to the upstream as it is read
+			sink = io.MultiWriter(hasher, mirror)
+		}
+		bodyBytes, stream = bodyService.ReadBodyStream(c.Request().BodyStream(), sink)
+		// Only the preview is left for later readers; handlers needing the whole body read it first with decodedBody
+		c.Request().SetBodyRaw(bodyBytes)
+	} else {
+		// Use the raw body, as c.Body() would decompress it without a size limit
+		bodyBytes = c.Request().Body()
+		_, _ = hasher.Write(bodyBytes)
+	}
+
+	if len(bodyBytes) > 0 {
+		contentType := string(c.Request().Header.ContentType())
+		messageType := utils.CopyString(c.Get(services.ProtobufMessageTypeHeader))
+		var decoded []byte
+		bodyInfo, decoded = bodyService.ParseEncodedBody(bodyBytes, utils.CopyString(c.Get("Content-Encoding")), contentType, messageType)
+
+		if stream != nil {
+			// The size is that of the whole stream, of which only the preview was parsed
+			bodyInfo.Stream = stream
+			bodyInfo.Size = int(stream.BytesRead)
+			bodyInfo.Truncated = bodyInfo.Truncated || stream.BytesRead > int64(stream.PreviewSize)
+		}
+
+		// Digests cover the body exactly as it arrived, before decompression
+		hasher.Finish(bodyInfo)
+		bodyInfo.Schema = bodyService.ValidateSchema(c.Method(), c.Path(), contentType, decoded)
 
-	// Parse body for POST, PUT, PATCH, DELETE methods
-	method := c.Method()
-	if method == "POST" || method == "PUT" || method == "PATCH" || method == "DELETE" {
-		bodyBytes := c.Body()
-		if len(bodyBytes) > 0 {
-			contentType := string(c.Request().Header.ContentType())
-			requestInfo.Body = bodyService.ParseBody(bodyBytes, contentType)
+		// SOAP 1.1 carries the action in the SOAPAction header
+		if soap := bodyInfo.SOAP; soap != nil && soap.Version == "1.1" {
+			soap.Action = strings.Trim(c.Get("SOAPAction"), `"`)
+		}
+
+		// Flag bodies intermediaries may strip or reject
+		if methodsWithoutBodySemantics[c.Method()] {
+			bodyInfo.MethodWarning = c.Method() + " request content has no defined semantics (RFC 9110) and may be stripped or rejected by intermediaries"
 		}
 	}
 
-	// Add compression info
-	requestInfo.Compression = getCompressionInfo(c)
+	return bodyInfo
+}
 
-	return requestInfo
+// decodedBody returns the request body with its Content-Encoding undone. Unlike c.Body(), which
+// decompresses without a limit, it refuses bodies that cannot be decoded or that decode past the maximum body size.
+// Streamed bodies are read here, and refused past the maximum body size, so handlers needing the whole
+// body must call it before building the echo response, which keeps only a preview of them.
+func decodedBody(c *fiber.Ctx, bodyService *services.BodyService) ([]byte, error) {
+	if c.Request().IsBodyStream() {
+		body, stream := bodyService.ReadBodyStream(c.Request().BodyStream(), io.Discard)
+		if stream.Error != "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Request body could not be read: "+stream.Error)
+		}
+		if stream.BytesRead > int64(len(body)) {
+			return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge, "Request body is too large.")
+		}
+		// Later readers, including the echo response, get the whole body instead of the drained stream
+		c.Request().SetBodyRaw(body)
+	}
+
+	decoded, decompression := bodyService.DecompressBody(c.Request().Body(), utils.CopyString(c.Get(fiber.HeaderContentEncoding)))
+	if decompression != nil && decompression.Error != "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Request body could not be decoded: "+decompression.Error)
+	}
+	if decompression != nil && decompression.LimitExceeded {
+		return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge, "Decoded request body is too large.")
+	}
+	return decoded, nil
 }
 
 func buildHeadersMap(c *fiber.Ctx) map[string]string {
@@ -127,6 +233,48 @@ func buildHeadersMap(c *fiber.Ctx) map[string]string {
 	return headers
 }
 
+// buildHeaderList lists the request headers in the order they were received, keeping their
+// casing and any duplicates that buildHeadersMap collapses
+func buildHeaderList(c *fiber.Ctx) []models.HeaderField {
+	raw := c.Request().Header.RawHeaders()
+	if len(raw) == 0 {
+		// Requests that were not parsed from the wire have no raw headers to list
+		var headers []models.HeaderField
+		for key, value := range c.Request().Header.All() {
+			headers = append(headers, models.HeaderField{Name: string(key), Value: string(value)})
+		}
+		return headers
+	}
+
+	var headers []models.HeaderField
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		headers = append(headers, models.HeaderField{Name: name, Value: strings.Trim(value, " \t")})
+	}
+	return headers
+}
+
+// buildQueryParams parses the query string into the values of each parameter, in the order they were sent
+func buildQueryParams(c *fiber.Ctx) map[string][]string {
+	args := c.Request().URI().QueryArgs()
+	if args.Len() == 0 {
+		return nil
+	}
+
+	params := make(map[string][]string, args.Len())
+	for key, value := range args.All() {
+		params[string(key)] = append(params[string(key)], string(value))
+	}
+	return params
+}
+
 func buildServerInfo() models.ServerInfo {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -463,9 +611,9 @@ func getRequestTLSInfo(c *fiber.Ctx) *models.RequestTLSInfo {
 
 // getServerTLSInfo gets TLS configuration information for the server
 func getServerTLSInfo() *models.TLSInfo {
-	// Check if TLS is enabled via environment variable
+	// Check if TLS is enabled via environment variable, or a TLS listener stored its certificate
 	tlsEnabled := os.Getenv("TLS_ENABLED")
-	if tlsEnabled == "" || tlsEnabled == "false" {
+	if (tlsEnabled == "" || tlsEnabled == "false") && os.Getenv("_TLS_CERT_SUBJECT") == "" {
 		return nil
 	}
 
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -525,3 +530,148 @@ func TestMultipleCookies(t *testing.T) {
 		}
 	}
 }
+
+// TestUnixSocketConnection tests that requests over a Unix socket report the transport and peer credentials
+func TestUnixSocketConnection(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "echo.sock")
+
+	ln, err := services.ListenUnix(socketPath, services.DefaultUnixSocketMode)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/*", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	defer func() {
+		_ = app.Shutdown()
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), "GET", "http://unix/socket-test", http.NoBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request over unix socket: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if echoResponse.Request.Path != "/socket-test" {
+		t.Errorf("Expected path /socket-test, got %s", echoResponse.Request.Path)
+	}
+
+	conn := echoResponse.Request.Connection
+	if conn == nil {
+		t.Fatal("Expected connection info in response")
+	}
+
+	if conn.Network != "unix" {
+		t.Errorf("Expected network unix, got %s", conn.Network)
+	}
+
+	if runtime.GOOS == "linux" {
+		if conn.PeerCredentials == nil {
+			t.Fatal("Expected peer credentials on Linux")
+		}
+		if int(conn.PeerCredentials.PID) != os.Getpid() {
+			t.Errorf("Expected peer pid %d, got %d", os.Getpid(), conn.PeerCredentials.PID)
+		}
+	}
+}
+
+// TestMultipleListeners tests that each listener is reported in the echo response
+func TestMultipleListeners(t *testing.T) {
+	cert, err := services.NewTLSService().GetOrGenerateCertificate(
+		filepath.Join(t.TempDir(), "missing.crt"), filepath.Join(t.TempDir(), "missing.key"))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	public, err := services.OpenListener(services.ListenerConfig{Label: "public", Network: "tcp", Address: "127.0.0.1:0"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to open public listener: %v", err)
+	}
+	admin, err := services.OpenListener(services.ListenerConfig{Label: "admin", Network: "tcp", Address: "127.0.0.1:0", TLS: true},
+		&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatalf("Failed to open admin listener: %v", err)
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/*", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	for _, ln := range []net.Listener{public, admin} {
+		go func(ln net.Listener) {
+			_ = app.Listener(ln)
+		}(ln)
+	}
+	defer func() {
+		_ = app.Shutdown()
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402 -- self-signed test certificate
+		},
+	}
+
+	tests := []struct {
+		url   string
+		label string
+		tls   bool
+	}{
+		{"http://" + public.Addr().String() + "/public", "public", false},
+		{"https://" + admin.Addr().String() + "/admin", "admin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			req, reqErr := http.NewRequestWithContext(context.Background(), "GET", tt.url, http.NoBody)
+			if reqErr != nil {
+				t.Fatalf("Failed to create request: %v", reqErr)
+			}
+
+			resp, doErr := client.Do(req)
+			if doErr != nil {
+				t.Fatalf("Failed to send request: %v", doErr)
+			}
+			defer resp.Body.Close()
+
+			var echoResponse models.EchoResponse
+			if decodeErr := json.NewDecoder(resp.Body).Decode(&echoResponse); decodeErr != nil {
+				t.Fatalf("Failed to decode response: %v", decodeErr)
+			}
+
+			conn := echoResponse.Request.Connection
+			if conn == nil || conn.Listener == nil {
+				t.Fatal("Expected listener info in response")
+			}
+			if conn.Listener.Label != tt.label || conn.Listener.TLS != tt.tls {
+				t.Errorf("Expected listener %s (tls=%t), got %+v", tt.label, tt.tls, conn.Listener)
+			}
+			if echoResponse.Request.TLS == nil || echoResponse.Request.TLS.Enabled != tt.tls {
+				t.Errorf("Expected request TLS enabled=%t, got %+v", tt.tls, echoResponse.Request.TLS)
+			}
+		})
+	}
+}
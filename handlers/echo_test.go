@@ -1,12 +1,20 @@
 package handlers
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5" // #nosec G501 -- Content-MD5 fixture
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -1634,3 +1642,439 @@ func TestGetEnvironmentVariablesWithCustomDisplay(t *testing.T) {
 		t.Errorf("Expected TEST_VAR2=value2, got %s", result["TEST_VAR2"])
 	}
 }
+
+func TestEchoHandler_ProtobufMessageTypeHeader(t *testing.T) {
+	app := fiber.New()
+	app.Post("/test", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	// Field 1, varint 150
+	req := httptest.NewRequest("POST", "/test", strings.NewReader("\x08\x96\x01"))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(services.ProtobufMessageTypeHeader, "acme.v1.Order")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	body := echoResponse.Request.Body
+	if body == nil || body.Protobuf == nil {
+		t.Fatal("Expected protobuf info in response")
+	}
+	if body.Protobuf.MessageType != "acme.v1.Order" || !body.Protobuf.Schemaless || body.Protobuf.Error == "" {
+		t.Errorf("Expected unknown message type to fall back to the wire format, got %+v", body.Protobuf)
+	}
+	fields, ok := body.Content.([]interface{})
+	if !ok || len(fields) != 1 || fields[0].(map[string]interface{})["value"] != float64(150) {
+		t.Errorf("Expected wire format dump, got %v", body.Content)
+	}
+}
+
+func TestEchoHandler_GzipBody(t *testing.T) {
+	app := fiber.New()
+	app.Post("/test", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, _ = writer.Write([]byte(`{"upload":"photo"}`))
+	_ = writer.Close()
+
+	req := httptest.NewRequest("POST", "/test", bytes.NewReader(compressed.Bytes()))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	body := echoResponse.Request.Body
+	if body == nil || body.Decompression == nil {
+		t.Fatal("Expected decompression info in response")
+	}
+	if body.Decompression.Encoding != "gzip" || body.Decompression.CompressedSize != compressed.Len() || body.Decompression.DecompressedSize != 18 {
+		t.Errorf("Unexpected decompression info: %+v", body.Decompression)
+	}
+	if content, ok := body.Content.(map[string]interface{}); !ok || content["upload"] != "photo" {
+		t.Errorf("Expected decompressed JSON to be parsed, got %v", body.Content)
+	}
+}
+
+func TestEchoHandler_BodyIntegrity(t *testing.T) {
+	app := fiber.New()
+	app.Post("/test", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"hello": "world"}`))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Digest", "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	body := echoResponse.Request.Body
+	if body == nil || body.Digests == nil {
+		t.Fatal("Expected body digests in response")
+	}
+	if body.Digests.SHA256 != "5f8f04f6a3a892aaabbddb6cf273894493773960d4a325b105fee46eef4304f1" {
+		t.Errorf("Unexpected SHA-256 digest %s", body.Digests.SHA256)
+	}
+	if len(body.Integrity) != 1 || !body.Integrity[0].Match || body.Integrity[0].Algorithm != "sha-256" {
+		t.Errorf("Expected verified Content-Digest, got %+v", body.Integrity)
+	}
+}
+
+func TestEchoHandler_SchemaValidation(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "order.json")
+	schema := `{"type":"object","required":["id"],"properties":{"id":{"type":"string"}}}`
+	if err := os.WriteFile(schemaPath, []byte(schema), 0o600); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+	t.Setenv("JSON_SCHEMA_ROUTES", "POST /orders="+schemaPath)
+	t.Setenv("JSON_SCHEMA_FAILURE_STATUS", "422")
+
+	schemaService, err := services.NewSchemaService()
+	if err != nil {
+		t.Fatalf("Failed to create schema service: %v", err)
+	}
+	bodyService := services.NewBodyService()
+	bodyService.SetSchemaService(schemaService)
+
+	app := fiber.New()
+	app.Post("/*", EchoHandler(services.NewJWTService(), bodyService))
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantValid  bool
+		validated  bool
+	}{
+		{"valid body", "/orders", `{"id":"A1"}`, 200, true, true},
+		{"invalid body", "/orders", `{"id":1}`, 422, false, true},
+		{"unbound route", "/users", `{"id":1}`, 200, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, testErr := app.Test(req, -1)
+			if testErr != nil {
+				t.Fatalf("Failed to send request: %v", testErr)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			var echoResponse models.EchoResponse
+			if decodeErr := json.NewDecoder(resp.Body).Decode(&echoResponse); decodeErr != nil {
+				t.Fatalf("Failed to decode response: %v", decodeErr)
+			}
+
+			validation := echoResponse.Request.Body.Schema
+			if !tt.validated {
+				if validation != nil {
+					t.Errorf("Expected no schema validation, got %+v", validation)
+				}
+				return
+			}
+			if validation == nil || validation.Valid != tt.wantValid {
+				t.Fatalf("Expected valid=%t, got %+v", tt.wantValid, validation)
+			}
+			if !tt.wantValid && (len(validation.Errors) == 0 || validation.Errors[0].InstanceLocation != "/id") {
+				t.Errorf("Expected an error at /id, got %+v", validation.Errors)
+			}
+		})
+	}
+}
+
+func TestEchoHandler_Latin1FormBody(t *testing.T) {
+	app := fiber.New()
+	app.Post("/test", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	req := httptest.NewRequest("POST", "/test", strings.NewReader("name=Jos%E9+Garc%EDa"))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=ISO-8859-1")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	body := echoResponse.Request.Body
+	if content, ok := body.Content.(map[string]interface{}); !ok || content["name"] != "José García" {
+		t.Errorf("Expected transcoded form field, got %v", body.Content)
+	}
+	if body.Charset == nil || body.Charset.Declared != "iso-8859-1" || !body.Charset.Transcoded {
+		t.Errorf("Unexpected charset info %+v", body.Charset)
+	}
+}
+
+func TestEchoHandler_StreamedBody(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "4096")
+
+	// Bodies beyond the body limit are streamed to the handler instead of being rejected
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true, BodyLimit: 1024})
+	app.Post("/upload", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	body := bytes.Repeat([]byte("streamed upload "), 4096)
+	req := httptest.NewRequest("POST", "/upload", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	bodyInfo := echoResponse.Request.Body
+	if bodyInfo == nil || bodyInfo.Stream == nil {
+		t.Fatal("Expected stream info in response")
+	}
+	if bodyInfo.Stream.BytesRead != int64(len(body)) || bodyInfo.Stream.PreviewSize != 4096 {
+		t.Errorf("Unexpected stream info %+v", bodyInfo.Stream)
+	}
+	if bodyInfo.Size != len(body) || !bodyInfo.Truncated {
+		t.Errorf("Expected full size %d and truncation, got size %d truncated %t", len(body), bodyInfo.Size, bodyInfo.Truncated)
+	}
+	if content, ok := bodyInfo.Content.(string); !ok || len(content) != 4096 {
+		t.Errorf("Expected a 4096 byte preview, got %d bytes", len(content))
+	}
+	sum := sha256.Sum256(body)
+	if bodyInfo.Digests == nil || bodyInfo.Digests.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected digest of the whole body, got %+v", bodyInfo.Digests)
+	}
+}
+
+func TestEchoHandler_BodyOnMethodWithoutSemantics(t *testing.T) {
+	app := fiber.New()
+	app.All("/search", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	tests := []struct {
+		method      string
+		wantWarning bool
+	}{
+		{"GET", true},
+		{"OPTIONS", true},
+		{"DELETE", true},
+		{"POST", false},
+		{"PUT", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/search", strings.NewReader(`{"term":"echo"}`))
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var echoResponse models.EchoResponse
+			if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			bodyInfo := echoResponse.Request.Body
+			if bodyInfo == nil {
+				t.Fatal("Expected body info in response")
+			}
+			if content, ok := bodyInfo.Content.(map[string]interface{}); !ok || content["term"] != "echo" {
+				t.Errorf("Expected parsed JSON body, got %v", bodyInfo.Content)
+			}
+			if got := bodyInfo.MethodWarning != ""; got != tt.wantWarning {
+				t.Errorf("Expected method warning %t, got %q", tt.wantWarning, bodyInfo.MethodWarning)
+			}
+		})
+	}
+}
+
+func TestEchoHandler_HeaderListAndQueryParams(t *testing.T) {
+	app := fiber.New()
+	app.Get("/hops", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	req := httptest.NewRequest("GET", "/hops?tag=a&tag=b&empty=&flag&name=echo%20server", http.NoBody)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Add("X-Forwarded-For", "203.0.113.1")
+	req.Header.Add("X-Forwarded-For", "10.0.0.1")
+	// Header names not in canonical form are sent as they are
+	req.Header["x-lowercase-HOP"] = []string{"kept"}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	var forwardedFor []string
+	lowercaseKept := false
+	for _, header := range echoResponse.Request.HeaderList {
+		switch header.Name {
+		case "X-Forwarded-For":
+			forwardedFor = append(forwardedFor, header.Value)
+		case "x-lowercase-HOP":
+			lowercaseKept = header.Value == "kept"
+		}
+	}
+	if len(forwardedFor) != 2 || forwardedFor[0] != "203.0.113.1" || forwardedFor[1] != "10.0.0.1" {
+		t.Errorf("Expected both X-Forwarded-For hops in order, got %v", forwardedFor)
+	}
+	if !lowercaseKept {
+		t.Errorf("Expected header casing to be kept, got %+v", echoResponse.Request.HeaderList)
+	}
+
+	query := echoResponse.Request.QueryParams
+	if tags := query["tag"]; len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("Expected both tag values in order, got %v", tags)
+	}
+	if len(query["empty"]) != 1 || len(query["flag"]) != 1 || query["name"][0] != "echo server" {
+		t.Errorf("Unexpected query params %v", query)
+	}
+	if echoResponse.Request.Query != "tag=a&tag=b&empty=&flag&name=echo%20server" {
+		t.Errorf("Expected raw query to be kept, got %q", echoResponse.Request.Query)
+	}
+}
+
+// multipartFormBody builds a form with a file part carrying a custom header, followed by a value field
+func multipartFormBody(t *testing.T) ([]byte, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="upload"; filename="notes.txt"`)
+	header.Set("Content-Type", "text/plain")
+	header.Set("X-Custom", "kept")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create part: %v", err)
+	}
+	_, _ = part.Write([]byte("file content"))
+	if err = writer.WriteField("title", "report"); err != nil {
+		t.Fatalf("Failed to write field: %v", err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatalf("Failed to close multipart writer: %v", err)
+	}
+	return body.Bytes(), writer.FormDataContentType()
+}
+
+func TestEchoHandler_MultipartFormAsSent(t *testing.T) {
+	// As configured in main: without it fasthttp rebuilds form bodies, reordering parts and dropping their headers
+	app := fiber.New(fiber.Config{DisablePreParseMultipartForm: true})
+	app.Post("/upload", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	body, contentType := multipartFormBody(t)
+	req := httptest.NewRequest("POST", "/upload", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	bodyInfo := echoResponse.Request.Body
+	if bodyInfo == nil {
+		t.Fatal("Expected body info")
+	}
+	if bodyInfo.Size != len(body) {
+		t.Errorf("Expected size %d as sent, got %d", len(body), bodyInfo.Size)
+	}
+	if len(bodyInfo.Parts) != 2 || bodyInfo.Parts[0].Name != "upload" || bodyInfo.Parts[1].Name != "title" {
+		t.Fatalf("Expected the file part followed by the field, got %+v", bodyInfo.Parts)
+	}
+	if bodyInfo.Parts[0].Headers["X-Custom"] != "kept" {
+		t.Errorf("Expected the part's custom header, got %v", bodyInfo.Parts[0].Headers)
+	}
+}
+
+func TestEchoHandler_MultipartFormIntegrity(t *testing.T) {
+	app := fiber.New(fiber.Config{DisablePreParseMultipartForm: true})
+	app.Post("/upload", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	body, contentType := multipartFormBody(t)
+	sum := md5.Sum(body) // #nosec G401 -- Content-MD5 fixture
+	req := httptest.NewRequest("POST", "/upload", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Digests are computed over the form as it arrived
+	bodyInfo := echoResponse.Request.Body
+	if bodyInfo == nil || len(bodyInfo.Integrity) != 1 {
+		t.Fatalf("Expected one integrity check, got %+v", bodyInfo)
+	}
+	if check := bodyInfo.Integrity[0]; check.Algorithm != "md5" || !check.Match {
+		t.Errorf("Expected the Content-MD5 of the form to match, got %+v", check)
+	}
+}
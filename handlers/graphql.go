@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/services"
+)
+
+// GraphQLHandler serves the GraphQL echo endpoint over GET and POST
+func GraphQLHandler(graphQLService *services.GraphQLService, jwtService *services.JWTService, bodyService *services.BodyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, err := parseGraphQLRequest(c, bodyService)
+		if err != nil {
+			status := fiber.StatusBadRequest
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+			return c.Status(status).JSON(graphQLErrorResponse(err.Error()))
+		}
+
+		if strings.TrimSpace(req.Query) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(graphQLErrorResponse("Must provide query string."))
+		}
+
+		// Mutations are not allowed over GET (GraphQL over HTTP)
+		if c.Method() == fiber.MethodGet && services.OperationType(req) == "mutation" {
+			c.Set(fiber.HeaderAllow, "POST")
+			return c.Status(fiber.StatusMethodNotAllowed).JSON(graphQLErrorResponse("Can only perform a mutation operation from a POST request."))
+		}
+
+		response := buildEchoResponse(c, jwtService, bodyService)
+		result, controls := graphQLService.Execute(c.UserContext(), req, &response)
+
+		statusCode := fiber.StatusOK
+		if controls.Status != 0 {
+			statusCode = controls.Status
+		}
+
+		return c.Status(statusCode).JSON(result)
+	}
+}
+
+// parseGraphQLRequest reads a GraphQL request from query parameters or the request body
+func parseGraphQLRequest(c *fiber.Ctx, bodyService *services.BodyService) (services.GraphQLRequest, error) {
+	req := services.GraphQLRequest{}
+
+	if c.Method() == fiber.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, fiber.NewError(fiber.StatusBadRequest, "Variables are invalid JSON.")
+			}
+		}
+		return req, nil
+	}
+
+	body, err := decodedBody(c, bodyService)
+	if err != nil {
+		return req, err
+	}
+
+	contentType := strings.ToLower(string(c.Request().Header.ContentType()))
+	if strings.HasPrefix(contentType, "application/graphql") {
+		req.Query = string(body)
+		return req, nil
+	}
+
+	if err = json.Unmarshal(body, &req); err != nil {
+		return req, fiber.NewError(fiber.StatusBadRequest, "POST body sent invalid JSON.")
+	}
+
+	return req, nil
+}
+
+// graphQLErrorResponse builds a GraphQL response containing a single request error
+func graphQLErrorResponse(message string) fiber.Map {
+	return fiber.Map{
+		"errors": []fiber.Map{
+			{"message": message},
+		},
+	}
+}
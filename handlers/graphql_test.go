@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/services"
+)
+
+func setupGraphQLTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	graphQLService, err := services.NewGraphQLService()
+	if err != nil {
+		t.Fatalf("Failed to create GraphQL service: %v", err)
+	}
+
+	app := fiber.New()
+	handler := GraphQLHandler(graphQLService, services.NewJWTService(), services.NewBodyService())
+	app.Get("/graphql", handler)
+	app.Post("/graphql", handler)
+	return app
+}
+
+func doGraphQLRequest(t *testing.T, app *fiber.App, req *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+
+	var result map[string]interface{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v (%s)", err, body)
+	}
+
+	return resp.StatusCode, result
+}
+
+func TestGraphQLHandler_PostQuery(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ request { method path headers(name: \"X-Team\") { value } } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Team", "platform")
+
+	status, result := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	request := result["data"].(map[string]interface{})["request"].(map[string]interface{})
+	if request["method"] != "POST" {
+		t.Errorf("Expected method POST, got %v", request["method"])
+	}
+
+	headers := request["headers"].([]interface{})
+	if len(headers) != 1 || headers[0].(map[string]interface{})["value"] != "platform" {
+		t.Errorf("Expected X-Team header value platform, got %v", headers)
+	}
+}
+
+func TestGraphQLHandler_GetQuery(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	query := url.QueryEscape(`{ server { hostname } }`)
+	req := httptest.NewRequest("GET", "/graphql?query="+query, http.NoBody)
+
+	status, result := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	server := result["data"].(map[string]interface{})["server"].(map[string]interface{})
+	if server["hostname"] == "" {
+		t.Error("Expected hostname to be set")
+	}
+}
+
+func TestGraphQLHandler_ApplicationGraphQLBody(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{ request { method } }`))
+	req.Header.Set("Content-Type", "application/graphql")
+
+	status, result := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	if _, ok := result["data"]; !ok {
+		t.Errorf("Expected data in response, got %v", result)
+	}
+}
+
+func TestGraphQLHandler_SetStatusMutation(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"mutation { setStatus(code: 503) { status } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	status, result := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", status)
+	}
+
+	if _, ok := result["errors"]; ok {
+		t.Errorf("Expected no errors, got %v", result["errors"])
+	}
+}
+
+func TestGraphQLHandler_MutationOverGetRejected(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	query := url.QueryEscape(`mutation { setStatus(code: 201) { status } }`)
+	req := httptest.NewRequest("GET", "/graphql?query="+query, http.NoBody)
+
+	status, result := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", status)
+	}
+
+	if _, ok := result["errors"]; !ok {
+		t.Error("Expected errors in response")
+	}
+}
+
+func TestGraphQLHandler_BadRequests(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{"query":`},
+		{"missing query", `{"variables":{}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			status, result := doGraphQLRequest(t, app, req)
+			if status != fiber.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", status)
+			}
+			if _, ok := result["errors"]; !ok {
+				t.Error("Expected errors in response")
+			}
+		})
+	}
+}
+
+func TestGraphQLHandler_InvalidVariables(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	req := httptest.NewRequest("GET", "/graphql?query=%7B+server+%7B+hostname+%7D+%7D&variables=not-json", http.NoBody)
+
+	status, _ := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", status)
+	}
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+	return compressed.Bytes()
+}
+
+func TestGraphQLHandler_DeeplyNestedQuery(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	for _, query := range []string{
+		strings.Repeat("{", 1000000),
+		"{ request { method } }" + strings.Repeat(" ", 300*1024),
+	} {
+		body, err := json.Marshal(map[string]string{"query": query})
+		if err != nil {
+			t.Fatalf("Failed to encode request: %v", err)
+		}
+		req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		status, result := doGraphQLRequest(t, app, req)
+		if status != fiber.StatusOK {
+			t.Errorf("Expected status 200, got %d", status)
+		}
+		errs, ok := result["errors"].([]interface{})
+		if !ok || len(errs) != 1 || result["data"] != nil {
+			t.Errorf("Expected a single request error, got %v", result)
+		}
+	}
+
+	// Mutations over GET are checked with the same guard
+	req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("mutation "+strings.Repeat("{", 1000)), http.NoBody)
+	if status, _ := doGraphQLRequest(t, app, req); status != fiber.StatusOK {
+		t.Errorf("Expected the nesting error to be reported as a GraphQL error, got status %d", status)
+	}
+}
+
+func TestGraphQLHandler_CompressedBody(t *testing.T) {
+	app := setupGraphQLTestApp(t)
+
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(gzipBytes(t, []byte(`{"query":"{ request { method } }"}`))))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	status, result := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusOK || result["errors"] != nil {
+		t.Fatalf("Expected compressed request to succeed, got %d %v", status, result)
+	}
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		t.Setenv("MAX_BODY_SIZE", "1024")
+		graphQLService, err := services.NewGraphQLService()
+		if err != nil {
+			t.Fatalf("Failed to create GraphQL service: %v", err)
+		}
+		bombApp := fiber.New()
+		bombApp.Post("/graphql", GraphQLHandler(graphQLService, services.NewJWTService(), services.NewBodyService()))
+
+		bomb := httptest.NewRequest("POST", "/graphql", bytes.NewReader(gzipBytes(t, bytes.Repeat([]byte(" "), 1<<20))))
+		bomb.Header.Set("Content-Type", "application/json")
+		bomb.Header.Set("Content-Encoding", "gzip")
+
+		if bombStatus, _ := doGraphQLRequest(t, bombApp, bomb); bombStatus != fiber.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", bombStatus)
+		}
+	})
+
+	t.Run("invalid encoding", func(t *testing.T) {
+		invalid := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ request { method } }"}`))
+		invalid.Header.Set("Content-Type", "application/json")
+		invalid.Header.Set("Content-Encoding", "gzip")
+
+		if invalidStatus, _ := doGraphQLRequest(t, app, invalid); invalidStatus != fiber.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", invalidStatus)
+		}
+	})
+}
+
+func TestGraphQLHandler_StreamedBody(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "4096")
+	graphQLService, err := services.NewGraphQLService()
+	if err != nil {
+		t.Fatalf("Failed to create GraphQL service: %v", err)
+	}
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true, BodyLimit: 1024})
+	app.Post("/graphql", GraphQLHandler(graphQLService, services.NewJWTService(), services.NewBodyService()))
+
+	// A streamed body within MAX_BODY_SIZE is read whole, not cut to a preview
+	query := `{"query":"{ request { method } }"` + strings.Repeat(" ", 2048) + "}"
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(query))
+	req.Header.Set("Content-Type", "application/json")
+	status, result := doGraphQLRequest(t, app, req)
+	if status != fiber.StatusOK || result["errors"] != nil {
+		t.Fatalf("Expected streamed request to succeed, got %d %v", status, result)
+	}
+
+	tooLarge := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ request { method } }"`+strings.Repeat(" ", 8192)+"}"))
+	tooLarge.Header.Set("Content-Type", "application/json")
+	if tooLargeStatus, _ := doGraphQLRequest(t, app, tooLarge); tooLargeStatus != fiber.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", tooLargeStatus)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/fasthttp/websocket"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/services"
+	"github.com/valyala/fasthttp"
+)
+
+// jsonRPCUpgrader upgrades JSON-RPC WebSocket connections.
+// Any origin is accepted since the echo server is a debugging target.
+var jsonRPCUpgrader = websocket.FastHTTPUpgrader{
+	CheckOrigin: func(_ *fasthttp.RequestCtx) bool {
+		return true
+	},
+}
+
+// JSONRPCHandler serves JSON-RPC 2.0 over HTTP POST and WebSocket
+func JSONRPCHandler(jsonRPCService *services.JSONRPCService, bodyService *services.BodyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Request metadata for echo results, without the JSON-RPC payload itself
+		requestInfo := buildRequestMetadata(c)
+
+		if websocket.FastHTTPIsWebSocketUpgrade(c.Context()) {
+			// Snapshot the metadata: request buffers are reused once the handler returns
+			snapshot, err := json.Marshal(requestInfo)
+			if err != nil {
+				return err
+			}
+			return jsonRPCUpgrader.Upgrade(c.Context(), func(conn *websocket.Conn) {
+				serveJSONRPCWebSocket(conn, jsonRPCService, json.RawMessage(snapshot), bodyService.MaxBodySize())
+			})
+		}
+
+		if c.Method() != fiber.MethodPost {
+			c.Set(fiber.HeaderAllow, "POST")
+			return c.SendStatus(fiber.StatusMethodNotAllowed)
+		}
+
+		body, err := decodedBody(c, bodyService)
+		if err != nil {
+			return err
+		}
+
+		response := jsonRPCService.Handle(body, requestInfo, "http")
+		if response == nil {
+			// Notifications only: nothing to return
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(response)
+	}
+}
+
+// serveJSONRPCWebSocket answers each text message on the connection as a JSON-RPC payload.
+// A message larger than maxMessageSize closes the connection.
+func serveJSONRPCWebSocket(conn *websocket.Conn, jsonRPCService *services.JSONRPCService, requestInfo interface{}, maxMessageSize int) {
+	defer conn.Close()
+	conn.SetReadLimit(int64(maxMessageSize))
+
+	for {
+		messageType, payload, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("JSON-RPC WebSocket read error: %v", err)
+			}
+			return
+		}
+
+		if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		response := jsonRPCService.Handle(payload, requestInfo, "websocket")
+		if response == nil {
+			continue
+		}
+
+		if err = conn.WriteMessage(websocket.TextMessage, response); err != nil {
+			log.Printf("JSON-RPC WebSocket write error: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/services"
+)
+
+func setupJSONRPCTestApp() *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	handler := JSONRPCHandler(services.NewJSONRPCService(), services.NewBodyService())
+	app.Get("/jsonrpc", handler)
+	app.Post("/jsonrpc", handler)
+	return app
+}
+
+func TestJSONRPCHandler_HTTPEcho(t *testing.T) {
+	app := setupJSONRPCTestApp()
+
+	req := httptest.NewRequest("POST", "/jsonrpc?trace=1", strings.NewReader(`{"jsonrpc":"2.0","method":"echo","params":{"hello":"world"},"id":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client", "lsp")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+
+	var rpcResp map[string]interface{}
+	if err = json.Unmarshal(body, &rpcResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	result := rpcResp["result"].(map[string]interface{})
+	if result["params"].(map[string]interface{})["hello"] != "world" {
+		t.Errorf("Expected params to be echoed, got %v", result["params"])
+	}
+
+	request := result["request"].(map[string]interface{})
+	if request["query"] != "trace=1" {
+		t.Errorf("Expected request query trace=1, got %v", request["query"])
+	}
+	if request["headers"].(map[string]interface{})["X-Client"] != "lsp" {
+		t.Errorf("Expected X-Client header in request metadata, got %v", request["headers"])
+	}
+	if _, hasBody := request["body"]; hasBody {
+		t.Error("Expected request body to be omitted from metadata")
+	}
+}
+
+func TestJSONRPCHandler_NotificationReturnsNoContent(t *testing.T) {
+	app := setupJSONRPCTestApp()
+
+	req := httptest.NewRequest("POST", "/jsonrpc", strings.NewReader(`{"jsonrpc":"2.0","method":"echo"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestJSONRPCHandler_GetWithoutUpgrade(t *testing.T) {
+	app := setupJSONRPCTestApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/jsonrpc", http.NoBody), -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", resp.StatusCode)
+	}
+}
+
+func TestJSONRPCHandler_WebSocket(t *testing.T) {
+	app := setupJSONRPCTestApp()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	defer func() {
+		_ = app.Shutdown()
+	}()
+
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	conn, resp, err := dialer.Dial("ws://"+ln.Addr().String()+"/jsonrpc?ws=1", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+	defer conn.Close()
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+
+	// A notification produces no message, so the next message answers the batch
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"echo"}`)); err != nil {
+		t.Fatalf("Failed to write notification: %v", err)
+	}
+	batch := `[{"jsonrpc":"2.0","method":"echo","params":[1],"id":1},{"jsonrpc":"2.0","method":"error.internalError","id":2}]`
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(batch)); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+
+	var responses []map[string]interface{}
+	if err = json.Unmarshal(message, &responses); err != nil {
+		t.Fatalf("Expected batch response, got %s: %v", message, err)
+	}
+
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(responses))
+	}
+
+	result := responses[0]["result"].(map[string]interface{})
+	if result["transport"] != "websocket" {
+		t.Errorf("Expected transport websocket, got %v", result["transport"])
+	}
+	if result["request"].(map[string]interface{})["query"] != "ws=1" {
+		t.Errorf("Expected upgrade request metadata, got %v", result["request"])
+	}
+
+	errObj := responses[1]["error"].(map[string]interface{})
+	if errObj["code"] != float64(services.JSONRPCInternalError) {
+		t.Errorf("Expected internal error code, got %v", errObj["code"])
+	}
+}
+
+func TestJSONRPCHandler_CompressedBody(t *testing.T) {
+	app := setupJSONRPCTestApp()
+
+	req := httptest.NewRequest("POST", "/jsonrpc", bytes.NewReader(gzipBytes(t, []byte(`{"jsonrpc":"2.0","method":"echo","params":[1],"id":1}`))))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var rpcResp map[string]interface{}
+	if err = json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rpcResp["result"] == nil {
+		t.Errorf("Expected compressed request to be answered, got %v", rpcResp)
+	}
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		t.Setenv("MAX_BODY_SIZE", "1024")
+		bombApp := fiber.New()
+		bombApp.Post("/jsonrpc", JSONRPCHandler(services.NewJSONRPCService(), services.NewBodyService()))
+
+		bomb := httptest.NewRequest("POST", "/jsonrpc", bytes.NewReader(gzipBytes(t, bytes.Repeat([]byte(" "), 1<<20))))
+		bomb.Header.Set("Content-Encoding", "gzip")
+
+		bombResp, bombErr := bombApp.Test(bomb, -1)
+		if bombErr != nil {
+			t.Fatalf("Failed to send request: %v", bombErr)
+		}
+		defer bombResp.Body.Close()
+		if bombResp.StatusCode != fiber.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", bombResp.StatusCode)
+		}
+	})
+}
+
+func TestJSONRPCHandler_WebSocketReadLimit(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "1024")
+	app := setupJSONRPCTestApp()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	defer func() {
+		_ = app.Shutdown()
+	}()
+
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	conn, resp, err := dialer.Dial("ws://"+ln.Addr().String()+"/jsonrpc", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+	defer conn.Close()
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+
+	message := `{"jsonrpc":"2.0","method":"echo","params":["` + strings.Repeat("x", 2048) + `"],"id":1}`
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	// The server closes the connection instead of answering a message over the limit
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("Expected the connection to be closed as the message is too big, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+// PollHandler holds the request open until an event is published to the channel or the
+// timeout elapses, then responds with the echo of the request and the published event
+func PollHandler(longPollService *services.LongPollService, jwtService *services.JWTService, bodyService *services.BodyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channel := utils.CopyString(c.Params("channel"))
+
+		requested := c.Query("timeout")
+		if requested == "" {
+			requested = c.Get("x-poll-timeout")
+		}
+		timeout := longPollService.Timeout(requested)
+
+		// The request context is canceled when the server shuts down, which ends the wait early.
+		// fasthttp cannot report a client disconnect, so a poll abandoned by its client is held until the timeout.
+		start := time.Now()
+		event := longPollService.Wait(c.Context(), channel, timeout)
+
+		response := buildEchoResponse(c, jwtService, bodyService)
+		response.Poll = &models.PollInfo{
+			Channel:   channel,
+			TimeoutMs: timeout.Milliseconds(),
+			WaitedMs:  float64(time.Since(start).Microseconds()) / 1000,
+			Event:     event,
+			TimedOut:  event == nil,
+		}
+
+		setResponseCookies(c)
+
+		return c.Status(getCustomStatusCode(c)).JSON(response)
+	}
+}
+
+// PublishHandler publishes the request body as an event to everyone polling the channel
+func PublishHandler(longPollService *services.LongPollService, bodyService *services.BodyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channel := c.Params("channel")
+		publishedAt := time.Now().UTC().Format(time.RFC3339Nano)
+
+		body, err := decodedBody(c, bodyService)
+		if err != nil {
+			return err
+		}
+
+		// The event outlives this request, so it must not reference request buffers. The body is parsed
+		// from a copy, as parsed values such as binary fields may alias the bytes they were parsed from.
+		event := &models.PollEvent{
+			Payload:     bodyService.ParseBody(bytes.Clone(body), string(c.Request().Header.ContentType())),
+			PublishedAt: publishedAt,
+			Publisher:   utils.CopyString(getRemoteAddress(c)),
+		}
+
+		delivered := longPollService.Publish(channel, event)
+
+		return c.Status(fiber.StatusAccepted).JSON(models.PublishResponse{
+			Channel:     channel,
+			PublishedAt: publishedAt,
+			Delivered:   delivered,
+		})
+	}
+}
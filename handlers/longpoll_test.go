@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+func setupLongPollTestApp(longPollService *services.LongPollService) *fiber.App {
+	app := fiber.New()
+	app.Get("/poll/:channel", PollHandler(longPollService, services.NewJWTService(), services.NewBodyService()))
+	app.Post("/publish/:channel", PublishHandler(longPollService, services.NewBodyService()))
+	return app
+}
+
+func TestPollHandler_ReturnsPublishedEvent(t *testing.T) {
+	longPollService := services.NewLongPollService()
+	app := setupLongPollTestApp(longPollService)
+
+	type pollResult struct {
+		err      error
+		response models.EchoResponse
+	}
+	results := make(chan pollResult, 1)
+
+	go func() {
+		req := httptest.NewRequest("GET", "/poll/deploys?timeout=5", http.NoBody)
+		req.Header.Set("X-Client", "poller")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			results <- pollResult{err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		var echoResponse models.EchoResponse
+		err = json.NewDecoder(resp.Body).Decode(&echoResponse)
+		results <- pollResult{response: echoResponse, err: err}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for longPollService.Waiting("deploys") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for poll request")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	req := httptest.NewRequest("POST", "/publish/deploys", strings.NewReader(`{"version":"1.2.3"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", resp.StatusCode)
+	}
+	var publishResponse models.PublishResponse
+	if err = json.NewDecoder(resp.Body).Decode(&publishResponse); err != nil {
+		t.Fatalf("Failed to decode publish response: %v", err)
+	}
+	if publishResponse.Channel != "deploys" || publishResponse.Delivered != 1 {
+		t.Errorf("Unexpected publish response: %+v", publishResponse)
+	}
+
+	result := <-results
+	if result.err != nil {
+		t.Fatalf("Poll request failed: %v", result.err)
+	}
+
+	poll := result.response.Poll
+	if poll == nil || poll.TimedOut || poll.Event == nil {
+		t.Fatalf("Expected poll to return the published event, got %+v", poll)
+	}
+	if poll.Channel != "deploys" || poll.TimeoutMs != 5000 {
+		t.Errorf("Unexpected poll info: %+v", poll)
+	}
+	payload, ok := poll.Event.Payload.Content.(map[string]interface{})
+	if !ok || payload["version"] != "1.2.3" {
+		t.Errorf("Expected published payload, got %+v", poll.Event.Payload)
+	}
+	if result.response.Request.Headers["X-Client"] != "poller" {
+		t.Error("Expected echo of the poll request")
+	}
+}
+
+func TestPollHandler_TimesOut(t *testing.T) {
+	app := setupLongPollTestApp(services.NewLongPollService())
+
+	req := httptest.NewRequest("GET", "/poll/quiet", http.NoBody)
+	req.Header.Set("x-poll-timeout", "50ms")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	poll := echoResponse.Poll
+	if poll == nil || !poll.TimedOut || poll.Event != nil {
+		t.Fatalf("Expected timed out poll, got %+v", poll)
+	}
+	if poll.TimeoutMs != 50 || poll.WaitedMs < 50 {
+		t.Errorf("Expected to wait the 50ms timeout, got %+v", poll)
+	}
+}
+
+func TestPublishHandler_NoPollers(t *testing.T) {
+	app := setupLongPollTestApp(services.NewLongPollService())
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/publish/empty", strings.NewReader("hello")), -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var publishResponse models.PublishResponse
+	if err = json.NewDecoder(resp.Body).Decode(&publishResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if publishResponse.Delivered != 0 {
+		t.Errorf("Expected no deliveries, got %d", publishResponse.Delivered)
+	}
+}
+
+func TestPollHandler_EndsOnShutdown(t *testing.T) {
+	longPollService := services.NewLongPollService()
+	app := setupLongPollTestApp(longPollService)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() {
+		_ = app.Listener(ln)
+	}()
+
+	type pollResult struct {
+		err      error
+		response models.EchoResponse
+	}
+	results := make(chan pollResult, 1)
+	go func() {
+		resp, getErr := http.Get("http://" + ln.Addr().String() + "/poll/deploys?timeout=60")
+		if getErr != nil {
+			results <- pollResult{err: getErr}
+			return
+		}
+		defer resp.Body.Close()
+
+		var echoResponse models.EchoResponse
+		getErr = json.NewDecoder(resp.Body).Decode(&echoResponse)
+		results <- pollResult{response: echoResponse, err: getErr}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for longPollService.Waiting("deploys") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for poll request")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err = app.ShutdownWithTimeout(5 * time.Second); err != nil {
+		t.Fatalf("Failed to shut down: %v", err)
+	}
+
+	select {
+	case result := <-results:
+		if result.err != nil {
+			t.Fatalf("Poll failed: %v", result.err)
+		}
+		if poll := result.response.Poll; poll == nil || !poll.TimedOut || poll.WaitedMs >= 60000 {
+			t.Errorf("Expected the poll to end without an event, got %+v", poll)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the poll to end when the server shuts down")
+	}
+}
+
+func TestPublishHandler_CompressedBody(t *testing.T) {
+	app := setupLongPollTestApp(services.NewLongPollService())
+
+	req := httptest.NewRequest("POST", "/publish/deploys", bytes.NewReader(gzipBytes(t, []byte("hello"))))
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", resp.StatusCode)
+	}
+
+	invalid := httptest.NewRequest("POST", "/publish/deploys", strings.NewReader("hello"))
+	invalid.Header.Set("Content-Encoding", "gzip")
+	invalidResp, err := app.Test(invalid, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer invalidResp.Body.Close()
+	if invalidResp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", invalidResp.StatusCode)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExtendedMethods are the request methods routed to the echo handler in addition to
+// Fiber's defaults: WebDAV (RFC 4918, RFC 3253, RFC 4791, RFC 5323), cache purging and
+// other commonly proxied verbs
+var ExtendedMethods = []string{
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+	"REPORT", "SEARCH", "MKCALENDAR", "MKACTIVITY", "CHECKOUT", "CHECKIN",
+	"UNCHECKOUT", "MERGE", "VERSION-CONTROL", "ACL", "BIND", "UNBIND", "REBIND",
+	"ORDERPATCH", "PURGE", "BAN", "LINK", "UNLINK", "QUERY",
+}
+
+// traceExcludedHeaders are not reflected by TRACE since they are likely to contain
+// credentials (RFC 9110, 9.3.8)
+var traceExcludedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+}
+
+// RequestMethods returns all request methods the server accepts: Fiber's defaults,
+// ExtendedMethods and any custom verbs listed in the EXTRA_HTTP_METHODS environment variable
+func RequestMethods() []string {
+	methods := make([]string, 0, len(fiber.DefaultMethods)+len(ExtendedMethods))
+	seen := make(map[string]bool)
+
+	add := func(method string) {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" || seen[method] || !isToken(method) {
+			return
+		}
+		seen[method] = true
+		methods = append(methods, method)
+	}
+
+	for _, method := range fiber.DefaultMethods {
+		add(method)
+	}
+	for _, method := range ExtendedMethods {
+		add(method)
+	}
+	if extra := os.Getenv("EXTRA_HTTP_METHODS"); extra != "" {
+		for _, method := range strings.Split(extra, ",") {
+			add(method)
+		}
+	}
+
+	return methods
+}
+
+// TraceHandler reflects the received request message back as message/http (RFC 9110, 9.3.8)
+func TraceHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var msg bytes.Buffer
+
+		// Request line as received
+		msg.Write(c.Request().Header.Method())
+		msg.WriteByte(' ')
+		msg.Write(c.Request().Header.RequestURI())
+		msg.WriteByte(' ')
+		msg.Write(c.Request().Header.Protocol())
+		msg.WriteString("\r\n")
+
+		// Header fields in their original order and casing
+		for _, line := range bytes.Split(c.Request().Header.RawHeaders(), []byte("\r\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			name, _, _ := bytes.Cut(line, []byte(":"))
+			if traceExcludedHeaders[strings.ToLower(strings.TrimSpace(string(name)))] {
+				continue
+			}
+			msg.Write(line)
+			msg.WriteString("\r\n")
+		}
+		msg.WriteString("\r\n")
+
+		c.Set(fiber.HeaderContentType, "message/http")
+		return c.Status(getCustomStatusCode(c)).Send(msg.Bytes())
+	}
+}
+
+// isToken reports whether s is a valid HTTP method token (RFC 9110, 5.6.2)
+func isToken(s string) bool {
+	for _, r := range s {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return s != ""
+}
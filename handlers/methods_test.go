@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+func setupAllMethodsTestApp() *fiber.App {
+	app := fiber.New(fiber.Config{RequestMethods: RequestMethods()})
+	app.Head("/*", EchoHandlerHead())
+	app.Trace("/*", TraceHandler())
+	app.All("/*", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+	return app
+}
+
+func TestRequestMethods(t *testing.T) {
+	t.Setenv("EXTRA_HTTP_METHODS", "frobnicate, PURGE, bad method,")
+
+	methods := RequestMethods()
+	seen := make(map[string]int)
+	for _, m := range methods {
+		seen[m]++
+	}
+
+	for _, expected := range []string{"GET", "TRACE", "CONNECT", "PROPFIND", "REPORT", "PURGE", "FROBNICATE"} {
+		if seen[expected] != 1 {
+			t.Errorf("Expected %s exactly once, got %d", expected, seen[expected])
+		}
+	}
+
+	if seen["BAD METHOD"] != 0 {
+		t.Error("Expected invalid method token to be ignored")
+	}
+}
+
+func TestEchoHandler_ExtendedMethods(t *testing.T) {
+	t.Setenv("EXTRA_HTTP_METHODS", "FROBNICATE")
+	app := setupAllMethodsTestApp()
+
+	for _, method := range []string{"PROPFIND", "REPORT", "PURGE", "MKCOL", "FROBNICATE"} {
+		t.Run(method, func(t *testing.T) {
+			req := httptest.NewRequest(method, "/dav/resource", strings.NewReader(`<?xml version="1.0"?><propfind xmlns="DAV:"><allprop/></propfind>`))
+			req.Header.Set("Content-Type", "application/xml")
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to send %s request: %v", method, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("Expected status 200 for %s, got %d", method, resp.StatusCode)
+			}
+
+			var echoResponse models.EchoResponse
+			if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if echoResponse.Request.Method != method {
+				t.Errorf("Expected method %s, got %s", method, echoResponse.Request.Method)
+			}
+			if echoResponse.Request.Body == nil {
+				t.Errorf("Expected body to be parsed for %s", method)
+			}
+		})
+	}
+}
+
+func TestEchoHandler_UnconfiguredMethodRejected(t *testing.T) {
+	app := setupAllMethodsTestApp()
+
+	resp, err := app.Test(httptest.NewRequest("NOTAVERB", "/", http.NoBody), -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Fiber rejects methods missing from RequestMethods before routing
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status 400 for unconfigured method, got %d", resp.StatusCode)
+	}
+}
+
+func TestTraceHandler(t *testing.T) {
+	app := setupAllMethodsTestApp()
+
+	req := httptest.NewRequest("TRACE", "/trace/me?x=1", http.NoBody)
+	req.Header.Set("X-Custom-Header", "value")
+	req.Header.Set("Via", "1.1 proxy-a")
+	req.Header.Add("Via", "1.1 proxy-b")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "message/http" {
+		t.Errorf("Expected Content-Type message/http, got %s", ct)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	msg := string(body)
+
+	if !strings.HasPrefix(msg, "TRACE /trace/me?x=1 HTTP/1.1\r\n") {
+		t.Errorf("Expected request line to be reflected, got:\n%s", msg)
+	}
+	if !strings.Contains(msg, "X-Custom-Header: value\r\n") {
+		t.Errorf("Expected custom header to be reflected, got:\n%s", msg)
+	}
+	if strings.Count(msg, "Via: ") != 2 {
+		t.Errorf("Expected both Via headers to be reflected, got:\n%s", msg)
+	}
+	if strings.Contains(msg, "secret") {
+		t.Errorf("Expected credentials to be excluded, got:\n%s", msg)
+	}
+	if !strings.HasSuffix(msg, "\r\n\r\n") {
+		t.Error("Expected message to end with an empty line")
+	}
+}
+
+func TestIsToken(t *testing.T) {
+	tests := map[string]bool{
+		"GET":             true,
+		"VERSION-CONTROL": true,
+		"M-SEARCH":        true,
+		"":                false,
+		"BAD METHOD":      false,
+		"BAD/METHOD":      false,
+		"MÉTHODE":         false,
+	}
+
+	for input, expected := range tests {
+		if got := isToken(input); got != expected {
+			t.Errorf("isToken(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+const (
+	proxyModeConnect      = "connect"
+	proxyModeAbsoluteForm = "absolute-form"
+)
+
+// ProxyHandler handles forward proxy requests: CONNECT tunnels and absolute-form requests.
+// Requests to allowed targets are tunneled or forwarded; all others are answered with an
+// echo of the proxy request. Regular origin-form requests are passed on to the next handler.
+func ProxyHandler(proxyService *services.ProxyService, jwtService *services.JWTService, bodyService *services.BodyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mode := proxyMode(c)
+		if mode == "" {
+			return c.Next()
+		}
+
+		proxyInfo := buildProxyInfo(c, mode)
+		proxyInfo.Allowed = proxyService.IsAllowed(proxyInfo.Target)
+
+		if proxyInfo.Allowed {
+			if mode == proxyModeConnect {
+				return connectTunnel(c, proxyService, proxyInfo)
+			}
+
+			if err := proxyService.Forward(c.Request(), c.Response()); err != nil {
+				proxyInfo.Error = err.Error()
+				c.Response().Reset()
+				return respondProxyEcho(c, jwtService, bodyService, proxyInfo, fiber.StatusBadGateway)
+			}
+			return nil
+		}
+
+		// A 2xx answer to CONNECT would establish a tunnel, so refuse with the echo instead
+		statusCode := getCustomStatusCode(c)
+		if mode == proxyModeConnect && c.Get("x-set-response-status-code") == "" {
+			statusCode = fiber.StatusForbidden
+		}
+		return respondProxyEcho(c, jwtService, bodyService, proxyInfo, statusCode)
+	}
+}
+
+// proxyMode returns the proxy request form, or "" for a regular origin-form request
+func proxyMode(c *fiber.Ctx) string {
+	if c.Method() == fiber.MethodConnect {
+		return proxyModeConnect
+	}
+
+	requestURI := c.Request().Header.RequestURI()
+	if len(requestURI) > 0 && requestURI[0] != '/' && bytes.Contains(requestURI, []byte("://")) {
+		return proxyModeAbsoluteForm
+	}
+	return ""
+}
+
+func buildProxyInfo(c *fiber.Ctx, mode string) *models.ProxyInfo {
+	requestURI := string(c.Request().Header.RequestURI())
+
+	proxyInfo := &models.ProxyInfo{
+		Mode:          mode,
+		RequestURI:    requestURI,
+		Target:        requestURI,
+		Authorization: services.ParseProxyAuthorization(c.Get(fiber.HeaderProxyAuthorization)),
+	}
+
+	if mode == proxyModeAbsoluteForm {
+		uri := c.Request().URI()
+		proxyInfo.Scheme = string(uri.Scheme())
+		proxyInfo.Target = services.ProxyTarget(uri)
+	}
+
+	for key, value := range c.Request().Header.All() {
+		name := string(key)
+		if strings.HasPrefix(strings.ToLower(name), "proxy-") {
+			if proxyInfo.ProxyHeaders == nil {
+				proxyInfo.ProxyHeaders = make(map[string]string)
+			}
+			proxyInfo.ProxyHeaders[name] = string(value)
+		}
+	}
+
+	return proxyInfo
+}
+
+// connectTunnel dials the target and hands the client connection over to a tunnel
+func connectTunnel(c *fiber.Ctx, proxyService *services.ProxyService, proxyInfo *models.ProxyInfo) error {
+	upstream, err := proxyService.Dial(proxyInfo.Target)
+	if err != nil {
+		proxyInfo.Error = err.Error()
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"proxy": proxyInfo})
+	}
+
+	// The connection is taken over after the handler returns, so write the status line ourselves
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(conn net.Conn) {
+		defer upstream.Close()
+
+		if _, writeErr := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); writeErr != nil {
+			log.Printf("Failed to establish tunnel to %s: %v", proxyInfo.Target, writeErr)
+			return
+		}
+		proxyService.Tunnel(conn, upstream)
+	})
+
+	return nil
+}
+
+func respondProxyEcho(c *fiber.Ctx, jwtService *services.JWTService, bodyService *services.BodyService, proxyInfo *models.ProxyInfo, statusCode int) error {
+	response := buildEchoResponse(c, jwtService, bodyService)
+	response.Proxy = proxyInfo
+
+	setResponseCookies(c)
+
+	// A refused CONNECT ends the exchange with the client
+	if proxyInfo.Mode == proxyModeConnect {
+		c.Context().SetConnectionClose()
+	}
+
+	return c.Status(statusCode).JSON(response)
+}
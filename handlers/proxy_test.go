@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+// startProxyTestServer runs the echo app with proxy mode on a real listener and returns its address
+func startProxyTestServer(t *testing.T, allowedTargets string) string {
+	t.Helper()
+	t.Setenv("PROXY_MODE_ENABLED", "true")
+	t.Setenv("PROXY_ALLOWED_TARGETS", allowedTargets)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	jwtService := services.NewJWTService()
+	bodyService := services.NewBodyService()
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(ProxyHandler(services.NewProxyService(), jwtService, bodyService))
+	app.All("/*", EchoHandler(jwtService, bodyService))
+
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	t.Cleanup(func() {
+		_ = app.Shutdown()
+	})
+
+	return ln.Addr().String()
+}
+
+// sendRawProxyRequest writes a raw request to the proxy and returns the parsed response
+func sendRawProxyRequest(t *testing.T, proxyAddr, rawRequest string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatalf("Failed to connect to proxy: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	if _, err = conn.Write([]byte(rawRequest)); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	return conn, reader, resp
+}
+
+func decodeProxyEcho(t *testing.T, resp *http.Response) models.EchoResponse {
+	t.Helper()
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if echoResponse.Proxy == nil {
+		t.Fatal("Expected proxy info in response")
+	}
+	return echoResponse
+}
+
+func TestProxyHandler_ConnectTunnel(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer upstream.Close()
+
+	go func() {
+		conn, acceptErr := upstream.Accept()
+		if acceptErr != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		_, _ = conn.Write([]byte("pong: " + line))
+	}()
+
+	target := upstream.Addr().String()
+	proxyAddr := startProxyTestServer(t, target)
+
+	conn, reader, resp := sendRawProxyRequest(t, proxyAddr,
+		fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target))
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected tunnel to be established, got status %d", resp.StatusCode)
+	}
+
+	if _, err = conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("Failed to write through tunnel: %v", err)
+	}
+	reply, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read through tunnel: %v", err)
+	}
+	if string(reply) != "pong: ping\n" {
+		t.Errorf("Expected upstream reply through tunnel, got %q", reply)
+	}
+}
+
+func TestProxyHandler_ConnectNotAllowed(t *testing.T) {
+	proxyAddr := startProxyTestServer(t, "")
+
+	// dXNlcjpzZWNyZXQ= = user:secret
+	_, _, resp := sendRawProxyRequest(t, proxyAddr,
+		"CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\nProxy-Authorization: Basic dXNlcjpzZWNyZXQ=\r\nProxy-Connection: keep-alive\r\n\r\n")
+
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("Expected status 403 for target that is not allowed, got %d", resp.StatusCode)
+	}
+
+	echoResponse := decodeProxyEcho(t, resp)
+	proxy := echoResponse.Proxy
+	if proxy.Mode != "connect" || proxy.Target != "example.com:443" || proxy.Allowed {
+		t.Errorf("Unexpected proxy info: %+v", proxy)
+	}
+	if proxy.Authorization == nil || proxy.Authorization.Username != "user" || proxy.Authorization.Password != "secret" {
+		t.Errorf("Expected decoded Proxy-Authorization, got %+v", proxy.Authorization)
+	}
+	if proxy.ProxyHeaders["Proxy-Connection"] != "keep-alive" {
+		t.Errorf("Expected Proxy-Connection header in proxy headers, got %v", proxy.ProxyHeaders)
+	}
+	if echoResponse.Request.Method != "CONNECT" {
+		t.Errorf("Expected method CONNECT, got %s", echoResponse.Request.Method)
+	}
+}
+
+func TestProxyHandler_AbsoluteFormEcho(t *testing.T) {
+	proxyAddr := startProxyTestServer(t, "")
+
+	_, _, resp := sendRawProxyRequest(t, proxyAddr,
+		"GET http://example.com/some/path?x=1 HTTP/1.1\r\nHost: example.com\r\nProxy-Authorization: Bearer token123\r\n\r\n")
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	echoResponse := decodeProxyEcho(t, resp)
+	proxy := echoResponse.Proxy
+	if proxy.Mode != "absolute-form" || proxy.Scheme != "http" || proxy.Target != "example.com:80" {
+		t.Errorf("Unexpected proxy info: %+v", proxy)
+	}
+	if proxy.RequestURI != "http://example.com/some/path?x=1" {
+		t.Errorf("Expected absolute request URI, got %s", proxy.RequestURI)
+	}
+	if proxy.Authorization == nil || proxy.Authorization.Scheme != "Bearer" || proxy.Authorization.Credentials != "token123" {
+		t.Errorf("Expected bearer Proxy-Authorization, got %+v", proxy.Authorization)
+	}
+	if echoResponse.Request.Path != "/some/path" || echoResponse.Request.Query != "x=1" {
+		t.Errorf("Expected origin path and query, got %s ? %s", echoResponse.Request.Path, echoResponse.Request.Query)
+	}
+}
+
+func TestProxyHandler_AbsoluteFormForward(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", "yes")
+		_, _ = fmt.Fprintf(w, "upstream saw %s %s auth=%q", r.Method, r.URL.Path, r.Header.Get("Proxy-Authorization"))
+	}))
+	defer upstream.Close()
+
+	target := strings.TrimPrefix(upstream.URL, "http://")
+	proxyAddr := startProxyTestServer(t, target)
+
+	_, _, resp := sendRawProxyRequest(t, proxyAddr,
+		fmt.Sprintf("GET %s/forwarded HTTP/1.1\r\nHost: %s\r\nProxy-Authorization: Basic abc\r\n\r\n", upstream.URL, target))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Upstream") != "yes" {
+		t.Error("Expected upstream response headers")
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if string(body) != `upstream saw GET /forwarded auth=""` {
+		t.Errorf("Unexpected upstream body: %s", body)
+	}
+}
+
+func TestProxyHandler_OriginFormPassesThrough(t *testing.T) {
+	t.Setenv("PROXY_MODE_ENABLED", "true")
+
+	app := fiber.New()
+	app.Use(ProxyHandler(services.NewProxyService(), services.NewJWTService(), services.NewBodyService()))
+	app.Get("/*", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/regular", http.NoBody), -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	resp.Body.Close()
+
+	if echoResponse.Proxy != nil {
+		t.Errorf("Expected no proxy info for origin-form request, got %+v", echoResponse.Proxy)
+	}
+}
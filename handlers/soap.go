@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+// respondSOAP answers a SOAP request with an envelope echoing the request,
+// or with a SOAP Fault when requested via the x-set-soap-fault header
+func respondSOAP(c *fiber.Ctx, soap *models.SOAPInfo) error {
+	var fault *services.SOAPFault
+	statusCode := getCustomStatusCode(c)
+
+	if faultCode := c.Get("x-set-soap-fault"); faultCode != "" {
+		fault = &services.SOAPFault{
+			Code:   faultCode,
+			String: c.Get("x-set-soap-fault-string"),
+		}
+		// Faults use the SOAP HTTP binding status unless one was set explicitly
+		if c.Get("x-set-response-status-code") == "" {
+			statusCode = services.SOAPFaultStatus(soap.Version, faultCode)
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, services.SOAPContentType(soap.Version))
+	return c.Status(statusCode).Send(services.BuildSOAPResponse(soap, fault))
+}
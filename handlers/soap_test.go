@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/services"
+)
+
+const testSOAP11Envelope = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Header><Auth xmlns="urn:auth">x</Auth></soap:Header>
+  <soap:Body><GetQuote xmlns="urn:quotes"><Symbol>ACME</Symbol></GetQuote></soap:Body>
+</soap:Envelope>`
+
+func sendSOAPRequest(t *testing.T, contentType string, headers map[string]string, body string) (int, string, string) {
+	t.Helper()
+
+	app := fiber.New()
+	app.Post("/*", EchoHandler(services.NewJWTService(), services.NewBodyService()))
+
+	req := httptest.NewRequest("POST", "/soap", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(respBody)
+}
+
+func TestEchoHandler_SOAP11Echo(t *testing.T) {
+	status, contentType, body := sendSOAPRequest(t, "text/xml; charset=utf-8",
+		map[string]string{"SOAPAction": `"urn:quotes/GetQuote"`}, testSOAP11Envelope)
+
+	if status != fiber.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+	if contentType != "text/xml; charset=utf-8" {
+		t.Errorf("Expected SOAP 1.1 content type, got %s", contentType)
+	}
+
+	for _, expected := range []string{
+		`<GetQuoteResponse xmlns="urn:quotes">`,
+		"<soapAction>urn:quotes/GetQuote</soapAction>",
+		"<operation>GetQuote</operation>",
+		"<name>Auth</name>",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected response to contain %q, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestEchoHandler_SOAPFault(t *testing.T) {
+	status, _, body := sendSOAPRequest(t, "text/xml",
+		map[string]string{"x-set-soap-fault": "Server", "x-set-soap-fault-string": "backend down"}, testSOAP11Envelope)
+
+	if status != fiber.StatusInternalServerError {
+		t.Errorf("Expected status 500 for SOAP fault, got %d", status)
+	}
+	if !strings.Contains(body, "<faultcode>soap:Server</faultcode>") || !strings.Contains(body, "backend down") {
+		t.Errorf("Expected SOAP fault, got:\n%s", body)
+	}
+}
+
+func TestEchoHandler_SOAP12SenderFault(t *testing.T) {
+	envelope := `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><Ping xmlns="urn:ping"/></env:Body></env:Envelope>`
+
+	status, contentType, body := sendSOAPRequest(t, "application/soap+xml",
+		map[string]string{"x-set-soap-fault": "Sender"}, envelope)
+
+	if status != fiber.StatusBadRequest {
+		t.Errorf("Expected status 400 for SOAP 1.2 Sender fault, got %d", status)
+	}
+	if contentType != "application/soap+xml; charset=utf-8" {
+		t.Errorf("Expected SOAP 1.2 content type, got %s", contentType)
+	}
+	if !strings.Contains(body, "soap:Sender") {
+		t.Errorf("Expected Sender fault code, got:\n%s", body)
+	}
+}
+
+func TestEchoHandler_SOAPFaultCustomStatus(t *testing.T) {
+	status, _, _ := sendSOAPRequest(t, "text/xml",
+		map[string]string{"x-set-soap-fault": "Client", "x-set-response-status-code": "200"}, testSOAP11Envelope)
+
+	if status != fiber.StatusOK {
+		t.Errorf("Expected explicit status to override fault status, got %d", status)
+	}
+}
+
+func TestEchoHandler_PlainXMLStaysJSON(t *testing.T) {
+	_, contentType, _ := sendSOAPRequest(t, "application/xml", nil, `<order><id>1</id></order>`)
+
+	if !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("Expected JSON echo for non-SOAP XML, got %s", contentType)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+// bodyMirrorKey is the local holding the writer a streamed body is copied to as the echo response reads it
+const bodyMirrorKey = "bodyMirror"
+
+// TeeHandler forwards every request to the tee upstream and responds with an envelope
+// holding the original request, the request as forwarded and the upstream response
+func TeeHandler(teeService *services.TeeService, jwtService *services.JWTService, bodyService *services.BodyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		headers := make(http.Header)
+		for key, value := range c.Request().Header.All() {
+			headers.Add(string(key), string(value))
+		}
+
+		in := services.TeeRequest{
+			Method:        c.Method(),
+			RequestURI:    string(c.Request().Header.RequestURI()),
+			Headers:       headers,
+			Host:          c.Hostname(),
+			Scheme:        c.Protocol(),
+			RemoteAddress: c.IP(),
+		}
+
+		var response models.EchoResponse
+		var tee *models.TeeInfo
+		var upstreamBody []byte
+		if c.Request().IsBodyStream() {
+			response, tee, upstreamBody = forwardStreamedBody(c, teeService, in, jwtService, bodyService)
+		} else {
+			response = buildEchoResponse(c, jwtService, bodyService)
+			// The body is forwarded as received, still encoded as its Content-Encoding says
+			in.Body = c.Request().Body()
+			tee, upstreamBody = teeService.Forward(c.UserContext(), in)
+		}
+		if tee.Response != nil {
+			// The upstream may compress its response, as the client's Accept-Encoding is forwarded
+			tee.Response.Body, _ = bodyService.ParseEncodedBody(upstreamBody, tee.Response.Headers["Content-Encoding"], tee.Response.Headers["Content-Type"], "")
+		}
+		response.Tee = tee
+
+		setResponseCookies(c)
+
+		statusCode := getCustomStatusCode(c)
+		if tee.Response == nil {
+			statusCode = fiber.StatusBadGateway
+		}
+
+		return c.Status(statusCode).JSON(response)
+	}
+}
+
+// forwardStreamedBody forwards a streamed body to the upstream while the echo response reads it, so the
+// upstream gets the whole body while the echo server keeps only a preview of it in memory
+func forwardStreamedBody(c *fiber.Ctx, teeService *services.TeeService, in services.TeeRequest, jwtService *services.JWTService, bodyService *services.BodyService) (models.EchoResponse, *models.TeeInfo, []byte) {
+	pipeReader, pipeWriter := io.Pipe()
+	in.BodyStream = pipeReader
+	in.StreamLength = c.Request().Header.ContentLength()
+
+	// The context must not be touched from the forwarding goroutine, as the handler keeps using c
+	ctx := c.UserContext()
+	var tee *models.TeeInfo
+	var upstreamBody []byte
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		tee, upstreamBody = teeService.Forward(ctx, in)
+		// The upstream may answer without reading the whole body; the rest is then dropped
+		_ = pipeReader.Close()
+	}()
+
+	c.Locals(bodyMirrorKey, &bodyMirror{pipe: pipeWriter})
+	response := buildEchoResponse(c, jwtService, bodyService)
+
+	// A body cut short by the client is cut short for the upstream too
+	if body := response.Request.Body; body != nil && body.Stream != nil && body.Stream.Error != "" {
+		_ = pipeWriter.CloseWithError(errors.New(body.Stream.Error))
+	} else {
+		_ = pipeWriter.Close()
+	}
+	<-forwarded
+
+	return response, tee, upstreamBody
+}
+
+// bodyMirror copies a streamed body to the upstream. Once the upstream stops reading, the rest is dropped
+// instead of failing the read of the body for the echo response.
+type bodyMirror struct {
+	pipe   *io.PipeWriter
+	closed bool
+}
+
+func (m *bodyMirror) Write(p []byte) (int, error) {
+	if !m.closed {
+		if _, err := m.pipe.Write(p); err != nil {
+			m.closed = true
+		}
+	}
+	return len(p), nil
+}
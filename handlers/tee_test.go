@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ullbergm/echo-server/models"
+	"github.com/ullbergm/echo-server/services"
+)
+
+func sendTeeRequest(t *testing.T, upstreamURL string, req *http.Request) (int, models.EchoResponse) {
+	t.Helper()
+	t.Setenv("TEE_UPSTREAM_URL", upstreamURL)
+
+	app := fiber.New()
+	app.All("/*", TeeHandler(services.NewTeeService(), services.NewJWTService(), services.NewBodyService()))
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if echoResponse.Tee == nil {
+		t.Fatal("Expected tee info in response")
+	}
+
+	return resp.StatusCode, echoResponse
+}
+
+func TestTeeHandler_EchoesBothSides(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte(`{"upstreamSaw":` + string(body) + `}`))
+	}))
+	defer upstream.Close()
+
+	req := httptest.NewRequest("PUT", "/items/9?dry=1", strings.NewReader(`{"name":"widget"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace", "abc")
+
+	status, echoResponse := sendTeeRequest(t, upstream.URL, req)
+
+	// The echo server answers the client itself; the upstream status is in the envelope
+	if status != fiber.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+
+	if echoResponse.Request.Method != "PUT" || echoResponse.Request.Path != "/items/9" {
+		t.Errorf("Expected original request, got %s %s", echoResponse.Request.Method, echoResponse.Request.Path)
+	}
+	if echoResponse.Request.Body == nil {
+		t.Error("Expected original request body")
+	}
+
+	tee := echoResponse.Tee
+	if tee.Forwarded.Method != "PUT" || tee.Forwarded.URL != upstream.URL+"/items/9?dry=1" {
+		t.Errorf("Unexpected forwarded request: %+v", tee.Forwarded)
+	}
+	if tee.Forwarded.Headers["X-Trace"] != "abc" {
+		t.Errorf("Expected forwarded headers, got %v", tee.Forwarded.Headers)
+	}
+
+	if tee.Response.Status != http.StatusTeapot {
+		t.Errorf("Expected upstream status 418, got %d", tee.Response.Status)
+	}
+	if tee.Response.Body == nil {
+		t.Fatal("Expected upstream body")
+	}
+	parsed, ok := tee.Response.Body.Content.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected upstream JSON body to be parsed, got %T", tee.Response.Body.Content)
+	}
+	if saw, isMap := parsed["upstreamSaw"].(map[string]interface{}); !isMap || saw["name"] != "widget" {
+		t.Errorf("Expected upstream to receive the request body, got %v", parsed)
+	}
+}
+
+func TestTeeHandler_UpstreamUnavailable(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstreamURL := upstream.URL
+	upstream.Close()
+
+	status, echoResponse := sendTeeRequest(t, upstreamURL, httptest.NewRequest("GET", "/", http.NoBody))
+
+	if status != fiber.StatusBadGateway {
+		t.Errorf("Expected status 502, got %d", status)
+	}
+	if echoResponse.Tee.Error == "" {
+		t.Error("Expected upstream error in envelope")
+	}
+}
+
+func TestTeeHandler_ForwardsEncodedBody(t *testing.T) {
+	compressed := gzipBytes(t, []byte(`{"name":"widget"}`))
+	var received []byte
+	var receivedEncoding string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		receivedEncoding = r.Header.Get("Content-Encoding")
+	}))
+	defer upstream.Close()
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(compressed))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	_, echoResponse := sendTeeRequest(t, upstream.URL, req)
+
+	// The upstream gets the body as the client sent it, matching the Content-Encoding forwarded with it
+	if !bytes.Equal(received, compressed) || receivedEncoding != "gzip" {
+		t.Errorf("Expected the encoded body to be forwarded unchanged, got %q with encoding %q", received, receivedEncoding)
+	}
+	if echoResponse.Tee.Forwarded.BodySize != len(compressed) {
+		t.Errorf("Expected forwarded body size %d, got %d", len(compressed), echoResponse.Tee.Forwarded.BodySize)
+	}
+}
+
+func TestTeeHandler_DecodesEncodedResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipBytes(t, []byte(`{"status":"ok"}`)))
+	}))
+	defer upstream.Close()
+
+	// The client's Accept-Encoding is forwarded, so the upstream answers compressed
+	req := httptest.NewRequest("GET", "/", http.NoBody)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	_, echoResponse := sendTeeRequest(t, upstream.URL, req)
+
+	body := echoResponse.Tee.Response.Body
+	if body == nil {
+		t.Fatal("Expected upstream body")
+	}
+	parsed, ok := body.Content.(map[string]interface{})
+	if !ok || parsed["status"] != "ok" {
+		t.Errorf("Expected the decoded upstream body to be parsed, got %v", body.Content)
+	}
+	if body.Decompression == nil || body.Decompression.Encoding != "gzip" {
+		t.Errorf("Expected gzip decompression info, got %+v", body.Decompression)
+	}
+}
+
+func TestTeeHandler_StreamedBody(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "4096")
+
+	var received []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+	t.Setenv("TEE_UPSTREAM_URL", upstream.URL)
+
+	// Bodies beyond the body limit are streamed, and must reach the upstream whole
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true, BodyLimit: 1024})
+	app.All("/*", TeeHandler(services.NewTeeService(), services.NewJWTService(), services.NewBodyService()))
+
+	body := bytes.Repeat([]byte("streamed upload "), 4096)
+	req := httptest.NewRequest("POST", "/upload", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if !bytes.Equal(received, body) {
+		t.Errorf("Expected the upstream to receive the whole %d byte body, got %d bytes", len(body), len(received))
+	}
+	if echoResponse.Tee == nil || echoResponse.Tee.Response == nil || echoResponse.Tee.Response.Status != http.StatusCreated {
+		t.Fatalf("Expected upstream response, got %+v", echoResponse.Tee)
+	}
+	if echoResponse.Tee.Forwarded.BodySize != len(body) {
+		t.Errorf("Expected forwarded body size %d, got %d", len(body), echoResponse.Tee.Forwarded.BodySize)
+	}
+
+	bodyInfo := echoResponse.Request.Body
+	if bodyInfo == nil || bodyInfo.Stream == nil || bodyInfo.Stream.BytesRead != int64(len(body)) || bodyInfo.Stream.PreviewSize != 4096 {
+		t.Errorf("Expected stream info of the whole body with a preview, got %+v", bodyInfo)
+	}
+}
+
+func TestTeeHandler_StreamedBodyUpstreamStopsReading(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "4096")
+
+	// The upstream answers without reading the body
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer upstream.Close()
+	t.Setenv("TEE_UPSTREAM_URL", upstream.URL)
+
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true, BodyLimit: 1024})
+	app.All("/*", TeeHandler(services.NewTeeService(), services.NewJWTService(), services.NewBodyService()))
+
+	body := bytes.Repeat([]byte("x"), 4<<20)
+	resp, err := app.Test(httptest.NewRequest("POST", "/", bytes.NewReader(body)), -1)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResponse models.EchoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&echoResponse); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The echo side still reads the whole body
+	if bodyInfo := echoResponse.Request.Body; bodyInfo == nil || bodyInfo.Stream == nil || bodyInfo.Stream.BytesRead != int64(len(body)) {
+		t.Errorf("Expected the whole body to be read, got %+v", bodyInfo)
+	}
+	if echoResponse.Tee == nil || echoResponse.Tee.Response == nil || echoResponse.Tee.Response.Status != http.StatusForbidden {
+		t.Errorf("Expected upstream response, got %+v", echoResponse.Tee)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/template/html/v3"
 	"github.com/ullbergm/echo-server/handlers"
+	"github.com/ullbergm/echo-server/models"
 	"github.com/ullbergm/echo-server/services"
 )
 
@@ -52,7 +53,7 @@ func main() {
 		switch v := content.(type) {
 		case string:
 			return v
-		case map[string]interface{}, []interface{}:
+		case map[string]interface{}, []interface{}, *models.XMLElement, []models.ProtobufWireField, []models.MultipartPart:
 			// Pretty print JSON
 			jsonBytes, err := json.MarshalIndent(v, "", "  ")
 			if err != nil {
@@ -73,6 +74,14 @@ func main() {
 		}
 	}
 
+	// Get request body streaming setting from environment (default: false)
+	streamRequestBody := false
+	if streamEnv := os.Getenv("STREAM_REQUEST_BODY"); streamEnv != "" {
+		if parsed, err := strconv.ParseBool(streamEnv); err == nil {
+			streamRequestBody = parsed
+		}
+	}
+
 	// Create Fiber app with template engine and performance optimizations
 	app := fiber.New(fiber.Config{
 		AppName:               "Echo Server v" + Version,
@@ -83,6 +92,13 @@ func main() {
 		JSONDecoder: json.Unmarshal,
 		// Enable prefork for multi-core scalability (optional, configurable via FIBER_PREFORK env var)
 		Prefork: prefork,
+		// Stream large request bodies instead of buffering them (optional, configurable via STREAM_REQUEST_BODY env var)
+		StreamRequestBody: streamRequestBody,
+		// Keep multipart bodies as they arrived. A pre-parsed form is rebuilt with its parts reordered and
+		// their headers dropped, which would break the parts listing and the body digests.
+		DisablePreParseMultipartForm: true,
+		// Accept WebDAV, PURGE and custom verbs in addition to the standard methods
+		RequestMethods: handlers.RequestMethods(),
 	})
 
 	// Middleware
@@ -156,8 +172,31 @@ func main() {
 
 	// Initialize services
 	jwtService := services.NewJWTService()
+	jwtVerifier, err := services.NewJWTVerifier()
+	if err != nil {
+		log.Fatalf("Failed to load JWT verification keys: %v", err)
+	}
+	jwtService.SetVerifier(jwtVerifier)
 	bodyService := services.NewBodyService()
+	protobufService, err := services.NewProtobufService()
+	if err != nil {
+		log.Fatalf("Failed to load protobuf descriptor sets: %v", err)
+	}
+	bodyService.SetProtobufService(protobufService)
+	schemaService, err := services.NewSchemaService()
+	if err != nil {
+		log.Fatalf("Failed to load JSON schemas: %v", err)
+	}
+	bodyService.SetSchemaService(schemaService)
 	metricsService := services.NewMetricsService()
+	jsonRPCService := services.NewJSONRPCService()
+	proxyService := services.NewProxyService()
+	teeService := services.NewTeeService()
+	longPollService := services.NewLongPollService()
+	graphQLService, err := services.NewGraphQLService()
+	if err != nil {
+		log.Fatalf("Failed to initialize GraphQL service: %v", err)
+	}
 
 	// Set version in handlers package
 	handlers.Version = Version
@@ -167,6 +206,12 @@ func main() {
 		return metricsService.MetricsMiddleware(c)
 	})
 
+	// Forward proxy mode (CONNECT and absolute-form requests)
+	if proxyService.Enabled() {
+		log.Printf("Forward proxy mode enabled")
+		app.Use(handlers.ProxyHandler(proxyService, jwtService, bodyService))
+	}
+
 	// Monitor dashboard endpoint
 	app.Get("/monitor", monitor.New(monitor.Config{
 		Title:   "Echo Server Monitor",
@@ -179,39 +224,36 @@ func main() {
 	// Request builder UI endpoint
 	app.Get("/builder", handlers.BuilderHandler())
 
+	// GraphQL echo endpoint
+	app.Get("/graphql", handlers.GraphQLHandler(graphQLService, jwtService, bodyService))
+	app.Post("/graphql", handlers.GraphQLHandler(graphQLService, jwtService, bodyService))
+
+	// JSON-RPC 2.0 echo endpoint (HTTP POST and WebSocket)
+	app.Get("/jsonrpc", handlers.JSONRPCHandler(jsonRPCService, bodyService))
+	app.Post("/jsonrpc", handlers.JSONRPCHandler(jsonRPCService, bodyService))
+
+	// Long-polling endpoints (in-memory channels per server process)
+	app.Get("/poll/:channel", handlers.PollHandler(longPollService, jwtService, bodyService))
+	app.Post("/publish/:channel", handlers.PublishHandler(longPollService, bodyService))
+
 	// Echo handlers for all HTTP methods (wildcard path)
-	app.Get("/*", handlers.EchoHandler(jwtService, bodyService))
-	app.Post("/*", handlers.EchoHandler(jwtService, bodyService))
-	app.Put("/*", handlers.EchoHandler(jwtService, bodyService))
-	app.Patch("/*", handlers.EchoHandler(jwtService, bodyService))
-	app.Delete("/*", handlers.EchoHandler(jwtService, bodyService))
-	app.Options("/*", handlers.EchoHandler(jwtService, bodyService))
-	app.Head("/*", handlers.EchoHandlerHead())
-
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if teeService.Enabled() {
+		// Tee mode: forward everything to the upstream and echo both sides of the exchange
+		log.Printf("Tee mode enabled, forwarding requests to %s", teeService.Upstream())
+		app.All("/*", handlers.TeeHandler(teeService, jwtService, bodyService))
+	} else {
+		app.Head("/*", handlers.EchoHandlerHead())
+		app.Trace("/*", handlers.TraceHandler())
+		app.All("/*", handlers.EchoHandler(jwtService, bodyService))
 	}
 
-	// Check if TLS is enabled
-	tlsEnabled := false
-	if tlsEnv := os.Getenv("TLS_ENABLED"); tlsEnv != "" {
-		if parsed, err := strconv.ParseBool(tlsEnv); err == nil {
-			tlsEnabled = parsed
-		}
+	// Listeners from LISTENERS, or from PORT, TLS_* and UNIX_SOCKET_* when it is not set
+	listeners, err := services.ListenersFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid listener configuration: %v", err)
 	}
 
-	if tlsEnabled {
-		// TLS is enabled, start both HTTP and HTTPS servers
-		startDualStackServers(app, port)
-	} else {
-		// TLS is disabled, start only HTTP server
-		log.Printf("Echo Server starting on port %s (HTTP only)", port)
-		if err := app.Listen(":" + port); err != nil {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}
+	startListeners(app, listeners, prefork)
 }
 
 func formatValue(v interface{}) string {
@@ -231,74 +273,88 @@ func formatValue(v interface{}) string {
 	}
 }
 
-// startDualStackServers starts both HTTP and HTTPS servers
-func startDualStackServers(app *fiber.App, httpPort string) {
-	// Get TLS configuration
-	tlsPort := os.Getenv("TLS_PORT")
-	if tlsPort == "" {
-		tlsPort = "8443"
+// startListeners serves the app on every configured listener and blocks until all have stopped
+func startListeners(app *fiber.App, listeners []services.ListenerConfig, prefork bool) {
+	// Prefork needs Fiber to open the listener itself, which is only possible for a single plain TCP port
+	if prefork && len(listeners) == 1 {
+		if listener := listeners[0]; listener.Network == "tcp" && !listener.TLS && listener.ProxyProtocol == services.ProxyProtocolOff {
+			log.Printf("Echo Server starting on %s (HTTP only)", listener.Address)
+			if err := app.Listen(listener.Address); err != nil {
+				log.Fatalf("Failed to start server: %v", err)
+			}
+			return
+		}
+	}
+	if prefork {
+		log.Printf("Warning: FIBER_PREFORK is ignored, as prefork needs a single plain HTTP listener on a TCP port without PROXY protocol (%d listener(s) configured)", len(listeners))
+	}
+
+	var tlsService *services.TLSService
+	var wg sync.WaitGroup
+
+	for _, listener := range listeners {
+		var tlsConfig *tls.Config
+		if listener.TLS {
+			if tlsService == nil {
+				tlsService = services.NewTLSService()
+			}
+			tlsConfig = loadListenerTLSConfig(tlsService, listener)
+		}
+
+		ln, err := services.OpenListener(listener, tlsConfig)
+		if err != nil {
+			log.Fatalf("Failed to start listener %q on %s: %v", listener.Label, listener.Address, err)
+		}
+
+		log.Printf("Echo Server starting listener %q on %s %s (TLS: %t, PROXY protocol: %s)",
+			listener.Label, listener.Network, listener.Address, listener.TLS, proxyProtocolDescription(listener.ProxyProtocol))
+
+		wg.Add(1)
+		go func(label string) {
+			defer wg.Done()
+			if listenErr := app.Listener(ln); listenErr != nil {
+				log.Printf("Listener %q error: %v", label, listenErr)
+			}
+		}(listener.Label)
 	}
 
-	certFile := os.Getenv("TLS_CERT_FILE")
+	wg.Wait()
+}
+
+// loadListenerTLSConfig loads the listener's certificate, generating a self-signed one if the files do not exist
+func loadListenerTLSConfig(tlsService *services.TLSService, listener services.ListenerConfig) *tls.Config {
+	certFile := listener.CertFile
 	if certFile == "" {
 		certFile = "/certs/tls.crt"
 	}
 
-	keyFile := os.Getenv("TLS_KEY_FILE")
+	keyFile := listener.KeyFile
 	if keyFile == "" {
 		keyFile = "/certs/tls.key"
 	}
 
-	// Initialize TLS service
-	tlsService := services.NewTLSService()
-
 	// Load or generate certificate
 	cert, err := tlsService.GetOrGenerateCertificate(certFile, keyFile)
 	if err != nil {
-		log.Fatalf("Failed to get TLS certificate: %v", err)
+		log.Fatalf("Failed to get TLS certificate for listener %q: %v", listener.Label, err)
 	}
 
 	// Store certificate information in environment for handlers to access
-	storeCertificateInfo(&cert)
+	if os.Getenv("_TLS_CERT_SUBJECT") == "" {
+		storeCertificateInfo(&cert)
+	}
 
-	// Create TLS config
-	tlsConfig := &tls.Config{
+	return &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		MinVersion:   tls.VersionTLS12,
 	}
+}
 
-	// Use WaitGroup to manage both servers
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// Start HTTP server in goroutine
-	go func() {
-		defer wg.Done()
-		log.Printf("Echo Server starting HTTP server on port %s", httpPort)
-		if listenErr := app.Listen(":" + httpPort); listenErr != nil {
-			log.Printf("HTTP server error: %v", listenErr)
-		}
-	}()
-
-	// Start HTTPS server in goroutine
-	go func() {
-		defer wg.Done()
-		log.Printf("Echo Server starting HTTPS server on port %s", tlsPort)
-
-		// Use ListenTLSWithCertificate for custom TLS config
-		ln, listenErr := tls.Listen("tcp", ":"+tlsPort, tlsConfig)
-		if listenErr != nil {
-			log.Printf("Failed to create TLS listener: %v", listenErr)
-			return
-		}
-
-		if listenerErr := app.Listener(ln); listenerErr != nil {
-			log.Printf("HTTPS server error: %v", listenerErr)
-		}
-	}()
-
-	log.Printf("Dual-stack servers running - HTTP:%s HTTPS:%s", httpPort, tlsPort)
-	wg.Wait()
+func proxyProtocolDescription(mode string) string {
+	if mode == services.ProxyProtocolOff {
+		return "off"
+	}
+	return mode
 }
 
 // storeCertificateInfo stores certificate information in environment variables
@@ -4,30 +4,251 @@ package models
 type EchoResponse struct {
 	Kubernetes *KubernetesInfo    `json:"kubernetes,omitempty"`
 	JwtTokens  map[string]JwtInfo `json:"jwtTokens,omitempty"`
+	Proxy      *ProxyInfo         `json:"proxy,omitempty"`
+	Tee        *TeeInfo           `json:"tee,omitempty"`
+	Poll       *PollInfo          `json:"poll,omitempty"`
 	Server     ServerInfo         `json:"server"`
 	Request    RequestInfo        `json:"request"`
 }
 
 // RequestInfo contains information about the HTTP request
 type RequestInfo struct {
-	Headers       map[string]string `json:"headers"`
-	Body          *BodyInfo         `json:"body,omitempty"`
-	Compression   *CompressionInfo  `json:"compression,omitempty"`
-	TLS           *RequestTLSInfo   `json:"tls,omitempty"`
-	Method        string            `json:"method"`
-	Path          string            `json:"path"`
-	Query         string            `json:"query,omitempty"`
-	RemoteAddress string            `json:"remoteAddress"`
-	Cookies       []CookieInfo      `json:"cookies,omitempty"`
+	Headers       map[string]string   `json:"headers"`
+	QueryParams   map[string][]string `json:"queryParams,omitempty"`
+	Body          *BodyInfo           `json:"body,omitempty"`
+	Compression   *CompressionInfo    `json:"compression,omitempty"`
+	TLS           *RequestTLSInfo     `json:"tls,omitempty"`
+	Connection    *ConnectionInfo     `json:"connection,omitempty"`
+	Method        string              `json:"method"`
+	Path          string              `json:"path"`
+	Query         string              `json:"query,omitempty"`
+	RemoteAddress string              `json:"remoteAddress"`
+	HeaderList    []HeaderField       `json:"headerList,omitempty"`
+	Cookies       []CookieInfo        `json:"cookies,omitempty"`
+}
+
+// HeaderField is a request header as it was received, keeping its casing, order and duplicates
+type HeaderField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 // BodyInfo contains information about the request body
 type BodyInfo struct {
-	Content     interface{} `json:"content,omitempty"`
-	ContentType string      `json:"contentType,omitempty"`
-	Size        int         `json:"size"`
-	IsBinary    bool        `json:"isBinary,omitempty"`
-	Truncated   bool        `json:"truncated,omitempty"`
+	Content       interface{}        `json:"content,omitempty"`
+	SOAP          *SOAPInfo          `json:"soap,omitempty"`
+	Protobuf      *ProtobufInfo      `json:"protobuf,omitempty"`
+	Decompression *DecompressionInfo `json:"decompression,omitempty"`
+	Digests       *BodyDigests       `json:"digests,omitempty"`
+	Integrity     []IntegrityCheck   `json:"integrity,omitempty"`
+	Schema        *SchemaValidation  `json:"schemaValidation,omitempty"`
+	Charset       *CharsetInfo       `json:"charset,omitempty"`
+	FileType      *FileTypeInfo      `json:"fileType,omitempty"`
+	Stream        *StreamInfo        `json:"stream,omitempty"`
+	JSONSequence  *JSONSequenceInfo  `json:"jsonSequence,omitempty"`
+	GraphQL       *GraphQLInfo       `json:"graphql,omitempty"`
+	ContentType   string             `json:"contentType,omitempty"`
+	MethodWarning string             `json:"methodWarning,omitempty"`
+	Parts         []MultipartPart    `json:"parts,omitempty"`
+	Size          int                `json:"size"`
+	IsBinary      bool               `json:"isBinary,omitempty"`
+	Truncated     bool               `json:"truncated,omitempty"`
+}
+
+// SOAPInfo contains information about a SOAP envelope found in the request body
+type SOAPInfo struct {
+	Version            string            `json:"version"`
+	Action             string            `json:"action,omitempty"`
+	Operation          string            `json:"operation,omitempty"`
+	OperationNamespace string            `json:"operationNamespace,omitempty"`
+	HeaderBlocks       []SOAPHeaderBlock `json:"headerBlocks,omitempty"`
+}
+
+// SOAPHeaderBlock describes a header block of a SOAP envelope
+type SOAPHeaderBlock struct {
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace,omitempty"`
+	Role           string `json:"role,omitempty"`
+	MustUnderstand bool   `json:"mustUnderstand,omitempty"`
+}
+
+// BodyDigests contains hex-encoded digests of the body as it was received
+type BodyDigests struct {
+	SHA256 string `json:"sha256"`
+	SHA1   string `json:"sha1"`
+	MD5    string `json:"md5"`
+	CRC32C string `json:"crc32c"`
+}
+
+// IntegrityCheck is the result of verifying a digest supplied in an integrity header
+type IntegrityCheck struct {
+	Header    string `json:"header"`
+	Algorithm string `json:"algorithm"`
+	Expected  string `json:"expected"`
+	Actual    string `json:"actual,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Match     bool   `json:"match"`
+}
+
+// CharsetInfo describes the character set of a text body
+type CharsetInfo struct {
+	Declared   string `json:"declared,omitempty"`
+	Detected   string `json:"detected,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Transcoded bool   `json:"transcoded"`
+}
+
+// GraphQLInfo describes the operation of a GraphQL request body. Complexity is the number of fields
+// selected, with fragments expanded.
+type GraphQLInfo struct {
+	Variables         map[string]interface{} `json:"variables,omitempty"`
+	PersistedQuery    *GraphQLPersistedQuery `json:"persistedQuery,omitempty"`
+	OperationType     string                 `json:"operationType,omitempty"`
+	OperationName     string                 `json:"operationName,omitempty"`
+	RootFields        []string               `json:"rootFields,omitempty"`
+	DeclaredVariables []string               `json:"declaredVariables,omitempty"`
+	Errors            []GraphQLError         `json:"errors,omitempty"`
+	Depth             int                    `json:"depth"`
+	Complexity        int                    `json:"complexity"`
+}
+
+// GraphQLPersistedQuery describes the automatic persisted query extension of a GraphQL request
+type GraphQLPersistedQuery struct {
+	SHA256Hash string `json:"sha256Hash"`
+	Version    int    `json:"version"`
+	QuerySent  bool   `json:"querySent"`
+	HashMatch  bool   `json:"hashMatch"`
+}
+
+// GraphQLError is a syntax error in a GraphQL document, or an operation that cannot be selected
+type GraphQLError struct {
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// JSONSequenceInfo describes a newline-delimited JSON or JSON text sequence body
+type JSONSequenceInfo struct {
+	Format  string            `json:"format"`
+	Errors  []JSONRecordError `json:"errors,omitempty"`
+	Records int               `json:"records"`
+}
+
+// JSONRecordError is a record of a JSON sequence that failed to parse. Record is the line of an
+// NDJSON record, or the position of a JSON text sequence record, counting from 1.
+type JSONRecordError struct {
+	Message string `json:"message"`
+	Record  int    `json:"record"`
+}
+
+// FileTypeInfo describes the file type of a binary body as sniffed from its magic bytes
+type FileTypeInfo struct {
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	MediaType    string                 `json:"mediaType"`
+	Extension    string                 `json:"extension,omitempty"`
+	Description  string                 `json:"description"`
+	DeclaredType string                 `json:"declaredType,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	Mismatch     bool                   `json:"mismatch"`
+}
+
+// ArchiveEntry describes a file or directory in an archive
+type ArchiveEntry struct {
+	Name           string `json:"name"`
+	Modified       string `json:"modified,omitempty"`
+	Size           int64  `json:"size"`
+	CompressedSize int64  `json:"compressedSize,omitempty"`
+	Dir            bool   `json:"dir,omitempty"`
+}
+
+// SchemaValidation contains the result of validating a JSON body against the JSON Schema bound to its route
+type SchemaValidation struct {
+	Route  string        `json:"route"`
+	Schema string        `json:"schema"`
+	Errors []SchemaError `json:"errors,omitempty"`
+	Valid  bool          `json:"valid"`
+}
+
+// SchemaError describes a JSON Schema validation failure, located by JSON pointers
+// into the body and the schema
+type SchemaError struct {
+	InstanceLocation string `json:"instanceLocation,omitempty"`
+	KeywordLocation  string `json:"keywordLocation,omitempty"`
+	Message          string `json:"message"`
+}
+
+// MultipartPart describes a part of a multipart body
+type MultipartPart struct {
+	Headers           map[string]string `json:"headers,omitempty"`
+	DispositionParams map[string]string `json:"dispositionParams,omitempty"`
+	Content           interface{}       `json:"content,omitempty"`
+	FileType          *FileTypeInfo     `json:"fileType,omitempty"`
+	Name              string            `json:"name,omitempty"`
+	Filename          string            `json:"filename,omitempty"`
+	ContentType       string            `json:"contentType,omitempty"`
+	Disposition       string            `json:"disposition,omitempty"`
+	DetectedType      string            `json:"detectedType"`
+	SHA256            string            `json:"sha256"`
+	Encoding          string            `json:"encoding,omitempty"`
+	Parts             []MultipartPart   `json:"parts,omitempty"`
+	Size              int               `json:"size"`
+	Truncated         bool              `json:"truncated,omitempty"`
+}
+
+// StreamInfo describes a request body that was streamed rather than buffered
+type StreamInfo struct {
+	Error                 string  `json:"error,omitempty"`
+	BytesRead             int64   `json:"bytesRead"`
+	DurationMs            float64 `json:"durationMs"`
+	ThroughputBytesPerSec float64 `json:"throughputBytesPerSec"`
+	PreviewSize           int     `json:"previewSize"`
+}
+
+// DecompressionInfo describes how a body sent with a Content-Encoding was decoded
+type DecompressionInfo struct {
+	Encoding         string `json:"encoding"`
+	Error            string `json:"error,omitempty"`
+	CompressedSize   int    `json:"compressedSize"`
+	DecompressedSize int    `json:"decompressedSize,omitempty"`
+	LimitExceeded    bool   `json:"limitExceeded,omitempty"`
+}
+
+// ProtobufInfo describes how a protobuf request body was decoded
+type ProtobufInfo struct {
+	MessageType string `json:"messageType,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Schemaless  bool   `json:"schemaless"`
+}
+
+// ProtobufWireField is a field of a protobuf message decoded from the wire format without a schema
+type ProtobufWireField struct {
+	Value    interface{}         `json:"value,omitempty"`
+	WireType string              `json:"wireType"`
+	Message  []ProtobufWireField `json:"message,omitempty"`
+	Number   int32               `json:"number"`
+}
+
+// XMLElement is an element of an XML body parsed into a generic tree
+type XMLElement struct {
+	Name       string         `json:"name"`
+	Namespace  string         `json:"namespace,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	Namespaces []XMLNamespace `json:"namespaces,omitempty"`
+	Attributes []XMLAttribute `json:"attributes,omitempty"`
+	Children   []*XMLElement  `json:"children,omitempty"`
+}
+
+// XMLAttribute is an attribute of an XML element
+type XMLAttribute struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Value     string `json:"value"`
+}
+
+// XMLNamespace is a namespace declared on an XML element; the default namespace has no prefix
+type XMLNamespace struct {
+	Prefix string `json:"prefix,omitempty"`
+	URI    string `json:"uri"`
 }
 
 // ServerInfo contains information about the server
@@ -52,9 +273,13 @@ type KubernetesInfo struct {
 
 // JwtInfo contains decoded JWT information
 type JwtInfo struct {
-	Header   map[string]interface{} `json:"header,omitempty"`
-	Payload  map[string]interface{} `json:"payload,omitempty"`
-	RawToken string                 `json:"rawToken"`
+	Header             map[string]interface{} `json:"header,omitempty"`
+	Payload            map[string]interface{} `json:"payload,omitempty"`
+	SignatureValid     *bool                  `json:"signatureValid,omitempty"`
+	RawToken           string                 `json:"rawToken"`
+	SignatureKeyID     string                 `json:"signatureKid,omitempty"`
+	SignatureKeySource string                 `json:"signatureKeySource,omitempty"`
+	SignatureError     string                 `json:"signatureError,omitempty"`
 }
 
 // CookieInfo contains information about an HTTP cookie
@@ -95,3 +320,119 @@ type RequestTLSInfo struct {
 	Cipher  string `json:"cipher,omitempty"`
 	Enabled bool   `json:"enabled"`
 }
+
+// ConnectionInfo contains information about the transport connection of the request
+type ConnectionInfo struct {
+	PeerCredentials *PeerCredentials   `json:"peerCredentials,omitempty"`
+	Listener        *ListenerInfo      `json:"listener,omitempty"`
+	ProxyProtocol   *ProxyProtocolInfo `json:"proxyProtocol,omitempty"`
+	Network         string             `json:"network"`
+	LocalAddress    string             `json:"localAddress,omitempty"`
+}
+
+// ListenerInfo describes the listener that accepted a connection
+type ListenerInfo struct {
+	Label         string `json:"label"`
+	Network       string `json:"network"`
+	Address       string `json:"address"`
+	ProxyProtocol string `json:"proxyProtocol,omitempty"`
+	TLS           bool   `json:"tls"`
+}
+
+// ProxyProtocolInfo contains the PROXY protocol header received on a connection
+type ProxyProtocolInfo struct {
+	Command            string `json:"command"`
+	SourceAddress      string `json:"sourceAddress,omitempty"`
+	DestinationAddress string `json:"destinationAddress,omitempty"`
+	TransportAddress   string `json:"transportAddress"`
+	Version            int    `json:"version"`
+}
+
+// PeerCredentials contains the credentials of the process on the other end of a Unix socket
+type PeerCredentials struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+	PID int32  `json:"pid"`
+}
+
+// ProxyInfo describes a request received in forward proxy mode
+type ProxyInfo struct {
+	Authorization *ProxyAuthInfo    `json:"authorization,omitempty"`
+	ProxyHeaders  map[string]string `json:"proxyHeaders,omitempty"`
+	Mode          string            `json:"mode"`
+	RequestURI    string            `json:"requestUri"`
+	Target        string            `json:"target"`
+	Scheme        string            `json:"scheme,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Allowed       bool              `json:"allowed"`
+}
+
+// ProxyAuthInfo contains the decoded Proxy-Authorization header
+type ProxyAuthInfo struct {
+	Scheme      string `json:"scheme"`
+	Credentials string `json:"credentials,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// TeeInfo describes a request forwarded to the tee upstream and the upstream's response
+type TeeInfo struct {
+	Forwarded *TeeForwardedRequest `json:"forwarded,omitempty"`
+	Response  *TeeUpstreamResponse `json:"response,omitempty"`
+	Timings   *TeeTimings          `json:"timings,omitempty"`
+	Upstream  string               `json:"upstream"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// TeeForwardedRequest is the request as it was sent to the upstream
+type TeeForwardedRequest struct {
+	Headers  map[string]string `json:"headers"`
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Host     string            `json:"host"`
+	BodySize int               `json:"bodySize"`
+}
+
+// TeeUpstreamResponse is the response received from the upstream
+type TeeUpstreamResponse struct {
+	Headers    map[string]string `json:"headers"`
+	Body       *BodyInfo         `json:"body,omitempty"`
+	StatusText string            `json:"statusText,omitempty"`
+	Protocol   string            `json:"protocol"`
+	Status     int               `json:"status"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+// TeeTimings contains the phases of the forwarded request in milliseconds
+type TeeTimings struct {
+	DNSMs             float64 `json:"dnsMs,omitempty"`
+	ConnectMs         float64 `json:"connectMs,omitempty"`
+	TLSHandshakeMs    float64 `json:"tlsHandshakeMs,omitempty"`
+	TimeToFirstByteMs float64 `json:"timeToFirstByteMs"`
+	TotalMs           float64 `json:"totalMs"`
+	ReusedConnection  bool    `json:"reusedConnection"`
+}
+
+// PollInfo describes how a long-poll request ended
+type PollInfo struct {
+	Event     *PollEvent `json:"event,omitempty"`
+	Channel   string     `json:"channel"`
+	TimeoutMs int64      `json:"timeoutMs"`
+	WaitedMs  float64    `json:"waitedMs"`
+	TimedOut  bool       `json:"timedOut"`
+}
+
+// PollEvent is an event published to a long-poll channel
+type PollEvent struct {
+	Payload     *BodyInfo `json:"payload,omitempty"`
+	PublishedAt string    `json:"publishedAt"`
+	Publisher   string    `json:"publisher,omitempty"`
+}
+
+// PublishResponse is returned to the publisher of a long-poll event
+type PublishResponse struct {
+	Channel     string `json:"channel"`
+	PublishedAt string `json:"publishedAt"`
+	Delivered   int    `json:"delivered"`
+}
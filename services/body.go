@@ -3,17 +3,26 @@ package services
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"mime"
-	"mime/multipart"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"unicode/utf8"
 
+	"github.com/BurntSushi/toml"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/ullbergm/echo-server/models"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"golang.org/x/text/encoding"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -26,31 +35,63 @@ const (
 	charNewline        = '\n' // Newline character
 	charCarriageReturn = '\r' // Carriage return character
 	charTab            = '\t' // Tab character
+
+	// maxCSVRows is the largest number of CSV data rows included in the parsed content
+	maxCSVRows = 10000
+
+	// maxNestingDepth is the deepest nesting of arrays and maps accepted in bodies whose
+	// decoders recurse without a limit
+	maxNestingDepth = 100
 )
 
 // BodyService handles request body parsing
 type BodyService struct {
+	protobuf    *ProtobufService
+	schema      *SchemaService
 	maxBodySize int
 }
 
 // NewBodyService creates a new body service
 func NewBodyService() *BodyService {
-	maxSize := DefaultMaxBodySize
+	return &BodyService{
+		maxBodySize: maxBodySizeFromEnv(),
+	}
+}
 
-	// Check for environment variable override
+// maxBodySizeFromEnv returns the maximum body size, overridable with MAX_BODY_SIZE
+func maxBodySizeFromEnv() int {
 	if maxSizeEnv := os.Getenv("MAX_BODY_SIZE"); maxSizeEnv != "" {
 		if parsed, err := strconv.Atoi(maxSizeEnv); err == nil && parsed > 0 {
-			maxSize = parsed
+			return parsed
 		}
 	}
+	return DefaultMaxBodySize
+}
 
-	return &BodyService{
-		maxBodySize: maxSize,
-	}
+// MaxBodySize returns the largest body, in bytes, that is read or decompressed
+func (s *BodyService) MaxBodySize() int {
+	return s.maxBodySize
+}
+
+// SetProtobufService sets the protobuf service used to decode protobuf bodies with message types
+// from descriptor sets. Without one, protobuf bodies are dumped from the wire format.
+func (s *BodyService) SetProtobufService(protobuf *ProtobufService) {
+	s.protobuf = protobuf
+}
+
+// SetSchemaService sets the schema service used to validate JSON bodies against the schemas bound to their routes
+func (s *BodyService) SetSchemaService(schema *SchemaService) {
+	s.schema = schema
 }
 
 // ParseBody parses the request body based on Content-Type
 func (s *BodyService) ParseBody(bodyBytes []byte, contentType string) *models.BodyInfo {
+	return s.ParseBodyAs(bodyBytes, contentType, "")
+}
+
+// ParseBodyAs parses the request body like ParseBody, decoding a protobuf body as the given
+// message type instead of the one named by the Content-Type
+func (s *BodyService) ParseBodyAs(bodyBytes []byte, contentType, messageType string) *models.BodyInfo {
 	if len(bodyBytes) == 0 {
 		return nil
 	}
@@ -72,9 +113,60 @@ func (s *BodyService) ParseBody(bodyBytes []byte, contentType string) *models.Bo
 		mediaType = contentType
 	}
 
+	// Protobuf bodies are decoded with their message type when it is known, and dumped otherwise.
+	// A message type given for an application/octet-stream body marks it as protobuf too.
+	if s.isProtobuf(mediaType) || (messageType != "" && mediaType == "application/octet-stream") {
+		content, info := s.parseProtobuf(bodyBytes, mediaType, params, messageType)
+		bodyInfo.Protobuf = info
+		if content != nil {
+			bodyInfo.Content = content
+			return bodyInfo
+		}
+	}
+
+	// Multipart bodies are split into parts before the binary data check, so binary files are described part by part
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if content, parts, multipartErr := s.parseMultipart(bodyBytes, mediaType, params); multipartErr == nil {
+			bodyInfo.Content = content
+			bodyInfo.Parts = parts
+			return bodyInfo
+		}
+	}
+
+	// Binary serialization formats are decoded before the binary data check would encode them
+	if content, ok := s.parseBinaryFormat(bodyBytes, mediaType); ok {
+		bodyInfo.Content = content
+		return bodyInfo
+	}
+
+	// Text in a declared charset is transcoded to UTF-8 before the binary data check would encode it.
+	// Plain ASCII reads the same in any charset and is left as it is. Form data is transcoded field
+	// by field instead, as its charset applies to the percent-decoded bytes.
+	formData := strings.HasPrefix(mediaType, "application/x-www-form-urlencoded")
+	charsetInfo, textEncoding := describeCharset(bodyBytes, params["charset"])
+	bodyInfo.Charset = charsetInfo
+	if textEncoding != nil && !formData && charsetInfo.Detected != "us-ascii" {
+		if decoded, decodeErr := textEncoding.NewDecoder().Bytes(bodyBytes); decodeErr == nil {
+			bodyBytes = decoded
+			charsetInfo.Transcoded = true
+		} else {
+			charsetInfo.Error = decodeErr.Error()
+		}
+	}
+
+	// JSON sequences are split into records before the binary data check, as record separators are control characters
+	if format, ok := jsonSequenceFormats[mediaType]; ok && utf8.Valid(bodyBytes) {
+		content, info, recordsDropped := s.parseJSONSequence(bodyBytes, format)
+		bodyInfo.Content = content
+		bodyInfo.JSONSequence = info
+		bodyInfo.Truncated = bodyInfo.Truncated || recordsDropped
+		return bodyInfo
+	}
+
 	// Check if binary data
 	if s.isBinaryData(bodyBytes) {
 		bodyInfo.IsBinary = true
+		bodyInfo.FileType = DetectFileType(bodyBytes, mediaType)
 		bodyInfo.Content = base64.StdEncoding.EncodeToString(bodyBytes)
 		return bodyInfo
 	}
@@ -83,12 +175,22 @@ func (s *BodyService) ParseBody(bodyBytes []byte, contentType string) *models.Bo
 	switch {
 	case strings.HasPrefix(mediaType, "application/json"):
 		bodyInfo.Content = s.parseJSON(bodyBytes)
-	case strings.HasPrefix(mediaType, "application/xml") || strings.HasPrefix(mediaType, "text/xml"):
+	case strings.HasPrefix(mediaType, "application/xml") || strings.HasPrefix(mediaType, "text/xml") || strings.HasSuffix(mediaType, "+xml"):
 		bodyInfo.Content = s.parseXML(bodyBytes)
-	case strings.HasPrefix(mediaType, "application/x-www-form-urlencoded"):
-		bodyInfo.Content = s.parseFormURLEncoded(bodyBytes)
-	case strings.HasPrefix(mediaType, "multipart/form-data"):
-		bodyInfo.Content = s.parseMultipartForm(bodyBytes, params)
+		bodyInfo.SOAP = s.parseSOAP(bodyBytes, params)
+	case formData:
+		bodyInfo.Content = s.parseFormURLEncoded(bodyBytes, textEncoding)
+		if textEncoding != nil {
+			charsetInfo.Transcoded = true
+		}
+	case isYAMLMediaType(mediaType):
+		bodyInfo.Content = s.parseYAML(bodyBytes)
+	case mediaType == "application/toml":
+		bodyInfo.Content = s.parseTOML(bodyBytes)
+	case mediaType == "text/csv":
+		content, rowsDropped := s.parseCSV(bodyBytes, params)
+		bodyInfo.Content = content
+		bodyInfo.Truncated = bodyInfo.Truncated || rowsDropped
 	case strings.HasPrefix(mediaType, "text/"):
 		bodyInfo.Content = string(bodyBytes)
 	default:
@@ -101,6 +203,9 @@ func (s *BodyService) ParseBody(bodyBytes []byte, contentType string) *models.Bo
 		}
 	}
 
+	// GraphQL requests are analyzed whether sent as a document or wrapped in JSON
+	bodyInfo.GraphQL = describeGraphQLBody(mediaType, bodyInfo.Content)
+
 	return bodyInfo
 }
 
@@ -138,84 +243,505 @@ func (s *BodyService) parseJSON(data []byte) interface{} {
 	return result
 }
 
-// parseXML parses XML body
-// Note: Go's encoding/xml doesn't support unmarshaling to map[string]interface{}
-// so we return the XML as a string for display purposes
+// parseXML parses XML body into a generic element tree
 func (s *BodyService) parseXML(data []byte) interface{} {
-	// Return XML as string - Go's xml.Unmarshal requires struct definitions
-	// for proper parsing, which we don't have for arbitrary XML
-	return string(data)
+	root, err := ParseXMLTree(data)
+	if err != nil {
+		// If XML parsing fails, return as string
+		return string(data)
+	}
+	return root
 }
 
-// parseFormURLEncoded parses URL-encoded form data
-func (s *BodyService) parseFormURLEncoded(data []byte) interface{} {
-	values, err := url.ParseQuery(string(data))
+// parseSOAP describes a SOAP envelope, taking the SOAP 1.2 action from the Content-Type parameters
+func (s *BodyService) parseSOAP(data []byte, params map[string]string) *models.SOAPInfo {
+	info := ParseSOAPEnvelope(data)
+	if info != nil && info.Version == "1.2" {
+		info.Action = params["action"]
+	}
+	return info
+}
+
+// parseBinaryFormat decodes MessagePack, CBOR and BSON bodies. The second return value is false
+// for other media types and for bodies that fail to decode, which are then treated as binary data.
+func (s *BodyService) parseBinaryFormat(data []byte, mediaType string) (interface{}, bool) {
+	var content interface{}
+	var err error
+
+	switch {
+	case mediaType == "application/msgpack" || mediaType == "application/x-msgpack" || mediaType == "application/vnd.msgpack":
+		content, err = s.parseMessagePack(data)
+	case mediaType == "application/cbor" || strings.HasSuffix(mediaType, "+cbor"):
+		content, err = s.parseCBOR(data)
+	case mediaType == "application/bson":
+		content, err = s.parseBSON(data)
+	default:
+		return nil, false
+	}
+
 	if err != nil {
-		return string(data)
+		return nil, false
 	}
+	return content, true
+}
 
-	// Convert to map[string]interface{} for consistent JSON output
-	result := make(map[string]interface{})
-	for key, vals := range values {
-		if len(vals) == 1 {
-			result[key] = vals[0]
+// parseMessagePack parses a MessagePack body holding a single value
+func (s *BodyService) parseMessagePack(data []byte) (interface{}, error) {
+	reader := bytes.NewReader(data)
+	decoder := msgpack.NewDecoder(reader)
+	// Map keys may be of any type, not just strings
+	decoder.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+
+	// The decoder recurses into nested values without a limit, so deeply nested bodies are rejected first
+	if err := checkMessagePackDepth(data, maxNestingDepth); err != nil {
+		return nil, err
+	}
+
+	value, err := decoder.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() > 0 {
+		return nil, errors.New("msgpack: trailing data after value")
+	}
+	return jsonCompatible(value), nil
+}
+
+// checkMessagePackDepth walks the first MessagePack value of the data without recursing and
+// returns an error when its arrays and maps are nested deeper than maxDepth
+func checkMessagePackDepth(data []byte, maxDepth int) error {
+	// Items left to read in each array or map being walked, innermost last
+	var remaining []uint64
+	for pos := 0; ; {
+		if pos >= len(data) {
+			return errors.New("msgpack: unexpected end of data")
+		}
+		code := data[pos]
+		pos++
+
+		// Containers hold a number of items, maps two per entry; everything else has a known size
+		var items uint64
+		var skip uint64
+		switch {
+		case code <= 0x7f || code >= 0xe0 || code == 0xc0 || code == 0xc2 || code == 0xc3:
+		case code >= 0x80 && code <= 0x8f:
+			items = 2 * uint64(code&0x0f)
+		case code >= 0x90 && code <= 0x9f:
+			items = uint64(code & 0x0f)
+		case code >= 0xa0 && code <= 0xbf:
+			skip = uint64(code & 0x1f)
+		case code == 0xcc || code == 0xd0:
+			skip = 1
+		case code == 0xcd || code == 0xd1:
+			skip = 2
+		case code == 0xca || code == 0xce || code == 0xd2:
+			skip = 4
+		case code == 0xcb || code == 0xcf || code == 0xd3:
+			skip = 8
+		case code >= 0xd4 && code <= 0xd8:
+			// fixext 1, 2, 4, 8 and 16: a type byte and the data
+			skip = 1 + 1<<(code-0xd4)
+		default:
+			// Lengths follow in 1, 2 or 4 bytes for str, bin and ext, and 2 or 4 bytes for array and map
+			var lengthSize int
+			switch code {
+			case 0xc4, 0xc7, 0xd9:
+				lengthSize = 1
+			case 0xc5, 0xc8, 0xda, 0xdc, 0xde:
+				lengthSize = 2
+			case 0xc6, 0xc9, 0xdb, 0xdd, 0xdf:
+				lengthSize = 4
+			default:
+				return fmt.Errorf("msgpack: invalid code 0x%x", code)
+			}
+			if pos+lengthSize > len(data) {
+				return errors.New("msgpack: unexpected end of data")
+			}
+			var length uint64
+			for _, b := range data[pos : pos+lengthSize] {
+				length = length<<8 | uint64(b)
+			}
+			pos += lengthSize
+
+			switch code {
+			case 0xdc, 0xdd:
+				items = length
+			case 0xde, 0xdf:
+				items = 2 * length
+			case 0xc7, 0xc8, 0xc9:
+				skip = 1 + length
+			default:
+				skip = length
+			}
+		}
+
+		if skip > uint64(len(data)-pos) {
+			return errors.New("msgpack: unexpected end of data")
+		}
+		pos += int(skip)
+
+		if items > 0 {
+			if len(remaining) == maxDepth {
+				return fmt.Errorf("msgpack: nesting deeper than %d levels", maxDepth)
+			}
+			remaining = append(remaining, items)
+			continue
+		}
+
+		// A complete value ends the containers it completes
+		for len(remaining) > 0 {
+			remaining[len(remaining)-1]--
+			if remaining[len(remaining)-1] > 0 {
+				break
+			}
+			remaining = remaining[:len(remaining)-1]
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+	}
+}
+
+// parseCBOR parses a CBOR body holding a single data item
+func (s *BodyService) parseCBOR(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := cbor.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return jsonCompatible(value), nil
+}
+
+// parseBSON parses a BSON document, rendering BSON-specific types as relaxed Extended JSON
+func (s *BodyService) parseBSON(data []byte) (interface{}, error) {
+	document := bson.Raw(data)
+	if err := document.Validate(); err != nil {
+		return nil, err
+	}
+
+	extJSON, err := bson.MarshalExtJSON(document, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err = json.Unmarshal(extJSON, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// isProtobuf reports whether the media type is a protobuf type or mapped to a protobuf message type
+func (s *BodyService) isProtobuf(mediaType string) bool {
+	return IsProtobufMediaType(mediaType) || (s.protobuf != nil && s.protobuf.Handles(mediaType))
+}
+
+// parseProtobuf decodes a protobuf body as its message type, falling back to a dump of the wire format.
+// The content is nil when the body is not valid protobuf wire format.
+func (s *BodyService) parseProtobuf(data []byte, mediaType string, params map[string]string, messageType string) (interface{}, *models.ProtobufInfo) {
+	if messageType == "" {
+		if s.protobuf != nil {
+			messageType = s.protobuf.MessageType(mediaType, params)
 		} else {
-			result[key] = vals
+			messageType = protobufMessageTypeParam(params)
 		}
 	}
-	return result
+
+	info := &models.ProtobufInfo{MessageType: messageType}
+	if messageType != "" {
+		if s.protobuf == nil {
+			info.Error = "no protobuf descriptor sets loaded"
+		} else {
+			content, err := s.protobuf.Decode(data, messageType)
+			if err == nil {
+				return content, info
+			}
+			info.Error = err.Error()
+		}
+	}
+
+	fields, err := DecodeProtobufWire(data)
+	if err != nil {
+		if info.Error == "" {
+			info.Error = err.Error()
+		}
+		return nil, info
+	}
+	info.Schemaless = true
+	return fields, info
 }
 
-// parseMultipartForm parses multipart form data
-func (s *BodyService) parseMultipartForm(data []byte, params map[string]string) interface{} {
-	boundary, ok := params["boundary"]
-	if !ok {
-		return string(data)
+// isYAMLMediaType reports whether the media type is one of the registered or common YAML types
+func isYAMLMediaType(mediaType string) bool {
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
 	}
+	return strings.HasSuffix(mediaType, "+yaml")
+}
 
-	reader := multipart.NewReader(bytes.NewReader(data), boundary)
-	result := make(map[string]interface{})
+// parseYAML parses YAML body; a stream of several documents is returned as an array
+func (s *BodyService) parseYAML(data []byte) interface{} {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
 
+	var documents []interface{}
 	for {
-		part, err := reader.NextPart()
-		if err == io.EOF {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
 			return string(data)
 		}
+		documents = append(documents, jsonCompatible(document))
+	}
 
-		partData, err := io.ReadAll(part)
-		if err != nil {
+	switch len(documents) {
+	case 0:
+		return string(data)
+	case 1:
+		return documents[0]
+	default:
+		return documents
+	}
+}
+
+// parseTOML parses TOML body
+func (s *BodyService) parseTOML(data []byte) interface{} {
+	// The decoder recurses into nested arrays and inline tables without a limit, and slows down
+	// quadratically on deeply dotted keys and table headers
+	if tomlNestingExceeds(data, maxNestingDepth) {
+		return string(data)
+	}
+
+	var result map[string]interface{}
+	if _, err := toml.Decode(string(data), &result); err != nil {
+		return string(data)
+	}
+	return jsonCompatible(result)
+}
+
+// tomlNestingExceeds reports whether a TOML document nests tables and arrays deeper than maxDepth.
+// Arrays, inline tables and every segment of a dotted key count as a level, and the keys of a table
+// are nested under its header. Brackets and dots in strings and comments are skipped.
+func tomlNestingExceeds(data []byte, maxDepth int) bool {
+	// Each open bracket saves the dotted key levels of the pair it belongs to
+	type level struct {
+		kind     byte
+		pairDots int
+	}
+	var stack []level
+	// depth counts the levels enclosing the current position
+	depth := 0
+	// tableDepth is added by the current table header, pairDots by the key of the current key/value pair
+	tableDepth, pairDots, headerDots := 0, 0, 0
+	inKey, inHeader, lineStart := true, false, true
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '\n':
+			if len(stack) == 0 {
+				// A key/value pair ends with its line, unless inside a multi-line array
+				depth -= pairDots
+				pairDots = 0
+				inKey = true
+			}
+			lineStart = true
 			continue
+		case ' ', '\t', '\r':
+			continue
+		}
+		atLineStart := lineStart
+		lineStart = false
+
+		switch c {
+		case '[', '{':
+			if c == '[' && len(stack) == 0 && atLineStart {
+				// A table header replaces the levels of the previous table
+				depth -= tableDepth
+				tableDepth = 0
+				inHeader = true
+			}
+			stack = append(stack, level{kind: c, pairDots: pairDots})
+			pairDots = 0
+			depth++
+			inKey = c == '{' || inHeader
+		case ']', '}':
+			if len(stack) == 0 {
+				continue
+			}
+			if inHeader {
+				headerDots += pairDots
+			}
+			depth -= pairDots + 1
+			pairDots = stack[len(stack)-1].pairDots
+			stack = stack[:len(stack)-1]
+			if inHeader && len(stack) == 0 {
+				inHeader = false
+				inKey = false
+				tableDepth = headerDots + 1
+				headerDots = 0
+				depth += tableDepth
+			}
+		case '.':
+			if inKey {
+				depth++
+				pairDots++
+			}
+		case '=':
+			if !inHeader {
+				inKey = false
+			}
+		case ',':
+			if len(stack) > 0 && stack[len(stack)-1].kind == '{' {
+				// The next key/value pair of an inline table starts
+				depth -= pairDots
+				pairDots = 0
+				inKey = true
+			}
+		case '#':
+			// Comments run to the end of the line, which still ends the pair
+			for i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+		case '"', '\'':
+			// Skip basic and literal strings, single-line or multi-line, honoring escapes in basic strings
+			delimiter := data[i : i+1]
+			if bytes.HasPrefix(data[i:], []byte{c, c, c}) {
+				delimiter = data[i : i+3]
+			}
+			i += len(delimiter)
+			for i < len(data) && !bytes.HasPrefix(data[i:], delimiter) {
+				if c == '"' && data[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i += len(delimiter) - 1
+		}
+		if depth > maxDepth {
+			return true
 		}
+	}
+	return false
+}
 
-		fieldName := part.FormName()
-		if fieldName == "" {
-			continue
+// parseCSV parses CSV body into rows keyed by the header record, or into plain arrays
+// when the Content-Type says header=absent. Rows beyond maxCSVRows are dropped, which
+// is reported by the second return value.
+func (s *BodyService) parseCSV(data []byte, params map[string]string) (interface{}, bool) {
+	reader := csv.NewReader(bytes.NewReader(data))
+
+	var header []string
+	if !strings.EqualFold(params["header"], "absent") {
+		record, err := reader.Read()
+		if err != nil {
+			return string(data), false
 		}
+		header = record
+	}
 
-		// Check if it's a file upload
-		if part.FileName() != "" {
-			fileInfo := map[string]interface{}{
-				"filename": part.FileName(),
-				"size":     len(partData),
-			}
+	rows := []interface{}{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return string(data), false
+		}
+		if len(rows) == maxCSVRows {
+			return rows, true
+		}
 
-			// For binary files, use base64
-			if s.isBinaryData(partData) {
-				fileInfo["content"] = base64.StdEncoding.EncodeToString(partData)
-				fileInfo["encoding"] = "base64"
-			} else {
-				fileInfo["content"] = string(partData)
+		if header == nil {
+			fields := make([]interface{}, len(record))
+			for i, field := range record {
+				fields[i] = field
 			}
+			rows = append(rows, fields)
+			continue
+		}
 
-			result[fieldName] = fileInfo
-		} else {
-			// Regular form field
-			result[fieldName] = string(partData)
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			row[name] = record[i]
 		}
+		rows = append(rows, row)
 	}
 
+	return rows, false
+}
+
+// jsonCompatible converts decoded YAML, TOML, MessagePack and CBOR values into values
+// encoding/json can marshal: maps with non-string keys get string keys and non-finite floats become strings
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonCompatible(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+		return v
+	case []map[string]interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = jsonCompatible(item)
+		}
+		return result
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return v
+	case float32:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return strconv.FormatFloat(float64(v), 'g', -1, 32)
+		}
+		return v
+	case cbor.Tag:
+		// Tags without a native Go representation keep their number next to the content
+		return map[string]interface{}{"tag": v.Number, "content": jsonCompatible(v.Content)}
+	default:
+		return v
+	}
+}
+
+// parseFormURLEncoded parses URL-encoded form data
+func (s *BodyService) parseFormURLEncoded(data []byte, textEncoding encoding.Encoding) interface{} {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return string(data)
+	}
+
+	// Convert to map[string]interface{} for consistent JSON output
+	result := make(map[string]interface{})
+	for key, vals := range values {
+		if textEncoding != nil {
+			// Field names and values are transcoded once percent-decoded
+			key = decodeString(key, textEncoding)
+			for i := range vals {
+				vals[i] = decodeString(vals[i], textEncoding)
+			}
+		}
+		if len(vals) == 1 {
+			result[key] = vals[0]
+		} else {
+			result[key] = vals
+		}
+	}
 	return result
 }
@@ -92,8 +92,8 @@ func TestNewBodyService_CustomMaxSize(t *testing.T) {
 
 	service := NewBodyService()
 
-	if service.maxBodySize != 5000 {
-		t.Errorf("Expected max body size 5000, got %d", service.maxBodySize)
+	if service.MaxBodySize() != 5000 {
+		t.Errorf("Expected max body size 5000, got %d", service.MaxBodySize())
 	}
 }
 
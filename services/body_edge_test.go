@@ -4,6 +4,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/ullbergm/echo-server/models"
 )
 
 // TestBodyService_LargeBody tests parsing of large payloads
@@ -822,12 +824,10 @@ func TestBodyService_ValidUTF8UnknownContentType(t *testing.T) {
 	}
 }
 
-// TestBodyService_XMLSuccessfulParse tests XML that could be parsed (though Go's xml package is limited)
+// TestBodyService_XMLSuccessfulParse tests that XML is parsed into an element tree
 func TestBodyService_XMLSuccessfulParse(t *testing.T) {
 	service := NewBodyService()
 
-	// Simple XML - note: Go's xml.Unmarshal to map[string]interface{} typically fails
-	// so we're actually testing the fallback path
 	xmlBody := []byte(`<root><item>value</item></root>`)
 
 	bodyInfo := service.ParseBody(xmlBody, "application/xml")
@@ -836,10 +836,12 @@ func TestBodyService_XMLSuccessfulParse(t *testing.T) {
 		t.Fatal("Expected body info to be non-nil")
 	}
 
-	// XML parsing to map typically fails in Go, so content should be string
-	// This tests the fallback path
-	if _, ok := bodyInfo.Content.(string); !ok {
-		t.Log("XML was parsed as map (unexpected but acceptable)")
+	root, ok := bodyInfo.Content.(*models.XMLElement)
+	if !ok {
+		t.Fatalf("Expected content to be an XML element tree, got %T", bodyInfo.Content)
+	}
+	if root.Name != "root" || len(root.Children) != 1 || root.Children[0].Text != "value" {
+		t.Errorf("Unexpected XML tree: %+v", root)
 	}
 }
 
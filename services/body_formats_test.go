@@ -0,0 +1,314 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestBodyService_ParseYAML(t *testing.T) {
+	service := NewBodyService()
+
+	body := []byte("name: api\nreplicas: 3\nratio: .inf\nports:\n  - 80\n  - 443\nlabels:\n  1: one\n")
+
+	for _, contentType := range []string{"application/yaml", "text/yaml", "application/x-yaml", "application/vnd.config+yaml; charset=utf-8"} {
+		t.Run(contentType, func(t *testing.T) {
+			content, ok := service.ParseBody(body, contentType).Content.(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected content to be a map")
+			}
+			if content["name"] != "api" || content["replicas"] != 3 {
+				t.Errorf("Unexpected scalars: %v", content)
+			}
+			if ports, isList := content["ports"].([]interface{}); !isList || len(ports) != 2 {
+				t.Errorf("Expected ports list, got %v", content["ports"])
+			}
+			if content["ratio"] != "+Inf" {
+				t.Errorf("Expected infinity to be rendered as a string, got %v", content["ratio"])
+			}
+			if labels, isMap := content["labels"].(map[string]interface{}); !isMap || labels["1"] != "one" {
+				t.Errorf("Expected non-string keys to be converted, got %#v", content["labels"])
+			}
+		})
+	}
+}
+
+func TestBodyService_ParseYAMLDocuments(t *testing.T) {
+	bodyInfo := NewBodyService().ParseBody([]byte("a: 1\n---\nb: 2\n"), "application/yaml")
+
+	documents, ok := bodyInfo.Content.([]interface{})
+	if !ok || len(documents) != 2 {
+		t.Fatalf("Expected two documents, got %v", bodyInfo.Content)
+	}
+}
+
+func TestBodyService_ParseYAMLInvalid(t *testing.T) {
+	for _, body := range []string{"a: [1, 2", "# only a comment\n"} {
+		if content := NewBodyService().ParseBody([]byte(body), "application/yaml").Content; content != body {
+			t.Errorf("Expected %q to be returned as a string, got %v", body, content)
+		}
+	}
+}
+
+func TestBodyService_ParseTOML(t *testing.T) {
+	service := NewBodyService()
+
+	body := []byte("title = \"config\"\n\n[server]\nport = 8080\n\n[[users]]\nname = \"a\"\n\n[[users]]\nname = \"b\"\n")
+	content, ok := service.ParseBody(body, "application/toml").Content.(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected content to be a map")
+	}
+
+	if content["title"] != "config" {
+		t.Errorf("Expected title, got %v", content["title"])
+	}
+	if server, isMap := content["server"].(map[string]interface{}); !isMap || server["port"] != int64(8080) {
+		t.Errorf("Expected server table, got %v", content["server"])
+	}
+	if users, isList := content["users"].([]interface{}); !isList || len(users) != 2 {
+		t.Errorf("Expected array of tables, got %#v", content["users"])
+	}
+
+	if invalid := service.ParseBody([]byte("title = "), "application/toml").Content; invalid != "title = " {
+		t.Errorf("Expected invalid TOML to be returned as a string, got %v", invalid)
+	}
+}
+
+func TestBodyService_ParseTOMLNesting(t *testing.T) {
+	service := NewBodyService()
+
+	nested := func(depth int) string {
+		return "a = " + strings.Repeat("[", depth) + strings.Repeat("]", depth) + "\n"
+	}
+
+	if _, ok := service.ParseBody([]byte(nested(maxNestingDepth)), "application/toml").Content.(map[string]interface{}); !ok {
+		t.Errorf("Expected %d nested arrays to be decoded", maxNestingDepth)
+	}
+	if body := nested(maxNestingDepth + 1); service.ParseBody([]byte(body), "application/toml").Content != body {
+		t.Errorf("Expected %d nested arrays to be returned as a string", maxNestingDepth+1)
+	}
+
+	// Deep enough to overflow the stack if it reached the decoder
+	deep := "a = " + strings.Repeat("[", 2*1024*1024)
+	if content := service.ParseBody([]byte(deep), "application/toml").Content; content != deep {
+		t.Error("Expected deeply nested TOML to be returned as a string")
+	}
+
+	// Brackets in strings and comments do not count
+	body := `a = "` + strings.Repeat("[", 200) + `\""` + "\nb = '''" + strings.Repeat("{", 200) + "'''\n# " + strings.Repeat("[", 200) + "\nc = { d = [1] }\n"
+	content, ok := service.ParseBody([]byte(body), "application/toml").Content.(map[string]interface{})
+	if !ok || content["c"] == nil {
+		t.Errorf("Expected brackets in strings and comments to be ignored, got %v", content)
+	}
+}
+
+func TestBodyService_ParseTOMLDottedKeys(t *testing.T) {
+	service := NewBodyService()
+	dotted := strings.TrimPrefix(strings.Repeat(".a", 200), ".")
+
+	// Dotted keys and table headers nest like tables, and are slow to decode when deep
+	for _, body := range []string{
+		"x." + dotted + " = 1\n",
+		"[" + dotted + "]\nb = 1\n",
+		"[[" + dotted + "]]\nb = 1\n",
+		"c = { " + dotted + " = 1 }\n",
+		// A table header and the dotted keys under it add up
+		"[" + strings.Repeat("a.", 60) + "a]\n" + strings.Repeat("b.", 60) + "b = 1\n",
+	} {
+		if content := service.ParseBody([]byte(body), "application/toml").Content; content != body {
+			t.Errorf("Expected %.40q... to be returned as a string", body)
+		}
+	}
+
+	// Dots in values, strings and comments, and in keys of separate lines, do not add up
+	body := "[server.http]\nport.number = 8080\nratio = 1.5\nlist = [1.5, 2.5]\nname = \"" + strings.Repeat("a.", 200) + "\"\n" +
+		"# " + strings.Repeat("a.", 200) + "\n" + "k"+strings.Repeat(".k", 50)+" = 1\nj"+strings.Repeat(".j", 50)+" = 1\n" +
+		"inline = { a.b = 1, c.d = { e.f = 2 } }\n[other]\n" + strings.Repeat("a.", 90) + "a = 1\n"
+	content, ok := service.ParseBody([]byte(body), "application/toml").Content.(map[string]interface{})
+	if !ok || content["server"] == nil || content["other"] == nil {
+		t.Errorf("Expected TOML with dotted keys to be decoded, got %v", content)
+	}
+}
+
+func TestBodyService_ParseCSV(t *testing.T) {
+	service := NewBodyService()
+
+	bodyInfo := service.ParseBody([]byte("id,name\n1,\"Doe, Jane\"\n2,John\n"), "text/csv")
+	rows, ok := bodyInfo.Content.([]interface{})
+	if !ok || len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %v", bodyInfo.Content)
+	}
+	if row := rows[0].(map[string]interface{}); row["id"] != "1" || row["name"] != "Doe, Jane" {
+		t.Errorf("Unexpected first row: %v", row)
+	}
+	if bodyInfo.Truncated {
+		t.Error("Expected rows not to be truncated")
+	}
+
+	bodyInfo = service.ParseBody([]byte("1,a\n2,b\n"), "text/csv; header=absent")
+	rows, ok = bodyInfo.Content.([]interface{})
+	if !ok || len(rows) != 2 {
+		t.Fatalf("Expected 2 rows without header, got %v", bodyInfo.Content)
+	}
+	if row := rows[1].([]interface{}); row[0] != "2" || row[1] != "b" {
+		t.Errorf("Unexpected second row: %v", row)
+	}
+
+	ragged := "a,b\n1\n"
+	if content := service.ParseBody([]byte(ragged), "text/csv").Content; content != ragged {
+		t.Errorf("Expected ragged CSV to be returned as a string, got %v", content)
+	}
+}
+
+func TestBodyService_ParseCSVRowLimit(t *testing.T) {
+	body := "n\n" + strings.Repeat("1\n", maxCSVRows+5)
+	bodyInfo := NewBodyService().ParseBody([]byte(body), "text/csv")
+
+	rows, ok := bodyInfo.Content.([]interface{})
+	if !ok || len(rows) != maxCSVRows {
+		t.Fatalf("Expected %d rows", maxCSVRows)
+	}
+	if !bodyInfo.Truncated {
+		t.Error("Expected dropped rows to be reported as truncated")
+	}
+}
+
+func TestBodyService_ParseMessagePack(t *testing.T) {
+	body, err := msgpack.Marshal(map[string]interface{}{
+		"device":   "sensor-1",
+		"readings": []float64{21.5, 22},
+		"flags":    map[int]bool{1: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode MessagePack: %v", err)
+	}
+
+	bodyInfo := NewBodyService().ParseBody(body, "application/msgpack")
+	content, ok := bodyInfo.Content.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected content to be a map, got %T", bodyInfo.Content)
+	}
+	if bodyInfo.IsBinary {
+		t.Error("Expected decoded MessagePack not to be marked as binary")
+	}
+	if content["device"] != "sensor-1" {
+		t.Errorf("Expected device, got %v", content["device"])
+	}
+	if flags, isMap := content["flags"].(map[string]interface{}); !isMap || flags["1"] != true {
+		t.Errorf("Expected integer keys to be converted, got %#v", content["flags"])
+	}
+	if _, marshalErr := json.Marshal(bodyInfo); marshalErr != nil {
+		t.Errorf("Expected decoded content to marshal as JSON: %v", marshalErr)
+	}
+}
+
+func TestBodyService_ParseMessagePackNesting(t *testing.T) {
+	// Nested one-element arrays around a nil, as deep as allowed and one level deeper
+	nested := func(depth int) []byte {
+		return append(bytes.Repeat([]byte{0x91}, depth), 0xc0)
+	}
+
+	if bodyInfo := NewBodyService().ParseBody(nested(maxNestingDepth), "application/msgpack"); bodyInfo.IsBinary {
+		t.Errorf("Expected %d nested arrays to be decoded", maxNestingDepth)
+	}
+	if bodyInfo := NewBodyService().ParseBody(nested(maxNestingDepth+1), "application/msgpack"); !bodyInfo.IsBinary {
+		t.Errorf("Expected %d nested arrays to be rejected", maxNestingDepth+1)
+	}
+
+	// Deep enough to overflow the stack if it reached the decoder
+	bodyInfo := NewBodyService().ParseBody(nested(5*1024*1024), "application/msgpack")
+	if !bodyInfo.IsBinary {
+		t.Error("Expected deeply nested MessagePack to be treated as binary data")
+	}
+}
+
+func TestCheckMessagePackDepth(t *testing.T) {
+	body, err := msgpack.Marshal([]interface{}{
+		"text", []byte{1, 2, 3}, int64(-1 << 40), uint64(1 << 63), 1.5, float32(2.5), true, nil,
+		map[string]interface{}{"nested": []interface{}{map[string]interface{}{}, []interface{}{}}},
+		strings.Repeat("x", 300), msgpack.RawMessage{0xd6, 0x01, 0, 0, 0, 0},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode MessagePack: %v", err)
+	}
+
+	if err = checkMessagePackDepth(body, maxNestingDepth); err != nil {
+		t.Errorf("Expected valid MessagePack to pass, got %v", err)
+	}
+	if err = checkMessagePackDepth(body, 2); err == nil {
+		t.Error("Expected nesting deeper than 2 levels to be rejected")
+	}
+	if err = checkMessagePackDepth(body[:len(body)-3], maxNestingDepth); err == nil {
+		t.Error("Expected truncated MessagePack to be rejected")
+	}
+}
+
+func TestBodyService_ParseCBOR(t *testing.T) {
+	body, err := cbor.Marshal(map[interface{}]interface{}{
+		"temp": 21.5,
+		7:      []interface{}{"a", uint64(2)},
+		"tag":  cbor.Tag{Number: 4000, Content: map[interface{}]interface{}{1: "x"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode CBOR: %v", err)
+	}
+
+	bodyInfo := NewBodyService().ParseBody(body, "application/cbor")
+	content, ok := bodyInfo.Content.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected content to be a map, got %T", bodyInfo.Content)
+	}
+	if content["temp"] != 21.5 {
+		t.Errorf("Expected temp, got %v", content["temp"])
+	}
+	if list, isList := content["7"].([]interface{}); !isList || len(list) != 2 {
+		t.Errorf("Expected integer key to be converted, got %v", content)
+	}
+	if tag, isMap := content["tag"].(map[string]interface{}); !isMap || tag["tag"] != uint64(4000) {
+		t.Errorf("Expected unknown tag to be described, got %#v", content["tag"])
+	}
+	if _, marshalErr := json.Marshal(bodyInfo); marshalErr != nil {
+		t.Errorf("Expected decoded content to marshal as JSON: %v", marshalErr)
+	}
+}
+
+func TestBodyService_ParseBSON(t *testing.T) {
+	id := bson.NewObjectID()
+	body, err := bson.Marshal(bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "widget"}, {Key: "tags", Value: bson.A{"a", "b"}}})
+	if err != nil {
+		t.Fatalf("Failed to encode BSON: %v", err)
+	}
+
+	content, ok := NewBodyService().ParseBody(body, "application/bson").Content.(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected content to be a map")
+	}
+	if content["name"] != "widget" {
+		t.Errorf("Expected name, got %v", content["name"])
+	}
+	if oid, isMap := content["_id"].(map[string]interface{}); !isMap || oid["$oid"] != id.Hex() {
+		t.Errorf("Expected ObjectID as Extended JSON, got %v", content["_id"])
+	}
+}
+
+func TestBodyService_ParseBinaryFormatInvalid(t *testing.T) {
+	service := NewBodyService()
+
+	tests := map[string][]byte{
+		"application/msgpack": {0x92, 0x01},       // array of two with one element
+		"application/cbor":    {0xa1, 0x01},       // map missing its value
+		"application/bson":    {0x05, 0x00, 0x00}, // truncated document
+	}
+
+	for contentType, body := range tests {
+		bodyInfo := service.ParseBody(body, contentType)
+		if !bodyInfo.IsBinary {
+			t.Errorf("%s: expected undecodable body to fall back to binary, got %v", contentType, bodyInfo.Content)
+		}
+	}
+}
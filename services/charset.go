@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// describeCharset reports the declared and detected charset of a text body with a declared charset.
+// The encoding returned is the one to transcode the body from, and is nil when the body needs no
+// transcoding or its charset is not supported.
+func describeCharset(data []byte, declared string) (*models.CharsetInfo, encoding.Encoding) {
+	if declared == "" {
+		return nil, nil
+	}
+
+	info := &models.CharsetInfo{
+		Declared: strings.ToLower(declared),
+		Detected: detectCharset(data),
+	}
+
+	textEncoding := lookupCharset(declared)
+	if textEncoding == nil {
+		info.Error = "unsupported charset " + declared
+		return info, nil
+	}
+
+	// UTF-8 and its ASCII subset are displayed as they are
+	if name, err := ianaindex.MIME.Name(textEncoding); err == nil && (name == "UTF-8" || name == "US-ASCII") {
+		return info, nil
+	}
+	return info, textEncoding
+}
+
+// lookupCharset returns the encoding for an IANA charset name or WHATWG label,
+// or nil when it is unknown or not supported
+func lookupCharset(name string) encoding.Encoding {
+	if textEncoding, err := ianaindex.IANA.Encoding(name); err == nil && textEncoding != nil {
+		return textEncoding
+	}
+	if textEncoding, err := htmlindex.Get(name); err == nil {
+		return textEncoding
+	}
+	return nil
+}
+
+// detectCharset guesses the charset of a body from its byte order mark or its bytes.
+// It returns an empty string when the charset cannot be told.
+func detectCharset(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return "utf-8"
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return "utf-16le"
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return "utf-16be"
+	}
+
+	ascii := true
+	evenZeros, oddZeros := 0, 0
+	for i, b := range data {
+		switch {
+		case b == 0 && i%2 == 0:
+			evenZeros++
+		case b == 0:
+			oddZeros++
+		case b >= utf8.RuneSelf:
+			ascii = false
+		}
+	}
+
+	switch {
+	case evenZeros+oddZeros > 0:
+		// Mostly Latin text in UTF-16 has a zero in every other byte
+		if evenZeros == 0 && oddZeros >= len(data)/4 {
+			return "utf-16le"
+		}
+		if oddZeros == 0 && evenZeros >= len(data)/4 {
+			return "utf-16be"
+		}
+		return ""
+	case ascii:
+		return "us-ascii"
+	case utf8.Valid(data):
+		return "utf-8"
+	default:
+		return ""
+	}
+}
+
+// decodeString transcodes a string to UTF-8, leaving it unchanged when it cannot be decoded
+func decodeString(value string, textEncoding encoding.Encoding) string {
+	decoded, err := textEncoding.NewDecoder().String(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
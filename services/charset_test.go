@@ -0,0 +1,166 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestBodyService_ParseBodyCharsets(t *testing.T) {
+	service := NewBodyService()
+
+	tests := []struct {
+		content     interface{}
+		name        string
+		contentType string
+		declared    string
+		detected    string
+		body        []byte
+		transcoded  bool
+	}{
+		{
+			name:        "latin-1 text",
+			body:        []byte("caf\xe9 cr\xe8me"),
+			contentType: "text/plain; charset=ISO-8859-1",
+			declared:    "iso-8859-1",
+			content:     "café crème",
+			transcoded:  true,
+		},
+		{
+			name:        "windows-1252 text",
+			body:        []byte("\x80 5 \x96 \x93quoted\x94"),
+			contentType: "text/plain; charset=windows-1252",
+			declared:    "windows-1252",
+			content:     "€ 5 – “quoted”",
+			transcoded:  true,
+		},
+		{
+			name:        "shift_jis text",
+			body:        []byte("\x93\xfa\x96\x7b"),
+			contentType: "text/plain; charset=Shift_JIS",
+			declared:    "shift_jis",
+			content:     "日本",
+			transcoded:  true,
+		},
+		{
+			name:        "utf-16 with byte order mark",
+			body:        []byte("\xff\xfeh\x00i\x00"),
+			contentType: "text/plain; charset=utf-16",
+			declared:    "utf-16",
+			detected:    "utf-16le",
+			content:     "hi",
+			transcoded:  true,
+		},
+		{
+			name:        "utf-8 text",
+			body:        []byte("café"),
+			contentType: "text/plain; charset=utf-8",
+			declared:    "utf-8",
+			detected:    "utf-8",
+			content:     "café",
+		},
+		{
+			name:        "ascii text in a legacy charset",
+			body:        []byte("plain"),
+			contentType: "text/plain; charset=iso-8859-1",
+			declared:    "iso-8859-1",
+			detected:    "us-ascii",
+			content:     "plain",
+		},
+		{
+			name:        "utf-8 text declared as latin-1",
+			body:        []byte("café"),
+			contentType: "text/plain; charset=iso-8859-1",
+			declared:    "iso-8859-1",
+			detected:    "utf-8",
+			content:     "cafÃ©",
+			transcoded:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodyInfo := service.ParseBody(tt.body, tt.contentType)
+			if bodyInfo == nil || bodyInfo.Charset == nil {
+				t.Fatalf("Expected charset info, got %+v", bodyInfo)
+			}
+			if bodyInfo.IsBinary {
+				t.Error("Expected text not to be treated as binary")
+			}
+			if bodyInfo.Content != tt.content {
+				t.Errorf("Expected content %q, got %q", tt.content, bodyInfo.Content)
+			}
+			charset := bodyInfo.Charset
+			if charset.Declared != tt.declared || charset.Detected != tt.detected || charset.Transcoded != tt.transcoded || charset.Error != "" {
+				t.Errorf("Unexpected charset info %+v", charset)
+			}
+		})
+	}
+}
+
+func TestBodyService_ParseBodyCharsetJSON(t *testing.T) {
+	service := NewBodyService()
+
+	bodyInfo := service.ParseBody([]byte("{\"name\":\"Jos\xe9\"}"), "application/json; charset=latin1")
+	content, ok := bodyInfo.Content.(map[string]interface{})
+	if !ok || content["name"] != "José" {
+		t.Errorf("Expected transcoded JSON, got %v", bodyInfo.Content)
+	}
+}
+
+func TestBodyService_ParseBodyCharsetForm(t *testing.T) {
+	service := NewBodyService()
+
+	bodyInfo := service.ParseBody([]byte("name=Jos%E9&city=Malm%F6&tag=a&tag=%E5"), "application/x-www-form-urlencoded; charset=ISO-8859-1")
+	content, ok := bodyInfo.Content.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected form fields, got %v", bodyInfo.Content)
+	}
+	if content["name"] != "José" || content["city"] != "Malmö" {
+		t.Errorf("Expected transcoded form values, got %v", content)
+	}
+	if tags, tagsOK := content["tag"].([]string); !tagsOK || len(tags) != 2 || tags[1] != "å" {
+		t.Errorf("Expected transcoded repeated values, got %v", content["tag"])
+	}
+	if bodyInfo.Charset == nil || !bodyInfo.Charset.Transcoded {
+		t.Errorf("Expected form to be reported as transcoded, got %+v", bodyInfo.Charset)
+	}
+}
+
+func TestBodyService_ParseBodyUnsupportedCharset(t *testing.T) {
+	service := NewBodyService()
+
+	bodyInfo := service.ParseBody([]byte("caf\xe9"), "text/plain; charset=x-unknown")
+	if bodyInfo.Charset == nil || bodyInfo.Charset.Error == "" || bodyInfo.Charset.Transcoded {
+		t.Errorf("Expected unsupported charset error, got %+v", bodyInfo.Charset)
+	}
+	if !bodyInfo.IsBinary {
+		t.Error("Expected undecodable text to be treated as binary")
+	}
+}
+
+func TestBodyService_ParseBodyWithoutCharset(t *testing.T) {
+	service := NewBodyService()
+
+	if bodyInfo := service.ParseBody([]byte("hello"), "text/plain"); bodyInfo.Charset != nil {
+		t.Errorf("Expected no charset info without a declared charset, got %+v", bodyInfo.Charset)
+	}
+}
+
+func TestDetectCharset(t *testing.T) {
+	tests := map[string]string{
+		"\xef\xbb\xbfhi":     "utf-8",
+		"\xff\xfeh\x00":      "utf-16le",
+		"\xfe\xff\x00h":      "utf-16be",
+		"h\x00i\x00!\x00":    "utf-16le",
+		"\x00h\x00i\x00!":    "utf-16be",
+		"plain text":         "us-ascii",
+		"caf\xc3\xa9":        "utf-8",
+		"caf\xe9":            "",
+		"\x00\x00\x01\x02ab": "",
+	}
+
+	for data, want := range tests {
+		if got := detectCharset([]byte(data)); got != want {
+			t.Errorf("detectCharset(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
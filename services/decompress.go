@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// maxZstdWindow caps the memory a zstd frame may ask for while decoding
+const maxZstdWindow = 8 << 20
+
+// DecompressBody decodes a body sent with a Content-Encoding, undoing each listed coding in reverse order.
+// Decoded output is capped at the maximum body size so a small body cannot expand without bound.
+// The info is nil when the body is not encoded; when it cannot be decoded, the body is returned
+// unchanged with the error recorded.
+func (s *BodyService) DecompressBody(bodyBytes []byte, contentEncoding string) ([]byte, *models.DecompressionInfo) {
+	var encodings []string
+	for _, encoding := range strings.Split(contentEncoding, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	if len(encodings) == 0 {
+		return bodyBytes, nil
+	}
+
+	info := &models.DecompressionInfo{
+		Encoding:       strings.Join(encodings, ", "),
+		CompressedSize: len(bodyBytes),
+	}
+
+	decoded := bodyBytes
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		decoded, info.LimitExceeded, err = decompress(decoded, encodings[i], s.maxBodySize)
+		if err != nil {
+			info.Error = err.Error()
+			return bodyBytes, info
+		}
+		if info.LimitExceeded && i > 0 {
+			// A cut-off intermediate coding cannot be decoded further
+			info.Error = fmt.Sprintf("%s: decoded body exceeds %d bytes", encodings[i], s.maxBodySize)
+			info.LimitExceeded = false
+			return bodyBytes, info
+		}
+	}
+
+	info.DecompressedSize = len(decoded)
+	return decoded, info
+}
+
+// ParseEncodedBody decodes a body sent with a Content-Encoding and parses the result, recording
+// the decompression in the body info. It also returns the decoded body.
+func (s *BodyService) ParseEncodedBody(bodyBytes []byte, contentEncoding, contentType, messageType string) (*models.BodyInfo, []byte) {
+	decoded, decompression := s.DecompressBody(bodyBytes, contentEncoding)
+	bodyInfo := s.ParseBodyAs(decoded, contentType, messageType)
+
+	if decompression != nil {
+		// A body that decompresses to nothing is still described
+		if bodyInfo == nil {
+			bodyInfo = &models.BodyInfo{ContentType: contentType}
+		}
+		bodyInfo.Decompression = decompression
+		bodyInfo.Truncated = bodyInfo.Truncated || decompression.LimitExceeded
+	}
+	return bodyInfo, decoded
+}
+
+// decompress decodes data in a single content coding, reading at most limit bytes of output.
+// The second return value reports whether the output was cut off at the limit.
+func decompress(data []byte, encoding string, limit int) ([]byte, bool, error) {
+	var reader io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("gzip: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "deflate":
+		reader = newDeflateReader(data)
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(data))
+	case "zstd":
+		zstdReader, err := zstd.NewReader(bytes.NewReader(data),
+			zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow))
+		if err != nil {
+			return nil, false, fmt.Errorf("zstd: %w", err)
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		return nil, false, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	// Read one byte past the limit to tell a body of exactly the limit from a larger one
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", encoding, err)
+	}
+	if len(decoded) > limit {
+		return decoded[:limit], true, nil
+	}
+	return decoded, false, nil
+}
+
+// newDeflateReader reads the deflate content coding, which is zlib-wrapped per RFC 9110
+// but sent as raw deflate by some clients
+func newDeflateReader(data []byte) io.Reader {
+	buffered := bufio.NewReader(bytes.NewReader(data))
+	if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+		if zlibReader, zlibErr := zlib.NewReader(buffered); zlibErr == nil {
+			return zlibReader
+		}
+	}
+	return flate.NewReader(bytes.NewReader(data))
+}
+
+// isZlibHeader reports whether the two bytes form a valid zlib header (deflate method, valid check bits)
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
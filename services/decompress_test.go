@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compress encodes data with the given content coding
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		flateWriter, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			t.Fatalf("Failed to create flate writer: %v", err)
+		}
+		writer = flateWriter
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	case "zstd":
+		zstdWriter, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("Failed to create zstd writer: %v", err)
+		}
+		writer = zstdWriter
+	default:
+		t.Fatalf("Unknown encoding %s", encoding)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBodyService_DecompressBody(t *testing.T) {
+	service := NewBodyService()
+	original := []byte(`{"device":"phone","readings":[1,2,3]}`)
+
+	tests := []struct {
+		encoding string
+		header   string
+	}{
+		{"gzip", "gzip"},
+		{"gzip", "x-gzip"},
+		{"deflate", "deflate"},
+		{"raw-deflate", "deflate"},
+		{"br", "br"},
+		{"zstd", "ZSTD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding+"/"+tt.header, func(t *testing.T) {
+			compressed := compress(t, tt.encoding, original)
+			decoded, info := service.DecompressBody(compressed, tt.header)
+
+			if !bytes.Equal(decoded, original) {
+				t.Errorf("Expected %s, got %q", original, decoded)
+			}
+			if info == nil || info.Error != "" {
+				t.Fatalf("Expected successful decompression, got %+v", info)
+			}
+			if info.CompressedSize != len(compressed) || info.DecompressedSize != len(original) {
+				t.Errorf("Unexpected sizes: %+v", info)
+			}
+		})
+	}
+}
+
+func TestBodyService_DecompressBodyStacked(t *testing.T) {
+	original := []byte("hello, hello, hello")
+	compressed := compress(t, "br", compress(t, "gzip", original))
+
+	decoded, info := NewBodyService().DecompressBody(compressed, "gzip, identity, br")
+	if !bytes.Equal(decoded, original) {
+		t.Errorf("Expected codings to be undone in reverse order, got %q (%+v)", decoded, info)
+	}
+	if info.Encoding != "gzip, br" {
+		t.Errorf("Expected identity to be ignored, got %q", info.Encoding)
+	}
+}
+
+func TestBodyService_DecompressBodyNotEncoded(t *testing.T) {
+	body := []byte("plain")
+	for _, header := range []string{"", "identity"} {
+		if decoded, info := NewBodyService().DecompressBody(body, header); info != nil || !bytes.Equal(decoded, body) {
+			t.Errorf("Content-Encoding %q: expected body unchanged without info, got %q %+v", header, decoded, info)
+		}
+	}
+}
+
+func TestBodyService_DecompressBodyErrors(t *testing.T) {
+	body := []byte("definitely not gzip")
+
+	for _, header := range []string{"gzip", "br", "zstd", "deflate", "compress"} {
+		decoded, info := NewBodyService().DecompressBody(body, header)
+		if info == nil || info.Error == "" {
+			t.Errorf("%s: expected error, got %+v", header, info)
+		}
+		if !bytes.Equal(decoded, body) {
+			t.Errorf("%s: expected original body on error, got %q", header, decoded)
+		}
+	}
+}
+
+func TestBodyService_DecompressBodyLimit(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "1024")
+	service := NewBodyService()
+
+	// A small body that expands far beyond the limit
+	bomb := compress(t, "gzip", []byte(strings.Repeat("a", 256<<10)))
+
+	decoded, info := service.DecompressBody(bomb, "gzip")
+	if len(decoded) != 1024 || !info.LimitExceeded || info.Error != "" {
+		t.Errorf("Expected output cut off at 1024 bytes, got %d bytes (%+v)", len(decoded), info)
+	}
+
+	// Codings applied after gzip are undone first and stay within the limit here
+	stacked := compress(t, "zstd", bomb)
+	decoded, info = service.DecompressBody(stacked, "gzip, zstd")
+	if len(decoded) != 1024 || !info.LimitExceeded || info.Error != "" {
+		t.Errorf("Expected final output cut off at the limit, got %d bytes (%+v)", len(decoded), info)
+	}
+
+	// An intermediate coding past the limit cannot be decoded further
+	incompressible := make([]byte, 4096)
+	_, _ = rand.NewChaCha8([32]byte{}).Read(incompressible)
+	stacked = compress(t, "zstd", compress(t, "gzip", incompressible))
+	decoded, info = service.DecompressBody(stacked, "gzip, zstd")
+	if info.Error == "" || info.LimitExceeded || !bytes.Equal(decoded, stacked) {
+		t.Errorf("Expected error and the original body, got %d bytes (%+v)", len(decoded), info)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"crypto/md5"  // #nosec G501 -- MD5 is computed to verify Content-MD5 headers, not for security
+	"crypto/sha1" // #nosec G505 -- SHA-1 is computed to verify integrity headers, not for security
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"hash/crc64"
+	"sort"
+	"strings"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+var (
+	crc32cTable    = crc32.MakeTable(crc32.Castagnoli)
+	crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+)
+
+// hashAlgorithms are the hash functions used for digests, keyed by the names used in digestAlgorithms
+// and amzChecksumAlgorithms
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256":    sha256.New,
+	"sha512":    sha512.New,
+	"sha1":      sha1.New,
+	"md5":       md5.New,
+	"adler32":   func() hash.Hash { return adler32.New() },
+	"crc32":     func() hash.Hash { return crc32.NewIEEE() },
+	"crc32c":    func() hash.Hash { return crc32.New(crc32cTable) },
+	"crc64nvme": func() hash.Hash { return crc64.New(crc64NVMETable) },
+}
+
+// digestAlgorithms maps the RFC 9530 hash algorithms accepted in Content-Digest and Repr-Digest to hash functions
+var digestAlgorithms = map[string]string{
+	"sha-256": "sha256",
+	"sha-512": "sha512",
+	"sha":     "sha1",
+	"md5":     "md5",
+	"adler":   "adler32",
+	"crc32c":  "crc32c",
+}
+
+// amzChecksumAlgorithms maps the algorithms of the S3 x-amz-checksum-* headers to hash functions
+var amzChecksumAlgorithms = map[string]string{
+	"crc32":     "crc32",
+	"crc32c":    "crc32c",
+	"crc64nvme": "crc64nvme",
+	"sha1":      "sha1",
+	"sha256":    "sha256",
+}
+
+// reportedDigests are the hash functions whose digests are always reported
+var reportedDigests = []string{"sha256", "sha1", "md5", "crc32c"}
+
+// pendingCheck is an integrity check waiting for the body to be hashed
+type pendingCheck struct {
+	expected []byte
+	hashName string
+	check    models.IntegrityCheck
+}
+
+// BodyHasher computes the digests of a body as it is written, so bodies streamed without being
+// buffered can be checked against their integrity headers
+type BodyHasher struct {
+	hashes map[string]hash.Hash
+	checks []pendingCheck
+}
+
+// NewBodyHasher creates a hasher for the reported digests and the integrity headers sent with a body:
+// Content-MD5, Content-Digest, Repr-Digest and x-amz-checksum-*
+func NewBodyHasher(headers map[string]string) *BodyHasher {
+	hasher := &BodyHasher{hashes: make(map[string]hash.Hash)}
+	for _, name := range reportedDigests {
+		hasher.hashes[name] = hashAlgorithms[name]()
+	}
+
+	// Header names are matched case-insensitively and checked in a stable order
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := headers[name]
+		lower := strings.ToLower(name)
+		switch {
+		case lower == "content-md5":
+			hasher.addCheck(name, "md5", value, "md5")
+		case lower == "content-digest" || lower == "repr-digest":
+			hasher.addDigestFields(name, value)
+		case strings.HasPrefix(lower, "x-amz-checksum-"):
+			algorithm := strings.TrimPrefix(lower, "x-amz-checksum-")
+			if algorithm == "algorithm" || algorithm == "type" {
+				// These name the checksum algorithm rather than carry a checksum
+				continue
+			}
+			hasher.addCheck(name, algorithm, value, amzChecksumAlgorithms[algorithm])
+		}
+	}
+
+	return hasher
+}
+
+// Write hashes the next chunk of the body
+func (h *BodyHasher) Write(p []byte) (int, error) {
+	for _, digest := range h.hashes {
+		_, _ = digest.Write(p)
+	}
+	return len(p), nil
+}
+
+// Finish records the digests of the body written so far and the results of the integrity checks
+func (h *BodyHasher) Finish(bodyInfo *models.BodyInfo) {
+	bodyInfo.Digests = h.digests()
+
+	var checks []models.IntegrityCheck
+	for _, pending := range h.checks {
+		check := pending.check
+		if check.Error == "" {
+			actual := h.hashes[pending.hashName].Sum(nil)
+			check.Actual = base64.StdEncoding.EncodeToString(actual)
+			check.Match = bytes.Equal(pending.expected, actual)
+		}
+		checks = append(checks, check)
+	}
+	bodyInfo.Integrity = checks
+}
+
+// digests returns the reported digests of the body written so far
+func (h *BodyHasher) digests() *models.BodyDigests {
+	return &models.BodyDigests{
+		SHA256: hex.EncodeToString(h.hashes["sha256"].Sum(nil)),
+		SHA1:   hex.EncodeToString(h.hashes["sha1"].Sum(nil)),
+		MD5:    hex.EncodeToString(h.hashes["md5"].Sum(nil)),
+		CRC32C: hex.EncodeToString(h.hashes["crc32c"].Sum(nil)),
+	}
+}
+
+// addDigestFields adds a check for each digest of an RFC 9530 Content-Digest or Repr-Digest header,
+// a structured field dictionary such as sha-256=:base64:, sha-512=:base64:
+func (h *BodyHasher) addDigestFields(header, value string) {
+	for _, member := range strings.Split(value, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+
+		algorithm, digest, _ := strings.Cut(member, "=")
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		// Drop any parameters and the byte sequence delimiters
+		digest, _, _ = strings.Cut(digest, ";")
+		digest = strings.TrimSpace(digest)
+
+		if len(digest) < 2 || digest[0] != ':' || digest[len(digest)-1] != ':' {
+			h.checks = append(h.checks, pendingCheck{check: models.IntegrityCheck{
+				Header:    header,
+				Algorithm: algorithm,
+				Expected:  digest,
+				Error:     "digest is not a structured field byte sequence",
+			}})
+			continue
+		}
+
+		h.addCheck(header, algorithm, digest[1:len(digest)-1], digestAlgorithms[algorithm])
+	}
+}
+
+// addCheck adds a check of a base64-encoded digest from a header against the named hash of the body
+func (h *BodyHasher) addCheck(header, algorithm, expected, hashName string) {
+	pending := pendingCheck{
+		hashName: hashName,
+		check: models.IntegrityCheck{
+			Header:    header,
+			Algorithm: algorithm,
+			Expected:  strings.TrimSpace(expected),
+		},
+	}
+
+	if hashName == "" {
+		pending.check.Error = fmt.Sprintf("unsupported algorithm %q", algorithm)
+		h.checks = append(h.checks, pending)
+		return
+	}
+
+	var err error
+	pending.expected, err = base64.StdEncoding.DecodeString(pending.check.Expected)
+	if err != nil {
+		pending.check.Error = "digest is not valid base64"
+	} else if _, ok := h.hashes[hashName]; !ok {
+		h.hashes[hashName] = hashAlgorithms[hashName]()
+	}
+	h.checks = append(h.checks, pending)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"crypto/md5" // #nosec G501 -- test fixture digests
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// checkIntegrity hashes a whole body and records its digests and integrity checks
+func checkIntegrity(bodyInfo *models.BodyInfo, body []byte, headers map[string]string) {
+	hasher := NewBodyHasher(headers)
+	_, _ = hasher.Write(body)
+	hasher.Finish(bodyInfo)
+}
+
+func TestBodyHasher_Digests(t *testing.T) {
+	bodyInfo := &models.BodyInfo{}
+	checkIntegrity(bodyInfo, []byte("hello world"), nil)
+	digests := bodyInfo.Digests
+
+	expected := models.BodyDigests{
+		SHA256: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		SHA1:   "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+		MD5:    "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		CRC32C: "c99465aa",
+	}
+	if *digests != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *digests)
+	}
+}
+
+func TestBodyHasher_Integrity(t *testing.T) {
+	body := []byte(`{"hello":"world"}`)
+	sha256Sum := sha256.Sum256(body)
+	sha512Sum := sha512.Sum512(body)
+	md5Sum := md5.Sum(body) // #nosec G401 -- test fixture digest
+	b64 := base64.StdEncoding.EncodeToString
+
+	headers := map[string]string{
+		"Content-Md5":            b64(md5Sum[:]),
+		"Content-Digest":         "sha-256=:" + b64(sha256Sum[:]) + ":, sha-512=:" + b64(sha512Sum[:]) + ":",
+		"Repr-Digest":            "sha-256=:" + b64([]byte("corrupted")) + ":;foo=bar, unixsum=:AAAA:, sha=nope",
+		"X-Amz-Checksum-Sha256":  b64(sha256Sum[:]),
+		"X-Amz-Checksum-Crc32":   "AAAAAA==",
+		"X-Amz-Checksum-Type":    "FULL_OBJECT",
+		"X-Amz-Checksum-Unknown": "AAAA",
+		"Content-Type":           "application/json",
+	}
+
+	bodyInfo := &models.BodyInfo{}
+	checkIntegrity(bodyInfo, body, headers)
+
+	if bodyInfo.Digests == nil {
+		t.Fatal("Expected digests to be computed")
+	}
+
+	type result struct {
+		err   bool
+		match bool
+	}
+	expected := map[string]result{
+		"Content-Md5/md5":                {match: true},
+		"Content-Digest/sha-256":         {match: true},
+		"Content-Digest/sha-512":         {match: true},
+		"Repr-Digest/sha-256":            {match: false},
+		"Repr-Digest/unixsum":            {err: true},
+		"Repr-Digest/sha":                {err: true},
+		"X-Amz-Checksum-Sha256/sha256":   {match: true},
+		"X-Amz-Checksum-Crc32/crc32":     {match: false},
+		"X-Amz-Checksum-Unknown/unknown": {err: true},
+	}
+
+	if len(bodyInfo.Integrity) != len(expected) {
+		t.Fatalf("Expected %d checks, got %+v", len(expected), bodyInfo.Integrity)
+	}
+	for _, check := range bodyInfo.Integrity {
+		want, ok := expected[check.Header+"/"+check.Algorithm]
+		if !ok {
+			t.Errorf("Unexpected check %+v", check)
+			continue
+		}
+		if (check.Error != "") != want.err || check.Match != want.match {
+			t.Errorf("%s %s: expected error=%t match=%t, got %+v", check.Header, check.Algorithm, want.err, want.match, check)
+		}
+	}
+}
+
+func TestBodyHasher_IntegrityAmzChecksums(t *testing.T) {
+	body := []byte("hello world")
+
+	// Known checksums of "hello world"
+	headers := map[string]string{
+		"x-amz-checksum-crc32":     "DUoRhQ==",
+		"x-amz-checksum-crc32c":    "yZRlqg==",
+		"x-amz-checksum-crc64nvme": "jSnVw/bqjr4=",
+		"x-amz-checksum-sha1":      "Kq5sNclPz7QV2+lfQIuc6R7oRu0=",
+	}
+
+	bodyInfo := &models.BodyInfo{}
+	checkIntegrity(bodyInfo, body, headers)
+
+	for _, check := range bodyInfo.Integrity {
+		if !check.Match {
+			t.Errorf("Expected %s to match, got %+v", check.Header, check)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"  // Register GIF for image.DecodeConfig
+	_ "image/jpeg" // Register JPEG for image.DecodeConfig
+	_ "image/png"  // Register PNG for image.DecodeConfig
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/bmp"  // Register BMP for image.DecodeConfig
+	_ "golang.org/x/image/tiff" // Register TIFF for image.DecodeConfig
+	_ "golang.org/x/image/webp" // Register WebP for image.DecodeConfig
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// maxArchiveEntries is the largest number of archive entries listed in file type metadata
+const maxArchiveEntries = 1000
+
+// fileSignature identifies a file type by its magic bytes
+type fileSignature struct {
+	match       func(data []byte) bool
+	mediaType   string
+	extension   string
+	description string
+}
+
+// fileSignatures are checked in order; more specific signatures come before those they overlap with
+var fileSignatures = []fileSignature{
+	{magicAt(0, "\x89PNG\r\n\x1a\n"), "image/png", "png", "PNG image"},
+	{magicAt(0, "\xff\xd8\xff"), "image/jpeg", "jpg", "JPEG image"},
+	{magicAt(0, "GIF87a", "GIF89a"), "image/gif", "gif", "GIF image"},
+	{riffForm("WEBP"), "image/webp", "webp", "WebP image"},
+	{magicAt(0, "II*\x00", "MM\x00*"), "image/tiff", "tiff", "TIFF image"},
+	{magicAt(0, "\x00\x00\x01\x00"), "image/x-icon", "ico", "Windows icon"},
+	{magicAt(0, "BM"), "image/bmp", "bmp", "BMP image"},
+	{magicAt(0, "%PDF-"), "application/pdf", "pdf", "PDF document"},
+	{magicAt(0, "PK\x03\x04", "PK\x05\x06"), "application/zip", "zip", "ZIP archive"},
+	{magicAt(0, "\x1f\x8b"), "application/gzip", "gz", "gzip compressed data"},
+	{magicAt(0, "BZh"), "application/x-bzip2", "bz2", "bzip2 compressed data"},
+	{magicAt(0, "\xfd7zXZ\x00"), "application/x-xz", "xz", "XZ compressed data"},
+	{magicAt(0, "\x28\xb5\x2f\xfd"), "application/zstd", "zst", "Zstandard compressed data"},
+	{magicAt(0, "7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed", "7z", "7-Zip archive"},
+	{magicAt(0, "Rar!\x1a\x07"), "application/vnd.rar", "rar", "RAR archive"},
+	{magicAt(257, "ustar"), "application/x-tar", "tar", "tar archive"},
+	{magicAt(0, "\x00asm"), "application/wasm", "wasm", "WebAssembly module"},
+	{magicAt(0, "\x7fELF"), "application/x-elf", "", "ELF executable"},
+	{magicAt(0, "\xfe\xed\xfa\xce", "\xfe\xed\xfa\xcf", "\xce\xfa\xed\xfe", "\xcf\xfa\xed\xfe"), "application/x-mach-binary", "", "Mach-O executable"},
+	{magicAt(0, "MZ"), "application/vnd.microsoft.portable-executable", "exe", "Windows executable"},
+	{magicAt(0, "SQLite format 3\x00"), "application/vnd.sqlite3", "sqlite", "SQLite database"},
+	{magicAt(0, "PAR1"), "application/vnd.apache.parquet", "parquet", "Parquet file"},
+	{magicAt(4, "ftyp"), "video/mp4", "mp4", "MP4 media"},
+	{magicAt(0, "OggS"), "audio/ogg", "ogg", "Ogg media"},
+	{magicAt(0, "fLaC"), "audio/flac", "flac", "FLAC audio"},
+	{riffForm("WAVE"), "audio/wav", "wav", "WAV audio"},
+	{magicAt(0, "ID3"), "audio/mpeg", "mp3", "MP3 audio"},
+	{magicAt(0, "wOFF"), "font/woff", "woff", "WOFF font"},
+	{magicAt(0, "wOF2"), "font/woff2", "woff2", "WOFF2 font"},
+}
+
+// mediaTypeAliases maps unofficial media types to the ones reported by file type detection
+var mediaTypeAliases = map[string]string{
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"image/x-png":                  "image/png",
+	"image/x-ms-bmp":               "image/bmp",
+	"image/vnd.microsoft.icon":     "image/x-icon",
+	"application/x-pdf":            "application/pdf",
+	"application/x-zip":            "application/zip",
+	"application/x-zip-compressed": "application/zip",
+	"application/x-gzip":           "application/gzip",
+	"application/x-zstd":           "application/zstd",
+	"application/x-rar-compressed": "application/vnd.rar",
+	"application/x-msdownload":     "application/vnd.microsoft.portable-executable",
+	"application/x-sqlite3":        "application/vnd.sqlite3",
+	"audio/x-wav":                  "audio/wav",
+	"audio/wave":                   "audio/wav",
+	"audio/mp3":                    "audio/mpeg",
+	"application/font-woff":        "font/woff",
+}
+
+// zipContainers are file types stored as ZIP archives, identified by an entry they contain
+var zipContainers = []struct {
+	entry       string
+	mediaType   string
+	extension   string
+	description string
+}{
+	{"word/", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "docx", "Word document"},
+	{"xl/", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx", "Excel workbook"},
+	{"ppt/", "application/vnd.openxmlformats-officedocument.presentationml.presentation", "pptx", "PowerPoint presentation"},
+	{"META-INF/MANIFEST.MF", "application/java-archive", "jar", "Java archive"},
+}
+
+// pdfPagePattern matches page objects, but not the /Pages tree nodes
+var pdfPagePattern = regexp.MustCompile(`/Type\s*/Page([^s]|$)`)
+
+// magicAt matches data holding one of the magic byte sequences at the offset
+func magicAt(offset int, magics ...string) func([]byte) bool {
+	return func(data []byte) bool {
+		if len(data) < offset {
+			return false
+		}
+		for _, magic := range magics {
+			if bytes.HasPrefix(data[offset:], []byte(magic)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// riffForm matches a RIFF container of the given form type
+func riffForm(form string) func([]byte) bool {
+	return func(data []byte) bool {
+		return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == form
+	}
+}
+
+// DetectFileType sniffs the file type of a binary body from its magic bytes, extracts basic metadata
+// and compares it with the declared media type. It returns nil when the type is not recognized.
+func DetectFileType(data []byte, declaredType string) *models.FileTypeInfo {
+	var info *models.FileTypeInfo
+	for _, signature := range fileSignatures {
+		if signature.match(data) {
+			info = &models.FileTypeInfo{
+				MediaType:   signature.mediaType,
+				Extension:   signature.extension,
+				Description: signature.description,
+			}
+			break
+		}
+	}
+
+	if info == nil {
+		// Protobuf has no magic bytes, so it is only a guess when the whole body parses as wire format
+		fields, err := DecodeProtobufWire(data)
+		if err != nil || len(fields) == 0 {
+			return nil
+		}
+		info = &models.FileTypeInfo{
+			MediaType:   "application/x-protobuf",
+			Description: "Protobuf wire format (guessed)",
+			Metadata:    map[string]interface{}{"fields": len(fields)},
+		}
+	}
+
+	describeFileMetadata(info, data)
+
+	if declaredType != "" {
+		info.DeclaredType = declaredType
+		info.Mismatch = !isCompatibleMediaType(declaredType, info.MediaType)
+	}
+	return info
+}
+
+// describeFileMetadata adds metadata for the detected file type
+func describeFileMetadata(info *models.FileTypeInfo, data []byte) {
+	var metadata map[string]interface{}
+	var err error
+
+	switch info.MediaType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp", "image/tiff", "image/bmp":
+		metadata, err = imageMetadata(data)
+	case "application/pdf":
+		metadata = pdfMetadata(data)
+	case "application/zip":
+		metadata, err = zipMetadata(info, data)
+	case "application/x-tar":
+		metadata, err = tarMetadata(data)
+	case "application/gzip":
+		metadata, err = gzipMetadata(data)
+	case "application/wasm":
+		if len(data) >= 8 {
+			metadata = map[string]interface{}{"version": binary.LittleEndian.Uint32(data[4:8])}
+		}
+	default:
+		return
+	}
+
+	if err != nil {
+		info.Error = err.Error()
+	}
+	if len(metadata) > 0 {
+		info.Metadata = metadata
+	}
+}
+
+// imageMetadata reads the dimensions of an image from its header
+func imageMetadata(data []byte) (map[string]interface{}, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"format": format,
+		"width":  config.Width,
+		"height": config.Height,
+	}, nil
+}
+
+// pdfMetadata reads the version of a PDF document and counts its pages
+func pdfMetadata(data []byte) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"pages": len(pdfPagePattern.FindAllIndex(data, -1)),
+	}
+	if version := bytes.Fields(data[len("%PDF-"):min(len(data), 16)]); len(version) > 0 {
+		metadata["version"] = string(version[0])
+	}
+	return metadata
+}
+
+// zipMetadata lists the entries of a ZIP archive, refining the file type for documents stored as ZIP archives
+func zipMetadata(info *models.FileTypeInfo, data []byte) (map[string]interface{}, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.ArchiveEntry, 0, min(len(reader.File), maxArchiveEntries))
+	for _, file := range reader.File {
+		if len(entries) == maxArchiveEntries {
+			break
+		}
+		entries = append(entries, models.ArchiveEntry{
+			Name:           file.Name,
+			Modified:       formatModified(file.Modified),
+			Size:           int64(file.UncompressedSize64),
+			CompressedSize: int64(file.CompressedSize64),
+			Dir:            file.FileInfo().IsDir(),
+		})
+	}
+
+	for _, container := range zipContainers {
+		if hasArchiveEntry(reader.File, container.entry) {
+			info.MediaType = container.mediaType
+			info.Extension = container.extension
+			info.Description = container.description
+			break
+		}
+	}
+
+	return archiveMetadata(entries, len(reader.File)), nil
+}
+
+// hasArchiveEntry reports whether the archive has the named entry, or an entry in the named directory
+func hasArchiveEntry(files []*zip.File, name string) bool {
+	for _, file := range files {
+		if file.Name == name || (strings.HasSuffix(name, "/") && strings.HasPrefix(file.Name, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// tarMetadata lists the entries of a tar archive
+func tarMetadata(data []byte) (map[string]interface{}, error) {
+	reader := tar.NewReader(bytes.NewReader(data))
+
+	entries := []models.ArchiveEntry{}
+	total := 0
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Entries read before a truncated or corrupt header are still listed
+			return archiveMetadata(entries, total), err
+		}
+		total++
+		if len(entries) < maxArchiveEntries {
+			entries = append(entries, models.ArchiveEntry{
+				Name:     header.Name,
+				Modified: formatModified(header.ModTime),
+				Size:     header.Size,
+				Dir:      header.Typeflag == tar.TypeDir,
+			})
+		}
+	}
+
+	return archiveMetadata(entries, total), nil
+}
+
+// archiveMetadata describes the listed entries of an archive holding total entries
+func archiveMetadata(entries []models.ArchiveEntry, total int) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"entryCount": total,
+		"entries":    entries,
+	}
+	if total > len(entries) {
+		metadata["entriesTruncated"] = true
+	}
+	return metadata
+}
+
+// gzipMetadata reads the gzip header, which may carry the original file name and modification time
+func gzipMetadata(data []byte) (map[string]interface{}, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	metadata := map[string]interface{}{}
+	if reader.Name != "" {
+		metadata["name"] = reader.Name
+	}
+	if modified := formatModified(reader.ModTime); modified != "" {
+		metadata["modified"] = modified
+	}
+	if reader.Comment != "" {
+		metadata["comment"] = reader.Comment
+	}
+	return metadata, nil
+}
+
+// formatModified formats a modification time, leaving unset times empty
+func formatModified(modified time.Time) string {
+	if modified.IsZero() || modified.Unix() == 0 {
+		return ""
+	}
+	return modified.UTC().Format(time.RFC3339)
+}
+
+// isCompatibleMediaType reports whether a declared media type agrees with the detected one.
+// Generic binary types agree with any file type.
+func isCompatibleMediaType(declared, detected string) bool {
+	if declared == "application/octet-stream" || declared == "binary/octet-stream" {
+		return true
+	}
+	if alias, ok := mediaTypeAliases[declared]; ok {
+		declared = alias
+	}
+	if declared == detected {
+		return true
+	}
+
+	// Structured syntax suffixes name the container format
+	switch {
+	case strings.HasSuffix(declared, "+zip"):
+		return detected == "application/zip" || isZipContainer(detected)
+	case strings.HasSuffix(declared, "+gzip"):
+		return detected == "application/gzip"
+	}
+
+	// Documents stored as ZIP archives are ZIP archives too
+	return declared == "application/zip" && isZipContainer(detected)
+}
+
+// isZipContainer reports whether the media type is a file type stored as a ZIP archive
+func isZipContainer(mediaType string) bool {
+	for _, container := range zipContainers {
+		if container.mediaType == mediaType {
+			return true
+		}
+	}
+	return false
+}
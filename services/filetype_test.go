@@ -0,0 +1,252 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/ullbergm/echo-server/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodePNG encodes a blank PNG image of the given size
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// encodeZip writes a ZIP archive holding the named files
+func encodeZip(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, name := range names {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create ZIP entry: %v", err)
+		}
+		_, _ = file.Write([]byte("content of " + name))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to write ZIP archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectFileType_Images(t *testing.T) {
+	var jpegData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, image.NewGray(image.Rect(0, 0, 16, 9)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		mediaType string
+		data      []byte
+		width     int
+		height    int
+	}{
+		{"png", "image/png", encodePNG(t, 3, 2), 3, 2},
+		{"jpeg", "image/jpeg", jpegData.Bytes(), 16, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := DetectFileType(tt.data, "")
+			if info == nil || info.MediaType != tt.mediaType {
+				t.Fatalf("Expected %s, got %+v", tt.mediaType, info)
+			}
+			if info.Metadata["width"] != tt.width || info.Metadata["height"] != tt.height {
+				t.Errorf("Expected %dx%d, got %v", tt.width, tt.height, info.Metadata)
+			}
+		})
+	}
+}
+
+func TestDetectFileType_PDF(t *testing.T) {
+	pdf := []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n" +
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n" +
+		"2 0 obj << /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >> endobj\n" +
+		"3 0 obj << /Type /Page /Parent 2 0 R >> endobj\n" +
+		"4 0 obj << /Type/Page /Parent 2 0 R >> endobj\n%%EOF")
+
+	info := DetectFileType(pdf, "application/pdf")
+	if info == nil || info.MediaType != "application/pdf" || info.Mismatch {
+		t.Fatalf("Expected matching PDF, got %+v", info)
+	}
+	if info.Metadata["pages"] != 2 || info.Metadata["version"] != "1.7" {
+		t.Errorf("Unexpected PDF metadata %v", info.Metadata)
+	}
+}
+
+func TestDetectFileType_Archives(t *testing.T) {
+	t.Run("zip", func(t *testing.T) {
+		info := DetectFileType(encodeZip(t, "a.txt", "dir/b.txt"), "application/zip")
+		if info == nil || info.MediaType != "application/zip" || info.Mismatch {
+			t.Fatalf("Expected matching ZIP, got %+v", info)
+		}
+		entries, ok := info.Metadata["entries"].([]models.ArchiveEntry)
+		if !ok || len(entries) != 2 || entries[1].Name != "dir/b.txt" || entries[1].Size != int64(len("content of dir/b.txt")) {
+			t.Errorf("Unexpected ZIP entries %+v", info.Metadata["entries"])
+		}
+		if info.Metadata["entryCount"] != 2 {
+			t.Errorf("Expected entry count 2, got %v", info.Metadata["entryCount"])
+		}
+	})
+
+	t.Run("docx", func(t *testing.T) {
+		info := DetectFileType(encodeZip(t, "[Content_Types].xml", "word/document.xml"), "application/zip")
+		if info == nil || info.Extension != "docx" || info.Mismatch {
+			t.Errorf("Expected Word document compatible with application/zip, got %+v", info)
+		}
+	})
+
+	t.Run("tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := tar.NewWriter(&buf)
+		_ = writer.WriteHeader(&tar.Header{Name: "notes.txt", Mode: 0o600, Size: 5})
+		_, _ = writer.Write([]byte("notes"))
+		_ = writer.Close()
+
+		info := DetectFileType(buf.Bytes(), "application/x-tar")
+		if info == nil || info.MediaType != "application/x-tar" {
+			t.Fatalf("Expected tar archive, got %+v", info)
+		}
+		entries, ok := info.Metadata["entries"].([]models.ArchiveEntry)
+		if !ok || len(entries) != 1 || entries[0].Name != "notes.txt" || entries[0].Size != 5 {
+			t.Errorf("Unexpected tar entries %+v", info.Metadata["entries"])
+		}
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		writer.Name = "report.csv"
+		_, _ = writer.Write([]byte("a,b\n1,2\n"))
+		_ = writer.Close()
+
+		info := DetectFileType(buf.Bytes(), "application/x-gzip")
+		if info == nil || info.MediaType != "application/gzip" || info.Mismatch {
+			t.Fatalf("Expected matching gzip data, got %+v", info)
+		}
+		if info.Metadata["name"] != "report.csv" {
+			t.Errorf("Expected original file name, got %v", info.Metadata)
+		}
+	})
+}
+
+func TestDetectFileType_Signatures(t *testing.T) {
+	tarHeader := make([]byte, 512)
+	copy(tarHeader[257:], "ustar")
+
+	tests := map[string]string{
+		"GIF89a\x01\x00\x01\x00":               "image/gif",
+		"RIFF\x00\x00\x00\x00WEBPVP8 ":         "image/webp",
+		"RIFF\x00\x00\x00\x00WAVEfmt ":         "audio/wav",
+		"\x00asm\x01\x00\x00\x00":              "application/wasm",
+		"\x7fELF\x02\x01\x01":                  "application/x-elf",
+		"MZ\x90\x00\x03":                       "application/vnd.microsoft.portable-executable",
+		"\x1f\x8b\x08":                         "application/gzip",
+		"\x28\xb5\x2f\xfd\x00":                 "application/zstd",
+		"\x00\x00\x00\x18ftypmp42":             "video/mp4",
+		"SQLite format 3\x00\x10\x00":          "application/vnd.sqlite3",
+		string(tarHeader):                      "application/x-tar",
+		"\xcf\xfa\xed\xfe\x07\x00\x00\x01\x03": "application/x-mach-binary",
+	}
+
+	for data, mediaType := range tests {
+		if info := DetectFileType([]byte(data), ""); info == nil || info.MediaType != mediaType {
+			t.Errorf("Expected %s for %q, got %+v", mediaType, data[:min(len(data), 12)], info)
+		}
+	}
+}
+
+func TestDetectFileType_WASMVersion(t *testing.T) {
+	info := DetectFileType([]byte("\x00asm\x01\x00\x00\x00"), "application/wasm")
+	if info == nil || info.Metadata["version"] != uint32(1) || info.Mismatch {
+		t.Errorf("Expected WASM version 1, got %+v", info)
+	}
+}
+
+func TestDetectFileType_Protobuf(t *testing.T) {
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.VarintType)
+	data = protowire.AppendVarint(data, 150)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte{0x00, 0xff})
+
+	info := DetectFileType(data, "application/octet-stream")
+	if info == nil || info.MediaType != "application/x-protobuf" || info.Mismatch {
+		t.Errorf("Expected guessed protobuf, got %+v", info)
+	}
+}
+
+func TestDetectFileType_Unknown(t *testing.T) {
+	if info := DetectFileType([]byte{0xff, 0xff, 0xff, 0x00}, "application/octet-stream"); info != nil {
+		t.Errorf("Expected unknown data not to be detected, got %+v", info)
+	}
+}
+
+func TestIsCompatibleMediaType(t *testing.T) {
+	tests := []struct {
+		declared string
+		detected string
+		want     bool
+	}{
+		{"image/png", "image/png", true},
+		{"image/jpg", "image/jpeg", true},
+		{"application/octet-stream", "application/pdf", true},
+		{"application/x-zip-compressed", "application/zip", true},
+		{"application/epub+zip", "application/zip", true},
+		{"application/zip", "application/java-archive", true},
+		{"image/jpeg", "image/png", false},
+		{"application/pdf", "application/zip", false},
+		{"application/java-archive", "application/zip", false},
+	}
+
+	for _, tt := range tests {
+		if got := isCompatibleMediaType(tt.declared, tt.detected); got != tt.want {
+			t.Errorf("isCompatibleMediaType(%q, %q) = %t, want %t", tt.declared, tt.detected, got, tt.want)
+		}
+	}
+}
+
+func TestBodyService_ParseBodyFileTypeMismatch(t *testing.T) {
+	service := NewBodyService()
+
+	bodyInfo := service.ParseBody(encodePNG(t, 4, 4), "image/jpeg")
+	if !bodyInfo.IsBinary || bodyInfo.FileType == nil {
+		t.Fatalf("Expected binary body with file type, got %+v", bodyInfo)
+	}
+	if bodyInfo.FileType.MediaType != "image/png" || !bodyInfo.FileType.Mismatch || bodyInfo.FileType.DeclaredType != "image/jpeg" {
+		t.Errorf("Expected PNG mismatching image/jpeg, got %+v", bodyInfo.FileType)
+	}
+}
+
+func TestBodyService_MultipartPartFileType(t *testing.T) {
+	service := NewBodyService()
+
+	body := "--b\r\n" +
+		"Content-Disposition: form-data; name=\"avatar\"; filename=\"avatar.png\"\r\n" +
+		"Content-Type: image/png\r\n\r\n" +
+		string(encodePNG(t, 2, 2)) + "\r\n" +
+		"--b--\r\n"
+
+	bodyInfo := service.ParseBody([]byte(body), "multipart/form-data; boundary=b")
+	if len(bodyInfo.Parts) != 1 {
+		t.Fatalf("Expected one part, got %+v", bodyInfo.Parts)
+	}
+	fileType := bodyInfo.Parts[0].FileType
+	if fileType == nil || fileType.MediaType != "image/png" || fileType.Mismatch || fileType.Metadata["width"] != 2 {
+		t.Errorf("Expected matching PNG part, got %+v", fileType)
+	}
+}
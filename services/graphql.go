@@ -0,0 +1,537 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/kinds"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// MaxGraphQLDelay is the longest delay the delay mutation will honor
+	MaxGraphQLDelay = 30 * time.Second
+
+	// maxGraphQLQuerySize is the longest GraphQL document parsed
+	maxGraphQLQuerySize = 256 << 10
+
+	// maxGraphQLNestingDepth is the deepest nesting of braces, brackets and parentheses parsed,
+	// as the parser recurses into them without a limit
+	maxGraphQLNestingDepth = 100
+)
+
+// GraphQLRequest is a GraphQL-over-HTTP request
+type GraphQLRequest struct {
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLResponseControls collects response controls requested via mutations
+type GraphQLResponseControls struct {
+	Status int
+	Delay  time.Duration
+}
+
+type graphQLControlsKey struct{}
+
+// GraphQLService executes GraphQL queries against echo data
+type GraphQLService struct {
+	schema graphql.Schema
+}
+
+// NewGraphQLService creates a new GraphQL service with the echo schema
+func NewGraphQLService() (*GraphQLService, error) {
+	schema, err := buildEchoSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+
+	return &GraphQLService{
+		schema: schema,
+	}, nil
+}
+
+// Execute runs a GraphQL request with the echo response as root value.
+// Response controls set by mutations are returned alongside the result.
+func (s *GraphQLService) Execute(ctx context.Context, req GraphQLRequest, echo *models.EchoResponse) (*graphql.Result, *GraphQLResponseControls) {
+	controls := &GraphQLResponseControls{}
+	ctx = context.WithValue(ctx, graphQLControlsKey{}, controls)
+
+	if err := checkGraphQLDocument(req.Query); err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())}}, controls
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		RootObject:     map[string]interface{}{"echo": echo},
+		Context:        ctx,
+	})
+
+	return result, controls
+}
+
+// OperationType returns the type (query, mutation, subscription) of the operation
+// that would be executed for the request, or an empty string if it cannot be determined
+func OperationType(req GraphQLRequest) string {
+	doc, err := parseGraphQLDocument(req.Query)
+	if err != nil {
+		return ""
+	}
+
+	operation, selectErr := selectGraphQLOperation(doc, req.OperationName)
+	if selectErr != "" {
+		return ""
+	}
+	return operation.Operation
+}
+
+// parseGraphQLDocument parses a GraphQL document, refusing documents too long or too deeply nested to parse safely
+func parseGraphQLDocument(query string) (*ast.Document, error) {
+	if err := checkGraphQLDocument(query); err != nil {
+		return nil, err
+	}
+	return parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"}),
+	})
+}
+
+// checkGraphQLDocument returns an error when a GraphQL document is longer than maxGraphQLQuerySize
+// or nests braces, brackets and parentheses deeper than maxGraphQLNestingDepth.
+// Strings and comments are skipped.
+func checkGraphQLDocument(query string) error {
+	if len(query) > maxGraphQLQuerySize {
+		return fmt.Errorf("query is longer than %d bytes", maxGraphQLQuerySize)
+	}
+
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '{', '[', '(':
+			depth++
+			if depth > maxGraphQLNestingDepth {
+				return fmt.Errorf("query is nested deeper than %d levels", maxGraphQLNestingDepth)
+			}
+		case '}', ']', ')':
+			if depth > 0 {
+				depth--
+			}
+		case '#':
+			// Comments run to the end of the line
+			for i < len(query) && query[i] != '\n' && query[i] != '\r' {
+				i++
+			}
+		case '"':
+			if strings.HasPrefix(query[i:], `"""`) {
+				// Block strings end at the next unescaped """
+				i += 3
+				for i < len(query) && !strings.HasPrefix(query[i:], `"""`) {
+					if strings.HasPrefix(query[i:], `\"""`) {
+						i += 3
+					}
+					i++
+				}
+				i += 2
+				continue
+			}
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// selectGraphQLOperation picks the operation named in the request, or the only operation of the document
+func selectGraphQLOperation(doc *ast.Document, operationName string) (*ast.OperationDefinition, string) {
+	var operations []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		if operation, ok := def.(*ast.OperationDefinition); ok {
+			operations = append(operations, operation)
+		}
+	}
+
+	if len(operations) == 0 {
+		return nil, "document contains no operation"
+	}
+	if operationName == "" {
+		if len(operations) > 1 {
+			return nil, "operationName is required for a document with several operations"
+		}
+		return operations[0], ""
+	}
+	for _, operation := range operations {
+		if operation.Name != nil && operation.Name.Value == operationName {
+			return operation, ""
+		}
+	}
+	return nil, "unknown operation named \"" + operationName + "\""
+}
+
+// jsonScalar passes arbitrary JSON values through unchanged
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Arbitrary JSON value",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: parseJSONLiteral,
+})
+
+func parseJSONLiteral(valueAST ast.Value) interface{} {
+	switch valueAST.GetKind() {
+	case kinds.ObjectValue:
+		obj, _ := valueAST.(*ast.ObjectValue)
+		result := make(map[string]interface{}, len(obj.Fields))
+		for _, field := range obj.Fields {
+			result[field.Name.Value] = parseJSONLiteral(field.Value)
+		}
+		return result
+	case kinds.ListValue:
+		list, _ := valueAST.(*ast.ListValue)
+		result := make([]interface{}, 0, len(list.Values))
+		for _, v := range list.Values {
+			result = append(result, parseJSONLiteral(v))
+		}
+		return result
+	case kinds.IntValue, kinds.FloatValue:
+		return graphql.Float.ParseLiteral(valueAST)
+	case kinds.BooleanValue:
+		return graphql.Boolean.ParseLiteral(valueAST)
+	default:
+		return valueAST.GetValue()
+	}
+}
+
+// keyValue is the resolved shape of headers, environment variables and labels
+type keyValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// sortedKeyValues converts a map into a list sorted by name
+func sortedKeyValues(m map[string]string) []keyValue {
+	result := make([]keyValue, 0, len(m))
+	for name, value := range m {
+		result = append(result, keyValue{Name: name, Value: value})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// jwtToken is the resolved shape of a decoded JWT
+type jwtToken struct {
+	Header          map[string]interface{} `json:"header"`
+	Payload         map[string]interface{} `json:"payload"`
+	SignatureValid  *bool                  `json:"signatureValid"`
+	Source          string                 `json:"source"`
+	Raw             string                 `json:"rawToken"`
+	SignatureKeyID  string                 `json:"signatureKid"`
+	SignatureSource string                 `json:"signatureKeySource"`
+	SignatureError  string                 `json:"signatureError"`
+}
+
+func echoFromRoot(p graphql.ResolveParams) *models.EchoResponse {
+	root, ok := p.Info.RootValue.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	echo, _ := root["echo"].(*models.EchoResponse)
+	return echo
+}
+
+func controlsFromContext(ctx context.Context) *GraphQLResponseControls {
+	controls, _ := ctx.Value(graphQLControlsKey{}).(*GraphQLResponseControls)
+	return controls
+}
+
+// buildEchoSchema builds the GraphQL schema exposing the echo data
+func buildEchoSchema() (graphql.Schema, error) {
+	keyValueType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "KeyValue",
+		Description: "A name/value pair",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	cookieType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Cookie",
+		Description: "A request cookie",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	bodyType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Body",
+		Description: "The parsed request body",
+		Fields: graphql.Fields{
+			"contentType": &graphql.Field{Type: graphql.String},
+			"size":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"isBinary":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"truncated":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"content":     &graphql.Field{Type: jsonScalar},
+		},
+	})
+
+	requestType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Request",
+		Description: "The HTTP request carrying the GraphQL operation",
+		Fields: graphql.Fields{
+			"method":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"path":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"query":         &graphql.Field{Type: graphql.String},
+			"remoteAddress": &graphql.Field{Type: graphql.String},
+			"headers": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(keyValueType))),
+				Description: "Request headers, optionally filtered by case-insensitive name",
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req, _ := p.Source.(models.RequestInfo)
+					headers := sortedKeyValues(req.Headers)
+					name, ok := p.Args["name"].(string)
+					if !ok {
+						return headers, nil
+					}
+					filtered := []keyValue{}
+					for _, h := range headers {
+						if strings.EqualFold(h.Name, name) {
+							filtered = append(filtered, h)
+						}
+					}
+					return filtered, nil
+				},
+			},
+			"cookies": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(cookieType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req, _ := p.Source.(models.RequestInfo)
+					if req.Cookies == nil {
+						return []models.CookieInfo{}, nil
+					}
+					return req.Cookies, nil
+				},
+			},
+			"body": &graphql.Field{
+				Type: bodyType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req, _ := p.Source.(models.RequestInfo)
+					if req.Body == nil {
+						return nil, nil
+					}
+					return req.Body, nil
+				},
+			},
+		},
+	})
+
+	serverType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Server",
+		Description: "The server handling the request",
+		Fields: graphql.Fields{
+			"hostname":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"hostAddress": &graphql.Field{Type: graphql.String},
+			"environment": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(keyValueType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					server, _ := p.Source.(models.ServerInfo)
+					return sortedKeyValues(server.Environment), nil
+				},
+			},
+		},
+	})
+
+	kubernetesType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Kubernetes",
+		Description: "Kubernetes pod metadata",
+		Fields: graphql.Fields{
+			"namespace":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"podName":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"podIp":       &graphql.Field{Type: graphql.String},
+			"nodeName":    &graphql.Field{Type: graphql.String},
+			"serviceHost": &graphql.Field{Type: graphql.String},
+			"servicePort": &graphql.Field{Type: graphql.String},
+			"labels": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(keyValueType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					k8s, _ := p.Source.(*models.KubernetesInfo)
+					return sortedKeyValues(k8s.Labels), nil
+				},
+			},
+			"annotations": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(keyValueType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					k8s, _ := p.Source.(*models.KubernetesInfo)
+					return sortedKeyValues(k8s.Annotations), nil
+				},
+			},
+		},
+	})
+
+	jwtTokenType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "JwtToken",
+		Description: "A decoded JWT found in a request header",
+		Fields: graphql.Fields{
+			"source":   &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "Header the token was found in"},
+			"rawToken": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"header":   &graphql.Field{Type: jsonScalar},
+			"payload":  &graphql.Field{Type: jsonScalar},
+			"signatureValid": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Whether the signature was verified by a configured key, or null when no keys are configured",
+			},
+			"signatureKid":       &graphql.Field{Type: graphql.String, Description: "Key ID of the key that verified the signature"},
+			"signatureKeySource": &graphql.Field{Type: graphql.String, Description: "Where the key that verified the signature was configured"},
+			"signatureError":     &graphql.Field{Type: graphql.String, Description: "Why the signature could not be verified"},
+		},
+	})
+
+	responseControlsType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "ResponseControls",
+		Description: "Response controls applied to the HTTP response",
+		Fields: graphql.Fields{
+			"status": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					controls, _ := p.Source.(*GraphQLResponseControls)
+					if controls.Status == 0 {
+						return nil, nil
+					}
+					return controls.Status, nil
+				},
+			},
+			"delayMs": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					controls, _ := p.Source.(*GraphQLResponseControls)
+					return int(controls.Delay / time.Millisecond), nil
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"request": &graphql.Field{
+				Type: graphql.NewNonNull(requestType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return echoFromRoot(p).Request, nil
+				},
+			},
+			"server": &graphql.Field{
+				Type: graphql.NewNonNull(serverType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return echoFromRoot(p).Server, nil
+				},
+			},
+			"kubernetes": &graphql.Field{
+				Type: kubernetesType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if k8s := echoFromRoot(p).Kubernetes; k8s != nil {
+						return k8s, nil
+					}
+					return nil, nil
+				},
+			},
+			"jwtTokens": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(jwtTokenType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					tokens := []jwtToken{}
+					for source, info := range echoFromRoot(p).JwtTokens {
+						tokens = append(tokens, jwtToken{
+							Source:          source,
+							Raw:             info.RawToken,
+							Header:          info.Header,
+							Payload:         info.Payload,
+							SignatureValid:  info.SignatureValid,
+							SignatureKeyID:  info.SignatureKeyID,
+							SignatureSource: info.SignatureKeySource,
+							SignatureError:  info.SignatureError,
+						})
+					}
+					sort.Slice(tokens, func(i, j int) bool {
+						return tokens[i].Source < tokens[j].Source
+					})
+					return tokens, nil
+				},
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"setStatus": &graphql.Field{
+				Type:        graphql.NewNonNull(responseControlsType),
+				Description: "Set the HTTP status code of the response (200-599)",
+				Args: graphql.FieldConfigArgument{
+					"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					code, _ := p.Args["code"].(int)
+					if code < 200 || code > 599 {
+						return nil, fmt.Errorf("status code %d out of range 200-599", code)
+					}
+					controls := controlsFromContext(p.Context)
+					controls.Status = code
+					return controls, nil
+				},
+			},
+			"delay": &graphql.Field{
+				Type:        graphql.NewNonNull(responseControlsType),
+				Description: "Delay the response by the given number of milliseconds",
+				Args: graphql.FieldConfigArgument{
+					"milliseconds": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					ms, _ := p.Args["milliseconds"].(int)
+					delay := time.Duration(ms) * time.Millisecond
+					if delay < 0 || delay > MaxGraphQLDelay {
+						return nil, fmt.Errorf("delay %dms out of range 0-%d", ms, MaxGraphQLDelay.Milliseconds())
+					}
+
+					timer := time.NewTimer(delay)
+					defer timer.Stop()
+					select {
+					case <-timer.C:
+					case <-p.Context.Done():
+						return nil, p.Context.Err()
+					}
+
+					controls := controlsFromContext(p.Context)
+					controls.Delay += delay
+					return controls, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+func newTestGraphQLService(t *testing.T) *GraphQLService {
+	t.Helper()
+	service, err := NewGraphQLService()
+	if err != nil {
+		t.Fatalf("Failed to create GraphQL service: %v", err)
+	}
+	return service
+}
+
+func testEchoResponse() *models.EchoResponse {
+	return &models.EchoResponse{
+		Request: models.RequestInfo{
+			Method: "POST",
+			Path:   "/graphql",
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+				"X-Tenant":     "acme",
+			},
+			Cookies: []models.CookieInfo{{Name: "session", Value: "abc"}},
+			Body: &models.BodyInfo{
+				ContentType: "application/json",
+				Size:        2,
+				Content:     map[string]interface{}{"a": float64(1)},
+			},
+		},
+		Server: models.ServerInfo{
+			Hostname:    "test-host",
+			Environment: map[string]string{"HOSTNAME": "test-host"},
+		},
+		JwtTokens: map[string]models.JwtInfo{
+			"Authorization": {
+				RawToken: "eyJ...abc",
+				Header:   map[string]interface{}{"alg": "HS256"},
+				Payload:  map[string]interface{}{"sub": "user-1"},
+			},
+		},
+	}
+}
+
+func TestGraphQLService_QueryRequest(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	result, _ := service.Execute(context.Background(), GraphQLRequest{
+		Query: `{ request { method path headers(name: "x-tenant") { name value } cookies { name value } body { size content } } }`,
+	}, testEchoResponse())
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	data := result.Data.(map[string]interface{})
+	request := data["request"].(map[string]interface{})
+
+	if request["method"] != "POST" {
+		t.Errorf("Expected method POST, got %v", request["method"])
+	}
+
+	headers := request["headers"].([]interface{})
+	if len(headers) != 1 {
+		t.Fatalf("Expected 1 filtered header, got %d", len(headers))
+	}
+	if headers[0].(map[string]interface{})["value"] != "acme" {
+		t.Errorf("Expected header value acme, got %v", headers[0])
+	}
+
+	cookies := request["cookies"].([]interface{})
+	if len(cookies) != 1 {
+		t.Errorf("Expected 1 cookie, got %d", len(cookies))
+	}
+
+	body := request["body"].(map[string]interface{})
+	content := body["content"].(map[string]interface{})
+	if content["a"] != float64(1) {
+		t.Errorf("Expected body content to pass through, got %v", content)
+	}
+}
+
+func TestGraphQLService_ServerKubernetesAndJWT(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	result, _ := service.Execute(context.Background(), GraphQLRequest{
+		Query: `{ server { hostname environment { name value } } kubernetes { namespace } jwtTokens { source payload } }`,
+	}, testEchoResponse())
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	data := result.Data.(map[string]interface{})
+	if data["kubernetes"] != nil {
+		t.Errorf("Expected kubernetes to be null outside Kubernetes, got %v", data["kubernetes"])
+	}
+
+	tokens := data["jwtTokens"].([]interface{})
+	if len(tokens) != 1 {
+		t.Fatalf("Expected 1 JWT token, got %d", len(tokens))
+	}
+	token := tokens[0].(map[string]interface{})
+	if token["source"] != "Authorization" {
+		t.Errorf("Expected source Authorization, got %v", token["source"])
+	}
+	if token["payload"].(map[string]interface{})["sub"] != "user-1" {
+		t.Errorf("Expected payload sub user-1, got %v", token["payload"])
+	}
+}
+
+func TestGraphQLService_JWTSignature(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	echo := testEchoResponse()
+	valid := false
+	jwtInfo := echo.JwtTokens["Authorization"]
+	jwtInfo.SignatureValid = &valid
+	jwtInfo.SignatureError = "signature does not match any of 1 candidate keys"
+	echo.JwtTokens["Authorization"] = jwtInfo
+
+	result, _ := service.Execute(context.Background(), GraphQLRequest{
+		Query: `{ jwtTokens { signatureValid signatureKid signatureError } }`,
+	}, echo)
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	token := result.Data.(map[string]interface{})["jwtTokens"].([]interface{})[0].(map[string]interface{})
+	if token["signatureValid"] != false || token["signatureError"] != jwtInfo.SignatureError {
+		t.Errorf("Unexpected signature fields %v", token)
+	}
+}
+
+func TestGraphQLService_SetStatusMutation(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	result, controls := service.Execute(context.Background(), GraphQLRequest{
+		Query: `mutation { setStatus(code: 418) { status } }`,
+	}, testEchoResponse())
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	if controls.Status != 418 {
+		t.Errorf("Expected status control 418, got %d", controls.Status)
+	}
+}
+
+func TestGraphQLService_SetStatusOutOfRange(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	result, controls := service.Execute(context.Background(), GraphQLRequest{
+		Query: `mutation { setStatus(code: 99) { status } }`,
+	}, testEchoResponse())
+
+	if !result.HasErrors() {
+		t.Fatal("Expected error for out of range status")
+	}
+
+	if controls.Status != 0 {
+		t.Errorf("Expected status control to be unset, got %d", controls.Status)
+	}
+}
+
+func TestGraphQLService_DelayMutation(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	start := time.Now()
+	result, controls := service.Execute(context.Background(), GraphQLRequest{
+		Query:     `mutation Slow($ms: Int!) { delay(milliseconds: $ms) { delayMs } }`,
+		Variables: map[string]interface{}{"ms": 50},
+	}, testEchoResponse())
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("Expected execution to be delayed")
+	}
+
+	if controls.Delay != 50*time.Millisecond {
+		t.Errorf("Expected delay control 50ms, got %v", controls.Delay)
+	}
+}
+
+func TestGraphQLService_ValidationErrors(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	result, _ := service.Execute(context.Background(), GraphQLRequest{
+		Query: `{ request { doesNotExist } }`,
+	}, testEchoResponse())
+
+	if !result.HasErrors() {
+		t.Fatal("Expected validation error for unknown field")
+	}
+}
+
+func TestGraphQLService_Introspection(t *testing.T) {
+	service := newTestGraphQLService(t)
+
+	result, _ := service.Execute(context.Background(), GraphQLRequest{
+		Query: `{ __schema { queryType { name } mutationType { name } } }`,
+	}, testEchoResponse())
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	schema := result.Data.(map[string]interface{})["__schema"].(map[string]interface{})
+	if schema["mutationType"].(map[string]interface{})["name"] != "Mutation" {
+		t.Errorf("Expected mutation type Mutation, got %v", schema["mutationType"])
+	}
+}
+
+func TestOperationType(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      GraphQLRequest
+		expected string
+	}{
+		{"shorthand query", GraphQLRequest{Query: `{ server { hostname } }`}, "query"},
+		{"mutation", GraphQLRequest{Query: `mutation { setStatus(code: 201) { status } }`}, "mutation"},
+		{
+			"named operation",
+			GraphQLRequest{Query: `query A { server { hostname } } mutation B { setStatus(code: 201) { status } }`, OperationName: "B"},
+			"mutation",
+		},
+		{"syntax error", GraphQLRequest{Query: `{ server {`}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OperationType(tt.req); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckGraphQLDocument(t *testing.T) {
+	nested := strings.Repeat("{ a ", 101) + strings.Repeat("}", 101)
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{name: "simple query", query: "{ request { method } }"},
+		{name: "too deep", query: nested, wantErr: true},
+		{name: "unbalanced", query: strings.Repeat("[", 1000), wantErr: true},
+		{name: "too long", query: "{ a }" + strings.Repeat(" ", maxGraphQLQuerySize), wantErr: true},
+		{name: "braces in string", query: `{ a(b: "` + strings.Repeat("{", 200) + `\"") }`},
+		{name: "braces in block string", query: `{ a(b: """` + strings.Repeat("{", 200) + `\"""""") }`},
+		{name: "braces in comment", query: "{ a # " + strings.Repeat("{", 200) + "\n}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGraphQLDocument(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkGraphQLDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// OperationType reports no operation for documents refused by the guard
+	if got := OperationType(GraphQLRequest{Query: nested}); got != "" {
+		t.Errorf("Expected no operation type for a deeply nested query, got %q", got)
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// maxGraphQLSelectionDepth bounds how deep selections are walked. The parser itself is protected
+	// by checkGraphQLDocument, which refuses documents nested deeper than maxGraphQLNestingDepth.
+	maxGraphQLSelectionDepth = 100
+
+	// maxGraphQLComplexity bounds the fields counted and the selections visited, so fragments spreading
+	// each other many times cannot stall the walk, whether or not they reach a field
+	maxGraphQLComplexity = 100000
+)
+
+// describeGraphQLBody analyzes a GraphQL request sent as an application/graphql document or as
+// a JSON body with an automatic persisted query (APQ) extension or a query that looks like GraphQL:
+// one sent with variables or an operationName, or one starting with an operation. The result is nil
+// for other bodies, so JSON that merely has a "query" string is not parsed as GraphQL.
+func describeGraphQLBody(mediaType string, content interface{}) *models.GraphQLInfo {
+	if mediaType == "application/graphql" {
+		query, ok := content.(string)
+		if !ok {
+			return nil
+		}
+		return AnalyzeGraphQL(GraphQLRequest{Query: query}, nil)
+	}
+
+	body, ok := content.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	query, hasQuery := body["query"].(string)
+	persistedQuery := graphQLPersistedQuery(body["extensions"])
+	_, hasVariables := body["variables"]
+	_, hasOperationName := body["operationName"]
+	if persistedQuery == nil && (!hasQuery || !hasVariables && !hasOperationName && !startsWithGraphQLOperation(query)) {
+		return nil
+	}
+
+	req := GraphQLRequest{Query: query}
+	req.OperationName, _ = body["operationName"].(string)
+	req.Variables, _ = body["variables"].(map[string]interface{})
+	return AnalyzeGraphQL(req, persistedQuery)
+}
+
+// startsWithGraphQLOperation reports whether a query starts, after blanks and comments, with a
+// selection set or an operation or fragment keyword
+func startsWithGraphQLOperation(query string) bool {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n,\ufeff")
+		if !strings.HasPrefix(query, "#") {
+			break
+		}
+		_, query, _ = strings.Cut(query, "\n")
+	}
+	if strings.HasPrefix(query, "{") {
+		return true
+	}
+
+	keyword := strings.TrimLeft(query, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_0123456789")
+	switch query[:len(query)-len(keyword)] {
+	case "query", "mutation", "subscription", "fragment":
+		return true
+	}
+	return false
+}
+
+// graphQLPersistedQuery reads the persistedQuery extension of an automatic persisted query, or returns nil
+func graphQLPersistedQuery(extensions interface{}) *models.GraphQLPersistedQuery {
+	fields, ok := extensions.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	persisted, ok := fields["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	info := &models.GraphQLPersistedQuery{}
+	info.SHA256Hash, _ = persisted["sha256Hash"].(string)
+	if version, isNumber := persisted["version"].(float64); isNumber {
+		info.Version = int(version)
+	}
+	return info
+}
+
+// AnalyzeGraphQL parses the query of a GraphQL request and describes the operation that would be
+// executed: its type, name, root fields, depth and complexity. The persisted query, when given, is
+// checked against the hash of the query sent with it.
+func AnalyzeGraphQL(req GraphQLRequest, persistedQuery *models.GraphQLPersistedQuery) *models.GraphQLInfo {
+	info := &models.GraphQLInfo{
+		OperationName:  req.OperationName,
+		Variables:      req.Variables,
+		PersistedQuery: persistedQuery,
+	}
+
+	if persistedQuery != nil {
+		persistedQuery.QuerySent = req.Query != ""
+		if persistedQuery.QuerySent {
+			sum := sha256.Sum256([]byte(req.Query))
+			persistedQuery.HashMatch = strings.EqualFold(hex.EncodeToString(sum[:]), persistedQuery.SHA256Hash)
+		}
+	}
+	if req.Query == "" {
+		// A persisted query sent by hash alone has no document to analyze
+		return info
+	}
+
+	doc, err := parseGraphQLDocument(req.Query)
+	if err != nil {
+		info.Errors = []models.GraphQLError{graphQLSyntaxError(err)}
+		return info
+	}
+
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	operation, selectErr := selectGraphQLOperation(doc, req.OperationName)
+	if selectErr != "" {
+		info.Errors = []models.GraphQLError{{Message: selectErr}}
+		return info
+	}
+
+	info.OperationType = operation.Operation
+	if operation.Name != nil {
+		info.OperationName = operation.Name.Value
+	}
+	for _, variable := range operation.VariableDefinitions {
+		info.DeclaredVariables = append(info.DeclaredVariables, variable.Variable.Name.Value)
+	}
+
+	walker := &graphQLWalker{fragments: fragments, expanding: make(map[string]bool)}
+	info.RootFields = walker.rootFields(operation.SelectionSet)
+	info.Depth = walker.walk(operation.SelectionSet, 0)
+	info.Complexity = walker.fields
+
+	return info
+}
+
+// graphQLSyntaxError describes a parse error by its message and location
+func graphQLSyntaxError(err error) models.GraphQLError {
+	// The message is followed by an excerpt of the document highlighting the error
+	message, _, _ := strings.Cut(err.Error(), "\n")
+	syntaxErr := models.GraphQLError{Message: message}
+
+	var gqlErr *gqlerrors.Error
+	if errors.As(err, &gqlErr) && len(gqlErr.Locations) > 0 {
+		syntaxErr.Line = gqlErr.Locations[0].Line
+		syntaxErr.Column = gqlErr.Locations[0].Column
+	}
+	return syntaxErr
+}
+
+// graphQLWalker walks the selections of an operation, expanding fragment spreads
+type graphQLWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	// expanding holds the fragments being expanded on the current path, to skip fragment cycles
+	expanding map[string]bool
+	fields    int
+	visited   int
+}
+
+// rootFields returns the sorted names of the fields selected at the root of an operation
+func (w *graphQLWalker) rootFields(selectionSet *ast.SelectionSet) []string {
+	seen := make(map[string]bool)
+	// Each fragment adds the same fields wherever it is spread, so it is expanded once
+	expanded := make(map[string]bool)
+	var names []string
+	var collect func(*ast.SelectionSet)
+	collect = func(set *ast.SelectionSet) {
+		if set == nil {
+			return
+		}
+		for _, selection := range set.Selections {
+			switch node := selection.(type) {
+			case *ast.Field:
+				if !seen[node.Name.Value] {
+					seen[node.Name.Value] = true
+					names = append(names, node.Name.Value)
+				}
+			case *ast.InlineFragment:
+				collect(node.SelectionSet)
+			case *ast.FragmentSpread:
+				fragment := w.fragments[node.Name.Value]
+				if fragment == nil || expanded[node.Name.Value] {
+					continue
+				}
+				expanded[node.Name.Value] = true
+				collect(fragment.SelectionSet)
+			}
+		}
+	}
+	collect(selectionSet)
+	sort.Strings(names)
+	return names
+}
+
+// walk counts the fields of a selection set and returns its depth, the deepest nesting of fields in it.
+// The walk stops once maxGraphQLComplexity selections, including fragment spreads, have been visited.
+func (w *graphQLWalker) walk(selectionSet *ast.SelectionSet, depth int) int {
+	if selectionSet == nil || depth >= maxGraphQLSelectionDepth || w.visited >= maxGraphQLComplexity {
+		return depth
+	}
+
+	deepest := depth
+	for _, selection := range selectionSet.Selections {
+		if w.visited >= maxGraphQLComplexity {
+			break
+		}
+		w.visited++
+
+		var nested int
+		switch node := selection.(type) {
+		case *ast.Field:
+			w.fields++
+			nested = w.walk(node.SelectionSet, depth+1)
+		case *ast.InlineFragment:
+			nested = w.walk(node.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			fragment := w.fragments[node.Name.Value]
+			if fragment == nil || w.expanding[node.Name.Value] {
+				// Unknown fragments and fragment cycles add nothing
+				continue
+			}
+			w.expanding[node.Name.Value] = true
+			nested = w.walk(fragment.SelectionSet, depth)
+			delete(w.expanding, node.Name.Value)
+		}
+		deepest = max(deepest, nested)
+	}
+	return deepest
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyService_GraphQLDocument(t *testing.T) {
+	query := `query GetUser($id: ID!) {
+  user(id: $id) { name ...Friends }
+  viewer { id }
+}
+fragment Friends on User { friends { name } }`
+
+	bodyInfo := NewBodyService().ParseBody([]byte(query), "application/graphql")
+
+	info := bodyInfo.GraphQL
+	if info == nil {
+		t.Fatal("Expected GraphQL info")
+	}
+	if info.OperationType != "query" || info.OperationName != "GetUser" {
+		t.Errorf("Unexpected operation %s %s", info.OperationType, info.OperationName)
+	}
+	if !reflect.DeepEqual(info.RootFields, []string{"user", "viewer"}) {
+		t.Errorf("Unexpected root fields %v", info.RootFields)
+	}
+	if !reflect.DeepEqual(info.DeclaredVariables, []string{"id"}) {
+		t.Errorf("Unexpected declared variables %v", info.DeclaredVariables)
+	}
+	// user, name, friends, name, viewer, id
+	if info.Depth != 3 || info.Complexity != 6 {
+		t.Errorf("Expected depth 3 and complexity 6, got %d and %d", info.Depth, info.Complexity)
+	}
+	if len(info.Errors) != 0 {
+		t.Errorf("Unexpected errors %+v", info.Errors)
+	}
+}
+
+func TestBodyService_GraphQLJSONBody(t *testing.T) {
+	body := `{"query":"mutation A { a } mutation B { b { c } }","operationName":"B","variables":{"x":1}}`
+
+	info := NewBodyService().ParseBody([]byte(body), "application/json").GraphQL
+	if info == nil {
+		t.Fatal("Expected GraphQL info")
+	}
+	if info.OperationType != "mutation" || info.OperationName != "B" {
+		t.Errorf("Unexpected operation %s %s", info.OperationType, info.OperationName)
+	}
+	if !reflect.DeepEqual(info.RootFields, []string{"b"}) || info.Depth != 2 {
+		t.Errorf("Unexpected root fields %v or depth %d", info.RootFields, info.Depth)
+	}
+	if info.Variables["x"] != float64(1) {
+		t.Errorf("Expected variables to be reported, got %v", info.Variables)
+	}
+}
+
+func TestBodyService_GraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		message string
+		line    int
+	}{
+		{"syntax", `{"query":"query {\n  user(id: ) { name }\n}"}`, "Syntax Error", 2},
+		{"ambiguous", `{"query":"query A { a } query B { b }"}`, "operationName is required", 0},
+		{"unknown operation", `{"query":"query A { a }","operationName":"C"}`, `unknown operation named "C"`, 0},
+		{"no operation", `{"query":"fragment F on T { a }"}`, "no operation", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := NewBodyService().ParseBody([]byte(tt.body), "application/json").GraphQL
+			if info == nil || len(info.Errors) != 1 {
+				t.Fatalf("Expected one error, got %+v", info)
+			}
+			if !strings.Contains(info.Errors[0].Message, tt.message) || strings.Contains(info.Errors[0].Message, "\n") {
+				t.Errorf("Unexpected message %q", info.Errors[0].Message)
+			}
+			if info.Errors[0].Line != tt.line {
+				t.Errorf("Expected error on line %d, got %d", tt.line, info.Errors[0].Line)
+			}
+		})
+	}
+}
+
+func TestBodyService_GraphQLPersistedQuery(t *testing.T) {
+	query := "{ a }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+
+	t.Run("hash only", func(t *testing.T) {
+		body := `{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + hash + `"}}}`
+		info := NewBodyService().ParseBody([]byte(body), "application/json").GraphQL
+		if info == nil || info.PersistedQuery == nil {
+			t.Fatal("Expected persisted query info")
+		}
+		if info.PersistedQuery.QuerySent || info.PersistedQuery.Version != 1 || info.OperationType != "" {
+			t.Errorf("Unexpected info %+v %+v", info, info.PersistedQuery)
+		}
+	})
+
+	for _, sentHash := range []string{hash, strings.Repeat("0", 64)} {
+		body := `{"query":"` + query + `","extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + sentHash + `"}}}`
+		info := NewBodyService().ParseBody([]byte(body), "application/json").GraphQL
+		if info == nil || info.PersistedQuery == nil || !info.PersistedQuery.QuerySent {
+			t.Fatalf("Expected persisted query with query, got %+v", info)
+		}
+		if want := sentHash == hash; info.PersistedQuery.HashMatch != want {
+			t.Errorf("Expected hash match %t for %s", want, sentHash)
+		}
+	}
+}
+
+func TestBodyService_GraphQLFragmentExpansionBounded(t *testing.T) {
+	// Each fragment spreads the next twice, doubling the fields at every level
+	var doc strings.Builder
+	doc.WriteString("{ ...F0 }\n")
+	for i := 0; i < 40; i++ {
+		doc.WriteString("fragment F" + strconv.Itoa(i) + " on T { a ...F" + strconv.Itoa(i+1) + " ...F" + strconv.Itoa(i+1) + " }\n")
+	}
+	doc.WriteString("fragment F40 on T { a }\n")
+	// Spreading a fragment inside itself is reported without looping
+	doc.WriteString("fragment Self on T { ...Self }\n")
+
+	info := AnalyzeGraphQL(GraphQLRequest{Query: doc.String()}, nil)
+	// Spreads count toward the limit, so the walk stops before counting maxGraphQLComplexity fields
+	if info.Complexity == 0 || info.Complexity >= maxGraphQLComplexity {
+		t.Errorf("Expected complexity to stop short of %d, got %d", maxGraphQLComplexity, info.Complexity)
+	}
+	if !reflect.DeepEqual(info.RootFields, []string{"a"}) {
+		t.Errorf("Unexpected root fields %v", info.RootFields)
+	}
+}
+
+func TestBodyService_GraphQLSpreadChainBounded(t *testing.T) {
+	// Each fragment spreads the next twice without selecting a field, and the last spreads an unknown fragment
+	var doc strings.Builder
+	doc.WriteString("query { ...F0 }\n")
+	for i := 0; i < 30; i++ {
+		doc.WriteString("fragment F" + strconv.Itoa(i) + " on Query { ...F" + strconv.Itoa(i+1) + " ...F" + strconv.Itoa(i+1) + " }\n")
+	}
+	doc.WriteString("fragment F30 on Query { ...Missing }\n")
+
+	start := time.Now()
+	info := NewBodyService().ParseBody([]byte(doc.String()), "application/graphql").GraphQL
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the walk to be bounded, took %v", elapsed)
+	}
+	if info == nil || info.Complexity != 0 || info.Depth != 0 {
+		t.Errorf("Expected no fields, got %+v", info)
+	}
+}
+
+func TestBodyService_NotGraphQL(t *testing.T) {
+	for _, body := range []string{
+		`{"query":{"match_all":{}}}`,
+		`{"name":"x"}`,
+		`[1,2]`,
+		`{"query":"SELECT * FROM users"}`,
+		`{"query":"queryString"}`,
+	} {
+		if info := NewBodyService().ParseBody([]byte(body), "application/json").GraphQL; info != nil {
+			t.Errorf("Expected no GraphQL info for %s, got %+v", body, info)
+		}
+	}
+}
+
+func TestBodyService_GraphQLDetection(t *testing.T) {
+	for _, body := range []string{
+		`{"query":"  # comment\n query { a }"}`,
+		`{"query":"mutation{ a }"}`,
+		`{"query":"a","variables":null}`,
+		`{"query":"a","operationName":"A"}`,
+	} {
+		if info := NewBodyService().ParseBody([]byte(body), "application/json").GraphQL; info == nil {
+			t.Errorf("Expected GraphQL info for %s", body)
+		}
+	}
+}
+
+func TestBodyService_GraphQLDeeplyNested(t *testing.T) {
+	bodies := map[string]string{
+		"application/graphql": strings.Repeat("{", 100000),
+		"application/json":    `{"query":"` + strings.Repeat("{a", 100000) + `","variables":{}}`,
+	}
+
+	for contentType, body := range bodies {
+		info := NewBodyService().ParseBody([]byte(body), contentType).GraphQL
+		if info == nil || len(info.Errors) != 1 {
+			t.Fatalf("Expected one error for %s, got %+v", contentType, info)
+		}
+		if !strings.Contains(info.Errors[0].Message, "nested deeper") {
+			t.Errorf("Unexpected message %q", info.Errors[0].Message)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+	JSONRPCServerError    = -32000
+
+	jsonRPCVersion = "2.0"
+)
+
+// jsonRPCErrorMethods maps the deliberate error methods to their error codes
+var jsonRPCErrorMethods = map[string]int{
+	"error.parseError":     JSONRPCParseError,
+	"error.invalidRequest": JSONRPCInvalidRequest,
+	"error.methodNotFound": JSONRPCMethodNotFound,
+	"error.invalidParams":  JSONRPCInvalidParams,
+	"error.internalError":  JSONRPCInternalError,
+	"error.serverError":    JSONRPCServerError,
+}
+
+// jsonRPCErrorMessages are the standard messages for JSON-RPC error codes
+var jsonRPCErrorMessages = map[int]string{
+	JSONRPCParseError:     "Parse error",
+	JSONRPCInvalidRequest: "Invalid Request",
+	JSONRPCMethodNotFound: "Method not found",
+	JSONRPCInvalidParams:  "Invalid params",
+	JSONRPCInternalError:  "Internal error",
+	JSONRPCServerError:    "Server error",
+}
+
+// JSONRPCError is a JSON-RPC 2.0 error object
+type JSONRPCError struct {
+	Data    interface{} `json:"data,omitempty"`
+	Message string      `json:"message"`
+	Code    int         `json:"code"`
+}
+
+// JSONRPCResponse is a JSON-RPC 2.0 response object
+type JSONRPCResponse struct {
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// JSONRPCEchoResult is the result of the echo method
+type JSONRPCEchoResult struct {
+	Params    interface{} `json:"params,omitempty"`
+	Request   interface{} `json:"request,omitempty"`
+	ID        interface{} `json:"id"`
+	Method    string      `json:"method"`
+	Transport string      `json:"transport"`
+}
+
+// JSONRPCService implements a JSON-RPC 2.0 echo service
+type JSONRPCService struct{}
+
+// NewJSONRPCService creates a new JSON-RPC service
+func NewJSONRPCService() *JSONRPCService {
+	return &JSONRPCService{}
+}
+
+// Handle processes a single or batch JSON-RPC payload.
+// The returned bytes are nil when no response must be sent (notifications only).
+// request is included in echo results as request metadata and transport names the
+// transport the call arrived on (http or websocket).
+func (s *JSONRPCService) Handle(payload []byte, request interface{}, transport string) []byte {
+	trimmed := bytes.TrimSpace(payload)
+
+	var raw interface{}
+	if err := json.Unmarshal(trimmed, &raw); err != nil {
+		return s.marshal(newJSONRPCError(nil, JSONRPCParseError, err.Error()))
+	}
+
+	// Batch request
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return s.marshal(newJSONRPCError(nil, JSONRPCParseError, err.Error()))
+		}
+		if len(batch) == 0 {
+			return s.marshal(newJSONRPCError(nil, JSONRPCInvalidRequest, "empty batch"))
+		}
+
+		responses := make([]*JSONRPCResponse, 0, len(batch))
+		for _, call := range batch {
+			if resp := s.handleCall(call, request, transport); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return s.marshal(responses)
+	}
+
+	resp := s.handleCall(trimmed, request, transport)
+	if resp == nil {
+		return nil
+	}
+	return s.marshal(resp)
+}
+
+// handleCall processes a single call, returning nil for notifications
+func (s *JSONRPCService) handleCall(call json.RawMessage, request interface{}, transport string) *JSONRPCResponse {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(call, &members); err != nil {
+		return newJSONRPCError(nil, JSONRPCInvalidRequest, "request must be an object")
+	}
+
+	id, hasID := members["id"]
+	if hasID && !isValidJSONRPCID(id) {
+		return newJSONRPCError(nil, JSONRPCInvalidRequest, "id must be a string, number or null")
+	}
+
+	var version string
+	if err := json.Unmarshal(members["jsonrpc"], &version); err != nil || version != jsonRPCVersion {
+		return newJSONRPCError(id, JSONRPCInvalidRequest, `jsonrpc must be exactly "2.0"`)
+	}
+
+	var method string
+	if err := json.Unmarshal(members["method"], &method); err != nil || method == "" {
+		return newJSONRPCError(id, JSONRPCInvalidRequest, "method must be a non-empty string")
+	}
+
+	var params interface{}
+	if rawParams, ok := members["params"]; ok {
+		trimmedParams := bytes.TrimSpace(rawParams)
+		if len(trimmedParams) == 0 || (trimmedParams[0] != '{' && trimmedParams[0] != '[') {
+			return newJSONRPCError(id, JSONRPCInvalidRequest, "params must be an object or array")
+		}
+		if err := json.Unmarshal(trimmedParams, &params); err != nil {
+			return newJSONRPCError(id, JSONRPCInvalidRequest, err.Error())
+		}
+	}
+
+	result, rpcErr := s.dispatch(method, params, id, request, transport)
+
+	// Notifications never receive a response, not even errors
+	if !hasID {
+		return nil
+	}
+
+	if rpcErr != nil {
+		return &JSONRPCResponse{JSONRPC: jsonRPCVersion, Error: rpcErr, ID: id}
+	}
+	return &JSONRPCResponse{JSONRPC: jsonRPCVersion, Result: result, ID: id}
+}
+
+// dispatch invokes a method
+func (s *JSONRPCService) dispatch(method string, params interface{}, id json.RawMessage, request interface{}, transport string) (interface{}, *JSONRPCError) {
+	switch {
+	case method == "echo":
+		var decodedID interface{}
+		if len(id) > 0 {
+			_ = json.Unmarshal(id, &decodedID)
+		}
+		return JSONRPCEchoResult{
+			Method:    method,
+			Params:    params,
+			ID:        decodedID,
+			Transport: transport,
+			Request:   request,
+		}, nil
+	case method == "error":
+		return nil, errorFromParams(params)
+	case strings.HasPrefix(method, "error."):
+		code, ok := jsonRPCErrorMethods[method]
+		if !ok {
+			break
+		}
+		return nil, &JSONRPCError{
+			Code:    code,
+			Message: jsonRPCErrorMessages[code],
+			Data:    params,
+		}
+	}
+
+	return nil, &JSONRPCError{
+		Code:    JSONRPCMethodNotFound,
+		Message: jsonRPCErrorMessages[JSONRPCMethodNotFound],
+		Data:    fmt.Sprintf("method %q does not exist", method),
+	}
+}
+
+// errorFromParams builds the error requested by the error method: {"code": int, "message": string, "data": any}
+func errorFromParams(params interface{}) *JSONRPCError {
+	obj, ok := params.(map[string]interface{})
+	if !ok {
+		return &JSONRPCError{
+			Code:    JSONRPCInvalidParams,
+			Message: jsonRPCErrorMessages[JSONRPCInvalidParams],
+			Data:    "params must be an object with an integer code",
+		}
+	}
+
+	code, ok := obj["code"].(float64)
+	if !ok || code != float64(int(code)) {
+		return &JSONRPCError{
+			Code:    JSONRPCInvalidParams,
+			Message: jsonRPCErrorMessages[JSONRPCInvalidParams],
+			Data:    "code must be an integer",
+		}
+	}
+
+	rpcErr := &JSONRPCError{
+		Code:    int(code),
+		Data:    obj["data"],
+		Message: "Application error",
+	}
+	if message, isString := obj["message"].(string); isString && message != "" {
+		rpcErr.Message = message
+	} else if standard, known := jsonRPCErrorMessages[rpcErr.Code]; known {
+		rpcErr.Message = standard
+	}
+
+	return rpcErr
+}
+
+// isValidJSONRPCID checks that an id is a string, number or null
+func isValidJSONRPCID(id json.RawMessage) bool {
+	var value interface{}
+	if err := json.Unmarshal(id, &value); err != nil {
+		return false
+	}
+	switch value.(type) {
+	case nil, string, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// newJSONRPCError builds an error response
+func newJSONRPCError(id json.RawMessage, code int, data string) *JSONRPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &JSONRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		ID:      id,
+		Error: &JSONRPCError{
+			Code:    code,
+			Message: jsonRPCErrorMessages[code],
+			Data:    data,
+		},
+	}
+}
+
+// marshal encodes a response, falling back to an internal error
+func (s *JSONRPCService) marshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(newJSONRPCError(nil, JSONRPCInternalError, err.Error()))
+	}
+	return data
+}
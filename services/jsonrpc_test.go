@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodeJSONRPCResponse(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	var resp map[string]interface{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("Failed to decode response %s: %v", data, err)
+	}
+	return resp
+}
+
+func jsonRPCErrorCode(t *testing.T, resp map[string]interface{}) int {
+	t.Helper()
+	errObj, ok := resp["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected error object, got %v", resp)
+	}
+	return int(errObj["code"].(float64))
+}
+
+func TestJSONRPCService_Echo(t *testing.T) {
+	service := NewJSONRPCService()
+
+	out := service.Handle([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"a":1},"id":7}`), map[string]string{"path": "/jsonrpc"}, "http")
+	resp := decodeJSONRPCResponse(t, out)
+
+	if resp["jsonrpc"] != "2.0" {
+		t.Errorf("Expected jsonrpc 2.0, got %v", resp["jsonrpc"])
+	}
+	if resp["id"] != float64(7) {
+		t.Errorf("Expected id 7, got %v", resp["id"])
+	}
+	if _, hasError := resp["error"]; hasError {
+		t.Fatalf("Unexpected error: %v", resp["error"])
+	}
+
+	result := resp["result"].(map[string]interface{})
+	if result["params"].(map[string]interface{})["a"] != float64(1) {
+		t.Errorf("Expected params to be echoed, got %v", result["params"])
+	}
+	if result["transport"] != "http" {
+		t.Errorf("Expected transport http, got %v", result["transport"])
+	}
+	if result["request"].(map[string]interface{})["path"] != "/jsonrpc" {
+		t.Errorf("Expected request metadata, got %v", result["request"])
+	}
+}
+
+func TestJSONRPCService_StringID(t *testing.T) {
+	service := NewJSONRPCService()
+
+	resp := decodeJSONRPCResponse(t, service.Handle([]byte(`{"jsonrpc":"2.0","method":"echo","id":"abc"}`), nil, "http"))
+	if resp["id"] != "abc" {
+		t.Errorf("Expected id abc, got %v", resp["id"])
+	}
+}
+
+func TestJSONRPCService_Notification(t *testing.T) {
+	service := NewJSONRPCService()
+
+	if out := service.Handle([]byte(`{"jsonrpc":"2.0","method":"echo","params":[1]}`), nil, "http"); out != nil {
+		t.Errorf("Expected no response for notification, got %s", out)
+	}
+
+	// Errors raised by notifications are not reported either
+	if out := service.Handle([]byte(`{"jsonrpc":"2.0","method":"nope"}`), nil, "http"); out != nil {
+		t.Errorf("Expected no response for failing notification, got %s", out)
+	}
+}
+
+func TestJSONRPCService_ParseError(t *testing.T) {
+	service := NewJSONRPCService()
+
+	resp := decodeJSONRPCResponse(t, service.Handle([]byte(`{"jsonrpc":"2.0","method":`), nil, "http"))
+	if code := jsonRPCErrorCode(t, resp); code != JSONRPCParseError {
+		t.Errorf("Expected code %d, got %d", JSONRPCParseError, code)
+	}
+	if resp["id"] != nil {
+		t.Errorf("Expected null id, got %v", resp["id"])
+	}
+}
+
+func TestJSONRPCService_InvalidRequests(t *testing.T) {
+	service := NewJSONRPCService()
+
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"not an object", `1`},
+		{"missing jsonrpc", `{"method":"echo","id":1}`},
+		{"wrong version", `{"jsonrpc":"1.0","method":"echo","id":1}`},
+		{"missing method", `{"jsonrpc":"2.0","id":1}`},
+		{"numeric method", `{"jsonrpc":"2.0","method":1,"id":1}`},
+		{"scalar params", `{"jsonrpc":"2.0","method":"echo","params":"x","id":1}`},
+		{"object id", `{"jsonrpc":"2.0","method":"echo","id":{}}`},
+		{"empty batch", `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := decodeJSONRPCResponse(t, service.Handle([]byte(tt.payload), nil, "http"))
+			if code := jsonRPCErrorCode(t, resp); code != JSONRPCInvalidRequest {
+				t.Errorf("Expected code %d, got %d", JSONRPCInvalidRequest, code)
+			}
+		})
+	}
+}
+
+func TestJSONRPCService_MethodNotFound(t *testing.T) {
+	service := NewJSONRPCService()
+
+	resp := decodeJSONRPCResponse(t, service.Handle([]byte(`{"jsonrpc":"2.0","method":"missing","id":1}`), nil, "http"))
+	if code := jsonRPCErrorCode(t, resp); code != JSONRPCMethodNotFound {
+		t.Errorf("Expected code %d, got %d", JSONRPCMethodNotFound, code)
+	}
+}
+
+func TestJSONRPCService_DeliberateErrors(t *testing.T) {
+	service := NewJSONRPCService()
+
+	tests := []struct {
+		payload      string
+		expectedMsg  string
+		expectedCode int
+	}{
+		{`{"jsonrpc":"2.0","method":"error.internalError","id":1}`, "Internal error", JSONRPCInternalError},
+		{`{"jsonrpc":"2.0","method":"error.invalidParams","id":1}`, "Invalid params", JSONRPCInvalidParams},
+		{`{"jsonrpc":"2.0","method":"error.serverError","id":1}`, "Server error", JSONRPCServerError},
+		{`{"jsonrpc":"2.0","method":"error","params":{"code":-32099,"message":"rate limited","data":{"retry":5}},"id":1}`, "rate limited", -32099},
+		{`{"jsonrpc":"2.0","method":"error","params":{"code":-32602},"id":1}`, "Invalid params", JSONRPCInvalidParams},
+		{`{"jsonrpc":"2.0","method":"error","params":{"code":42},"id":1}`, "Application error", 42},
+		{`{"jsonrpc":"2.0","method":"error","params":[1],"id":1}`, "Invalid params", JSONRPCInvalidParams},
+		{`{"jsonrpc":"2.0","method":"error","params":{"code":1.5},"id":1}`, "Invalid params", JSONRPCInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			resp := decodeJSONRPCResponse(t, service.Handle([]byte(tt.payload), nil, "http"))
+			if code := jsonRPCErrorCode(t, resp); code != tt.expectedCode {
+				t.Errorf("Expected code %d, got %d", tt.expectedCode, code)
+			}
+			if msg := resp["error"].(map[string]interface{})["message"]; msg != tt.expectedMsg {
+				t.Errorf("Expected message %q, got %v", tt.expectedMsg, msg)
+			}
+		})
+	}
+}
+
+func TestJSONRPCService_Batch(t *testing.T) {
+	service := NewJSONRPCService()
+
+	payload := `[
+		{"jsonrpc":"2.0","method":"echo","params":[1],"id":1},
+		{"jsonrpc":"2.0","method":"echo","params":[2]},
+		{"jsonrpc":"2.0","method":"missing","id":"x"},
+		1
+	]`
+
+	var responses []map[string]interface{}
+	if err := json.Unmarshal(service.Handle([]byte(payload), nil, "http"), &responses); err != nil {
+		t.Fatalf("Expected batch response array: %v", err)
+	}
+
+	if len(responses) != 3 {
+		t.Fatalf("Expected 3 responses (notification omitted), got %d", len(responses))
+	}
+
+	if responses[0]["id"] != float64(1) {
+		t.Errorf("Expected first response id 1, got %v", responses[0]["id"])
+	}
+	if code := jsonRPCErrorCode(t, responses[1]); code != JSONRPCMethodNotFound {
+		t.Errorf("Expected method not found, got %d", code)
+	}
+	if code := jsonRPCErrorCode(t, responses[2]); code != JSONRPCInvalidRequest {
+		t.Errorf("Expected invalid request, got %d", code)
+	}
+}
+
+func TestJSONRPCService_BatchOfNotifications(t *testing.T) {
+	service := NewJSONRPCService()
+
+	out := service.Handle([]byte(`[{"jsonrpc":"2.0","method":"echo"},{"jsonrpc":"2.0","method":"echo"}]`), nil, "http")
+	if out != nil {
+		t.Errorf("Expected no response for batch of notifications, got %s", out)
+	}
+}
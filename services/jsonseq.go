@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// maxJSONSequenceRecords is the largest number of records of a JSON sequence included in the parsed content
+	maxJSONSequenceRecords = 10000
+
+	// recordSeparator starts each record of a JSON text sequence (RFC 7464)
+	recordSeparator = 0x1E
+)
+
+// jsonSequenceFormats maps the media types of JSON sequences to their formats
+var jsonSequenceFormats = map[string]string{
+	"application/x-ndjson":  "ndjson",
+	"application/jsonl":     "ndjson",
+	"application/jsonlines": "ndjson",
+	"application/json-seq":  "json-seq",
+}
+
+// parseJSONSequence parses newline-delimited JSON or a JSON text sequence into an array of records.
+// Records that fail to parse are kept as strings and reported in the errors. Records beyond
+// maxJSONSequenceRecords are counted but dropped unparsed, which is reported by the third return value.
+func (s *BodyService) parseJSONSequence(data []byte, format string) ([]interface{}, *models.JSONSequenceInfo, bool) {
+	separator := byte(charNewline)
+	if format == "json-seq" {
+		separator = recordSeparator
+	}
+
+	info := &models.JSONSequenceInfo{Format: format}
+	records := []interface{}{}
+	for i, chunk := range bytes.Split(data, []byte{separator}) {
+		// Blank lines between NDJSON records and the empty text before the first JSON text sequence record are skipped
+		chunk = bytes.TrimSpace(chunk)
+		if len(chunk) == 0 {
+			continue
+		}
+		info.Records++
+		if len(records) == maxJSONSequenceRecords {
+			continue
+		}
+
+		var record interface{}
+		if err := json.Unmarshal(chunk, &record); err != nil {
+			// NDJSON records are located by line, JSON text sequence records by their position
+			position := i + 1
+			if format == "json-seq" {
+				position = info.Records
+			}
+			info.Errors = append(info.Errors, models.JSONRecordError{Record: position, Message: err.Error()})
+			record = string(chunk)
+		}
+		records = append(records, record)
+	}
+
+	return records, info, info.Records > len(records)
+}
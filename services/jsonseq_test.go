@@ -0,0 +1,96 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBodyService_ParseNDJSON(t *testing.T) {
+	service := NewBodyService()
+
+	body := []byte("{\"level\":\"info\",\"msg\":\"started\"}\r\n\n{\"level\":\"warn\"\n[1,2]\n")
+
+	for _, contentType := range []string{"application/x-ndjson", "application/jsonl", "application/x-ndjson; charset=utf-8"} {
+		t.Run(contentType, func(t *testing.T) {
+			bodyInfo := service.ParseBody(body, contentType)
+
+			records, ok := bodyInfo.Content.([]interface{})
+			if !ok || len(records) != 3 {
+				t.Fatalf("Expected three records, got %v", bodyInfo.Content)
+			}
+			if first, isMap := records[0].(map[string]interface{}); !isMap || first["msg"] != "started" {
+				t.Errorf("Expected first record to be parsed, got %v", records[0])
+			}
+			if records[1] != `{"level":"warn"` {
+				t.Errorf("Expected invalid record to be kept as a string, got %v", records[1])
+			}
+
+			info := bodyInfo.JSONSequence
+			if info == nil || info.Format != "ndjson" || info.Records != 3 {
+				t.Fatalf("Unexpected sequence info %+v", info)
+			}
+			if len(info.Errors) != 1 || info.Errors[0].Record != 3 {
+				t.Errorf("Expected an error on line 3, got %+v", info.Errors)
+			}
+		})
+	}
+}
+
+func TestBodyService_ParseJSONTextSequence(t *testing.T) {
+	body := []byte("\x1e{\"id\":1}\n\x1e2\n\x1e{\"id\":\n\x1e\"three\"\n")
+
+	bodyInfo := NewBodyService().ParseBody(body, "application/json-seq")
+
+	records, ok := bodyInfo.Content.([]interface{})
+	if !ok || len(records) != 4 {
+		t.Fatalf("Expected four records, got %v", bodyInfo.Content)
+	}
+	if records[1] != float64(2) || records[3] != "three" {
+		t.Errorf("Expected scalar records to be parsed, got %v", records)
+	}
+	if bodyInfo.IsBinary {
+		t.Error("Expected record separators not to mark the body as binary")
+	}
+
+	info := bodyInfo.JSONSequence
+	if info == nil || info.Format != "json-seq" || info.Records != 4 {
+		t.Fatalf("Unexpected sequence info %+v", info)
+	}
+	if len(info.Errors) != 1 || info.Errors[0].Record != 3 {
+		t.Errorf("Expected an error on record 3, got %+v", info.Errors)
+	}
+}
+
+func TestBodyService_ParseJSONSequenceRecordLimit(t *testing.T) {
+	body := strings.Repeat("{}\n", maxJSONSequenceRecords+5)
+
+	bodyInfo := NewBodyService().ParseBody([]byte(body), "application/x-ndjson")
+
+	if records, ok := bodyInfo.Content.([]interface{}); !ok || len(records) != maxJSONSequenceRecords {
+		t.Fatalf("Expected %d records", maxJSONSequenceRecords)
+	}
+	if !bodyInfo.Truncated {
+		t.Error("Expected body to be marked as truncated")
+	}
+	// Records past the limit are still counted
+	if bodyInfo.JSONSequence == nil || bodyInfo.JSONSequence.Records != maxJSONSequenceRecords+5 {
+		t.Errorf("Expected %d records to be counted, got %+v", maxJSONSequenceRecords+5, bodyInfo.JSONSequence)
+	}
+
+	// A sequence of exactly the limit is kept whole
+	bodyInfo = NewBodyService().ParseBody([]byte(strings.Repeat("{}\n", maxJSONSequenceRecords)), "application/x-ndjson")
+	if bodyInfo.Truncated || bodyInfo.JSONSequence.Records != maxJSONSequenceRecords {
+		t.Errorf("Expected %d records without truncation, got %+v", maxJSONSequenceRecords, bodyInfo.JSONSequence)
+	}
+}
+
+func TestBodyService_ParseJSONSequenceEmpty(t *testing.T) {
+	bodyInfo := NewBodyService().ParseBody([]byte("\n\n"), "application/x-ndjson")
+
+	if records, ok := bodyInfo.Content.([]interface{}); !ok || len(records) != 0 {
+		t.Errorf("Expected no records, got %v", bodyInfo.Content)
+	}
+	if bodyInfo.JSONSequence == nil || bodyInfo.JSONSequence.Records != 0 {
+		t.Errorf("Unexpected sequence info %+v", bodyInfo.JSONSequence)
+	}
+}
@@ -9,8 +9,9 @@ import (
 	"github.com/ullbergm/echo-server/models"
 )
 
-// JWTService handles JWT token decoding
+// JWTService handles JWT token decoding and, with a verifier set, signature verification
 type JWTService struct {
+	verifier    *JWTVerifier
 	headerNames []string
 }
 
@@ -92,6 +93,10 @@ func (s *JWTService) decodeJWT(token string) *models.JwtInfo {
 		return nil
 	}
 
+	if s.verifier != nil && s.verifier.Enabled() {
+		s.verifier.Verify(token, jwtInfo)
+	}
+
 	return jwtInfo
 }
 
@@ -0,0 +1,509 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ullbergm/echo-server/models"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jwksRefreshInterval is how long keys fetched from JWKS URLs are used before being fetched again
+	jwksRefreshInterval = 5 * time.Minute
+
+	// jwksMinRefreshInterval is how soon JWKS URLs may be fetched again for a token with an unknown kid
+	jwksMinRefreshInterval = 30 * time.Second
+
+	// maxJWKSSize is the largest JWKS document read from a URL
+	maxJWKSSize = 1 << 20
+)
+
+// jwtAlgorithm describes how a JWS algorithm signs tokens
+type jwtAlgorithm struct {
+	curve  elliptic.Curve
+	family string
+	hash   crypto.Hash
+}
+
+// jwtAlgorithms are the supported JWS algorithms (RFC 7518, RFC 8037)
+var jwtAlgorithms = map[string]jwtAlgorithm{
+	"HS256": {family: "HS", hash: crypto.SHA256},
+	"HS384": {family: "HS", hash: crypto.SHA384},
+	"HS512": {family: "HS", hash: crypto.SHA512},
+	"RS256": {family: "RS", hash: crypto.SHA256},
+	"RS384": {family: "RS", hash: crypto.SHA384},
+	"RS512": {family: "RS", hash: crypto.SHA512},
+	"PS256": {family: "PS", hash: crypto.SHA256},
+	"PS384": {family: "PS", hash: crypto.SHA384},
+	"PS512": {family: "PS", hash: crypto.SHA512},
+	"ES256": {family: "ES", hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {family: "ES", hash: crypto.SHA384, curve: elliptic.P384()},
+	"ES512": {family: "ES", hash: crypto.SHA512, curve: elliptic.P521()},
+	"EdDSA": {family: "EdDSA"},
+}
+
+// jwtKey is a key tokens can be verified with. The key is an *rsa.PublicKey, *ecdsa.PublicKey,
+// ed25519.PublicKey or an HMAC secret.
+type jwtKey struct {
+	key       interface{}
+	keyID     string
+	algorithm string
+	source    string
+}
+
+// JWTVerifier verifies JWT signatures against keys from JWKS files and URLs, PEM files and HMAC secrets
+type JWTVerifier struct {
+	fetchedAt  time.Time
+	fetches    singleflight.Group
+	client     *http.Client
+	fetchErr   string
+	keys       []jwtKey
+	jwksURLs   []string
+	remoteKeys []jwtKey
+	mu         sync.Mutex
+}
+
+// NewJWTVerifier creates a JWT verifier with the keys configured in the environment:
+// JWT_JWKS_FILES and JWT_PUBLIC_KEY_FILES list key files ("kid=path" sets the kid of a PEM key),
+// JWT_HMAC_SECRETS lists shared secrets and JWT_JWKS_URLS lists JWKS endpoints, fetched when needed.
+func NewJWTVerifier() (*JWTVerifier, error) {
+	verifier := &JWTVerifier{
+		client:   &http.Client{Timeout: 5 * time.Second},
+		jwksURLs: splitList(os.Getenv("JWT_JWKS_URLS")),
+	}
+
+	for _, file := range splitList(os.Getenv("JWT_JWKS_FILES")) {
+		data, err := os.ReadFile(file) // #nosec G304 -- JWKS files are configured by the operator
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file %s: %w", file, err)
+		}
+		keys, err := parseJWKS(data, "jwks file "+file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS file %s: %w", file, err)
+		}
+		verifier.keys = append(verifier.keys, keys...)
+	}
+
+	for _, entry := range splitList(os.Getenv("JWT_PUBLIC_KEY_FILES")) {
+		keyID, file, found := strings.Cut(entry, "=")
+		if !found {
+			keyID, file = "", entry
+		}
+		data, err := os.ReadFile(file) // #nosec G304 -- key files are configured by the operator
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key file %s: %w", file, err)
+		}
+		keys, err := parsePEMKeys(data, keyID, "pem file "+file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key file %s: %w", file, err)
+		}
+		verifier.keys = append(verifier.keys, keys...)
+	}
+
+	for i, secret := range splitList(os.Getenv("JWT_HMAC_SECRETS")) {
+		verifier.keys = append(verifier.keys, jwtKey{
+			key:    []byte(secret),
+			source: fmt.Sprintf("hmac secret %d", i+1),
+		})
+	}
+
+	return verifier, nil
+}
+
+// SetVerifier sets the verifier used to check the signatures of decoded tokens.
+// Without one, or when it has no keys, signatures are not checked.
+func (s *JWTService) SetVerifier(verifier *JWTVerifier) {
+	s.verifier = verifier
+}
+
+// Enabled reports whether any keys or JWKS URLs are configured
+func (v *JWTVerifier) Enabled() bool {
+	return len(v.keys) > 0 || len(v.jwksURLs) > 0
+}
+
+// Verify checks the signature of a token whose header was decoded into the JWT info,
+// and records whether it is valid, the kid of the key that verified it or why it failed
+func (v *JWTVerifier) Verify(token string, jwtInfo *models.JwtInfo) {
+	valid := false
+	jwtInfo.SignatureValid = &valid
+
+	algorithmName, _ := jwtInfo.Header["alg"].(string)
+	keyID, _ := jwtInfo.Header["kid"].(string)
+	algorithm, ok := jwtAlgorithms[algorithmName]
+	if !ok {
+		if strings.EqualFold(algorithmName, "none") {
+			jwtInfo.SignatureError = "token is unsigned (alg none)"
+		} else {
+			jwtInfo.SignatureError = fmt.Sprintf("unsupported algorithm %q", algorithmName)
+		}
+		return
+	}
+
+	lastDot := strings.LastIndex(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(token[lastDot+1:], "="))
+	if err != nil {
+		jwtInfo.SignatureError = "signature is not valid base64url"
+		return
+	}
+	signingInput := []byte(token[:lastDot])
+
+	candidates := v.candidateKeys(algorithmName, algorithm, keyID)
+	if len(candidates) == 0 {
+		jwtInfo.SignatureError = v.noKeyReason(algorithmName, keyID)
+		return
+	}
+
+	for _, key := range candidates {
+		if verifyJWTSignature(algorithm, key.key, signingInput, signature) {
+			valid = true
+			jwtInfo.SignatureKeyID = key.keyID
+			jwtInfo.SignatureKeySource = key.source
+			return
+		}
+	}
+	jwtInfo.SignatureError = fmt.Sprintf("signature does not match any of %d candidate keys", len(candidates))
+}
+
+// candidateKeys returns the keys that can verify tokens signed with an algorithm. Keys with a kid are only
+// tried for tokens with the same kid. When no key has the token's kid, the JWKS URLs are fetched again.
+func (v *JWTVerifier) candidateKeys(algorithmName string, algorithm jwtAlgorithm, keyID string) []jwtKey {
+	match := func(keys []jwtKey) []jwtKey {
+		var candidates []jwtKey
+		for _, key := range keys {
+			if key.keyID != "" && keyID != "" && key.keyID != keyID {
+				continue
+			}
+			if key.algorithm != "" && key.algorithm != algorithmName {
+				continue
+			}
+			if keyMatchesAlgorithm(key.key, algorithm) {
+				candidates = append(candidates, key)
+			}
+		}
+		return candidates
+	}
+
+	candidates := match(v.keys)
+	if len(v.jwksURLs) == 0 {
+		return candidates
+	}
+
+	remote := match(v.fetchRemoteKeys(false))
+	if keyID != "" && !hasKeyID(remote, keyID) {
+		// The key may have been rotated in since the keys were fetched
+		remote = match(v.fetchRemoteKeys(true))
+	}
+	return append(candidates, remote...)
+}
+
+// noKeyReason explains why no key could verify a token
+func (v *JWTVerifier) noKeyReason(algorithmName, keyID string) string {
+	reason := "no key configured for " + algorithmName
+	if keyID != "" {
+		reason = fmt.Sprintf("no key configured for %s with kid %q", algorithmName, keyID)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.fetchErr != "" {
+		reason += " (" + v.fetchErr + ")"
+	}
+	return reason
+}
+
+// fetchRemoteKeys returns the keys of the JWKS URLs, fetching them when they are stale,
+// or when forced and they were not fetched within jwksMinRefreshInterval
+func (v *JWTVerifier) fetchRemoteKeys(force bool) []jwtKey {
+	v.mu.Lock()
+	age := time.Since(v.fetchedAt)
+	fresh := !v.fetchedAt.IsZero() && age < jwksRefreshInterval && (!force || age < jwksMinRefreshInterval)
+	keys := v.remoteKeys
+	v.mu.Unlock()
+	if fresh {
+		return keys
+	}
+
+	// Concurrent verifications share a single fetch, made without holding the lock
+	_, _, _ = v.fetches.Do("jwks", func() (interface{}, error) {
+		v.refreshRemoteKeys()
+		return nil, nil
+	})
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.remoteKeys
+}
+
+// refreshRemoteKeys fetches the keys of the JWKS URLs and replaces the cached keys with them
+func (v *JWTVerifier) refreshRemoteKeys() {
+	var keys []jwtKey
+	var fetchErrs []string
+	for _, jwksURL := range v.jwksURLs {
+		fetched, err := v.fetchJWKS(jwksURL)
+		if err != nil {
+			fetchErrs = append(fetchErrs, err.Error())
+			continue
+		}
+		keys = append(keys, fetched...)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fetchedAt = time.Now()
+	v.fetchErr = strings.Join(fetchErrs, "; ")
+	// Keys of URLs that failed are kept until they can be fetched again
+	if len(fetchErrs) == 0 || len(keys) > 0 {
+		v.remoteKeys = keys
+	}
+}
+
+// fetchJWKS fetches and parses the JWKS document of a URL
+func (v *JWTVerifier) fetchJWKS(jwksURL string) ([]jwtKey, error) {
+	resp, err := v.client.Get(jwksURL) // #nosec G107 -- JWKS URLs are configured by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS %s: %w", jwksURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS %s: status %d", jwksURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS %s: %w", jwksURL, err)
+	}
+	keys, err := parseJWKS(data, "jwks url "+jwksURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS %s: %w", jwksURL, err)
+	}
+	return keys, nil
+}
+
+// hasKeyID reports whether any of the keys has the kid
+func hasKeyID(keys []jwtKey, keyID string) bool {
+	for _, key := range keys {
+		if key.keyID == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// keyMatchesAlgorithm reports whether a key is of the type an algorithm signs with
+func keyMatchesAlgorithm(key interface{}, algorithm jwtAlgorithm) bool {
+	switch k := key.(type) {
+	case []byte:
+		return algorithm.family == "HS"
+	case *rsa.PublicKey:
+		return algorithm.family == "RS" || algorithm.family == "PS"
+	case *ecdsa.PublicKey:
+		return algorithm.family == "ES" && k.Curve == algorithm.curve
+	case ed25519.PublicKey:
+		return algorithm.family == "EdDSA"
+	default:
+		return false
+	}
+}
+
+// verifyJWTSignature verifies a JWS signature over the signing input with a key of the algorithm's type
+func verifyJWTSignature(algorithm jwtAlgorithm, key interface{}, signingInput, signature []byte) bool {
+	if algorithm.family == "EdDSA" {
+		publicKey, _ := key.(ed25519.PublicKey)
+		return ed25519.Verify(publicKey, signingInput, signature)
+	}
+
+	hasher := algorithm.hash.New()
+	_, _ = hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(algorithm.hash.New, k)
+		_, _ = mac.Write(signingInput)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		if algorithm.family == "PS" {
+			return rsa.VerifyPSS(k, algorithm.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+		}
+		return rsa.VerifyPKCS1v15(k, algorithm.hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS ECDSA signatures are the fixed-size big-endian r and s concatenated
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	default:
+		return false
+	}
+}
+
+// jsonWebKey is a key of a JWKS document (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS parses the signature verification keys of a JWKS document, skipping encryption keys
+func parseJWKS(data []byte, source string) ([]jwtKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []jwtKey
+	for i, jwk := range set.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		keys = append(keys, jwtKey{key: key, keyID: jwk.Kid, algorithm: jwk.Alg, source: source})
+	}
+	return keys, nil
+}
+
+// parseJWK decodes the public key or secret of a JSON Web Key
+func parseJWK(jwk jsonWebKey) (interface{}, error) {
+	decode := func(field, value string) ([]byte, error) {
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil || len(decoded) == 0 {
+			return nil, fmt.Errorf("invalid %s parameter", field)
+		}
+		return decoded, nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode("n", jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid e parameter")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[jwk.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC point size")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return decode("k", jwk.K)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// parsePEMKeys parses the public keys and certificates of a PEM file
+func parsePEMKeys(data []byte, keyID, source string) ([]jwtKey, error) {
+	var keys []jwtKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwtKey{key: key, keyID: keyID, source: source})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no public key or certificate found")
+	}
+	return keys, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
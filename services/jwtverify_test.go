@@ -0,0 +1,432 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signTestJWT signs a token with the payload {"sub":"test"} using a private key or HMAC secret
+func signTestJWT(t *testing.T, alg, kid string, key interface{}) string {
+	t.Helper()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("Failed to encode header: %v", err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test"}`))
+
+	algorithm := jwtAlgorithms[alg]
+	var digest []byte
+	if algorithm.family != "EdDSA" {
+		hasher := algorithm.hash.New()
+		hasher.Write([]byte(signingInput))
+		digest = hasher.Sum(nil)
+	}
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(algorithm.hash.New, k)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if algorithm.family == "PS" {
+			signature, err = rsa.SignPSS(rand.Reader, k, algorithm.hash, digest, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, algorithm.hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signingInput))
+	}
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// verifyTestJWT decodes and verifies a token with a verifier
+func verifyTestJWT(t *testing.T, verifier *JWTVerifier, token string) *bool {
+	t.Helper()
+
+	service := NewJWTService()
+	service.SetVerifier(verifier)
+	jwtInfo := service.decodeJWT(token)
+	if jwtInfo == nil {
+		t.Fatal("Expected token to be decoded")
+	}
+	return jwtInfo.SignatureValid
+}
+
+// publicKeyPEM encodes a public key as a PEM PUBLIC KEY block
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode public key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// testJWK encodes a public key or secret as a JSON Web Key
+func testJWK(kid string, key interface{}) map[string]string {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "n": encode(k.N.Bytes()), "e": encode(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		point, _ := k.Bytes()
+		size := (len(point) - 1) / 2
+		return map[string]string{"kty": "EC", "kid": kid, "crv": k.Curve.Params().Name, "x": encode(point[1 : 1+size]), "y": encode(point[1+size:])}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": encode(k)}
+	case []byte:
+		return map[string]string{"kty": "oct", "kid": kid, "k": encode(k)}
+	}
+	return nil
+}
+
+// testJWKS encodes JSON Web Keys as a JWKS document
+func testJWKS(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatalf("Failed to encode JWKS: %v", err)
+	}
+	return data
+}
+
+func TestJWTVerifier_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p521Key, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("a shared secret of sufficient length")
+
+	t.Setenv("JWT_JWKS_FILES", writeFile(t, "jwks.json", testJWKS(t,
+		testJWK("rsa-1", &rsaKey.PublicKey),
+		testJWK("ec-256", &p256Key.PublicKey),
+		testJWK("ec-521", &p521Key.PublicKey),
+		testJWK("ed-1", edPublic),
+		testJWK("hmac-1", secret),
+	)))
+	t.Setenv("JWT_PUBLIC_KEY_FILES", "ec-384="+writeFile(t, "ec384.pem", publicKeyPEM(t, &p384Key.PublicKey)))
+
+	verifier, err := NewJWTVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	tests := []struct {
+		key interface{}
+		alg string
+		kid string
+	}{
+		{rsaKey, "RS256", "rsa-1"},
+		{rsaKey, "RS512", "rsa-1"},
+		{rsaKey, "PS256", "rsa-1"},
+		{rsaKey, "PS384", "rsa-1"},
+		{p256Key, "ES256", "ec-256"},
+		{p384Key, "ES384", "ec-384"},
+		{p521Key, "ES512", "ec-521"},
+		{edPrivate, "EdDSA", "ed-1"},
+		{secret, "HS256", "hmac-1"},
+		{secret, "HS512", "hmac-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg+"/"+tt.kid, func(t *testing.T) {
+			service := NewJWTService()
+			service.SetVerifier(verifier)
+			jwtInfo := service.decodeJWT(signTestJWT(t, tt.alg, tt.kid, tt.key))
+
+			if jwtInfo.SignatureValid == nil || !*jwtInfo.SignatureValid {
+				t.Fatalf("Expected valid signature, got error %q", jwtInfo.SignatureError)
+			}
+			if jwtInfo.SignatureKeyID != tt.kid {
+				t.Errorf("Expected kid %q, got %q", tt.kid, jwtInfo.SignatureKeyID)
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_Failures(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	t.Setenv("JWT_JWKS_FILES", writeFile(t, "jwks.json", testJWKS(t, testJWK("rsa-1", &rsaKey.PublicKey))))
+	t.Setenv("JWT_HMAC_SECRETS", "first-secret, second-secret")
+
+	verifier, err := NewJWTVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	valid := signTestJWT(t, "RS256", "rsa-1", rsaKey)
+	tampered := valid[:strings.LastIndex(valid, ".")] + "." + strings.Repeat("A", 342)
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test"}`)) + "."
+
+	tests := []struct {
+		name  string
+		token string
+		error string
+	}{
+		{"wrong key", signTestJWT(t, "RS256", "rsa-1", otherKey), "does not match any of 1 candidate keys"},
+		{"tampered", tampered, "does not match"},
+		{"unknown kid", signTestJWT(t, "RS256", "rsa-2", rsaKey), `no key configured for RS256 with kid "rsa-2"`},
+		{"no key for algorithm", signTestJWT(t, "EdDSA", "", ed25519.NewKeyFromSeed(make([]byte, 32))), "no key configured for EdDSA"},
+		{"wrong secret", signTestJWT(t, "HS256", "", []byte("third-secret")), "does not match any of 2 candidate keys"},
+		{"unsigned", unsigned, "unsigned"},
+		{"unsupported algorithm", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"XS256"}`)) + ".e30.c2ln", `unsupported algorithm "XS256"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewJWTService()
+			service.SetVerifier(verifier)
+			jwtInfo := service.decodeJWT(tt.token)
+			if jwtInfo == nil {
+				t.Fatal("Expected token to be decoded")
+			}
+
+			if jwtInfo.SignatureValid == nil || *jwtInfo.SignatureValid {
+				t.Fatalf("Expected invalid signature, got %v", jwtInfo.SignatureValid)
+			}
+			if !strings.Contains(jwtInfo.SignatureError, tt.error) {
+				t.Errorf("Expected error containing %q, got %q", tt.error, jwtInfo.SignatureError)
+			}
+		})
+	}
+
+	t.Run("secret without kid", func(t *testing.T) {
+		service := NewJWTService()
+		service.SetVerifier(verifier)
+		jwtInfo := service.decodeJWT(signTestJWT(t, "HS256", "", []byte("second-secret")))
+		if jwtInfo.SignatureValid == nil || !*jwtInfo.SignatureValid || jwtInfo.SignatureKeySource != "hmac secret 2" {
+			t.Errorf("Expected second secret to verify the token, got %+v", jwtInfo)
+		}
+	})
+}
+
+func TestJWTVerifier_JWKSURL(t *testing.T) {
+	firstKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rotatedKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var fetches atomic.Int32
+	var rotated atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys := []map[string]string{testJWK("key-1", &firstKey.PublicKey)}
+		if rotated.Load() {
+			keys = append(keys, testJWK("key-2", &rotatedKey.PublicKey))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(testJWKS(t, keys...))
+	}))
+	defer server.Close()
+
+	t.Setenv("JWT_JWKS_URLS", server.URL)
+	verifier, err := NewJWTVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	if valid := verifyTestJWT(t, verifier, signTestJWT(t, "ES256", "key-1", firstKey)); valid == nil || !*valid {
+		t.Fatal("Expected token signed with the published key to be valid")
+	}
+	if valid := verifyTestJWT(t, verifier, signTestJWT(t, "ES256", "key-1", firstKey)); valid == nil || !*valid {
+		t.Fatal("Expected token to be valid with the cached keys")
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("Expected keys to be fetched once, got %d fetches", fetches.Load())
+	}
+
+	// A key rotated in is picked up once the keys may be fetched again
+	rotated.Store(true)
+	rotatedToken := signTestJWT(t, "ES256", "key-2", rotatedKey)
+	if valid := verifyTestJWT(t, verifier, rotatedToken); valid == nil || *valid {
+		t.Error("Expected keys not to be fetched again within the minimum refresh interval")
+	}
+	verifier.fetchedAt = verifier.fetchedAt.Add(-jwksMinRefreshInterval)
+	if valid := verifyTestJWT(t, verifier, rotatedToken); valid == nil || !*valid {
+		t.Error("Expected token signed with the rotated key to be valid")
+	}
+}
+
+func TestJWTVerifier_JWKSURLConcurrentFetch(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		_, _ = w.Write(testJWKS(t, testJWK("key-1", &key.PublicKey)))
+	}))
+	defer server.Close()
+
+	t.Setenv("JWT_JWKS_URLS", server.URL)
+	verifier, err := NewJWTVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	service := NewJWTService()
+	service.SetVerifier(verifier)
+	token := signTestJWT(t, "ES256", "key-1", key)
+	var wg sync.WaitGroup
+	results := make([]*bool, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if jwtInfo := service.decodeJWT(token); jwtInfo != nil {
+				results[i] = jwtInfo.SignatureValid
+			}
+		}(i)
+	}
+
+	for fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The lock is not held while the keys are fetched
+	reasonDone := make(chan struct{})
+	go func() {
+		defer close(reasonDone)
+		verifier.noKeyReason("ES256", "")
+	}()
+	select {
+	case <-reasonDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the verifier not to be locked during the fetch")
+	}
+
+	close(release)
+	wg.Wait()
+
+	for i, valid := range results {
+		if valid == nil || !*valid {
+			t.Errorf("Expected verification %d to be valid", i)
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("Expected concurrent verifications to share one fetch, got %d fetches", fetches.Load())
+	}
+}
+
+func TestJWTVerifier_JWKSURLUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	t.Setenv("JWT_JWKS_URLS", server.URL)
+	verifier, err := NewJWTVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	service := NewJWTService()
+	service.SetVerifier(verifier)
+	jwtInfo := service.decodeJWT(signTestJWT(t, "ES256", "key-1", key))
+
+	if jwtInfo.SignatureValid == nil || *jwtInfo.SignatureValid {
+		t.Fatal("Expected invalid signature")
+	}
+	if !strings.Contains(jwtInfo.SignatureError, "status 503") {
+		t.Errorf("Expected fetch error in reason, got %q", jwtInfo.SignatureError)
+	}
+}
+
+func TestJWTVerifier_NotConfigured(t *testing.T) {
+	verifier, err := NewJWTVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	if verifier.Enabled() {
+		t.Error("Expected verifier without keys to be disabled")
+	}
+	if valid := verifyTestJWT(t, verifier, signTestJWT(t, "HS256", "", []byte("secret"))); valid != nil {
+		t.Errorf("Expected signature not to be checked, got %v", *valid)
+	}
+}
+
+func TestNewJWTVerifier_InvalidKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		data  string
+		error string
+	}{
+		{"missing JWKS file", "JWT_JWKS_FILES", "", "failed to read JWKS file"},
+		{"invalid JWKS", "JWT_JWKS_FILES", `{"keys":[{"kty":"EC","crv":"P-192","x":"AA","y":"AA"}]}`, "unsupported curve"},
+		{"unsupported key type", "JWT_JWKS_FILES", `{"keys":[{"kty":"XYZ"}]}`, "unsupported key type"},
+		{"no PEM key", "JWT_PUBLIC_KEY_FILES", "not a key", "no public key or certificate found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.json")
+			if tt.data != "" {
+				path = writeFile(t, "keys", []byte(tt.data))
+			}
+			t.Setenv(tt.env, path)
+
+			if _, err := NewJWTVerifier(); err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestParseJWKS_SkipsEncryptionKeys(t *testing.T) {
+	keys, err := parseJWKS([]byte(`{"keys":[{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"},{"kty":"oct","k":"c2VjcmV0"}]}`), "test")
+	if err != nil {
+		t.Fatalf("Failed to parse JWKS: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("Expected the encryption key to be skipped, got %d keys", len(keys))
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	proxyproto "github.com/pires/go-proxyproto"
+	"github.com/ullbergm/echo-server/models"
+)
+
+// PROXY protocol modes of a listener
+const (
+	ProxyProtocolOff      = ""
+	ProxyProtocolRequired = "required"
+	ProxyProtocolOptional = "optional"
+)
+
+// ListenerConfig describes one listener the server accepts connections on
+type ListenerConfig struct {
+	Label         string
+	Network       string
+	Address       string
+	CertFile      string
+	KeyFile       string
+	ProxyProtocol string
+	SocketMode    os.FileMode
+	TLS           bool
+}
+
+// Info returns the listener description reported in echo responses
+func (l ListenerConfig) Info() *models.ListenerInfo {
+	return &models.ListenerInfo{
+		Label:         l.Label,
+		Network:       l.Network,
+		Address:       l.Address,
+		TLS:           l.TLS,
+		ProxyProtocol: l.ProxyProtocol,
+	}
+}
+
+// ListenersFromEnv returns the configured listeners. LISTENERS takes precedence; without it
+// the listeners are derived from PORT, TLS_* and UNIX_SOCKET_* as before.
+func ListenersFromEnv() ([]ListenerConfig, error) {
+	if spec := os.Getenv("LISTENERS"); strings.TrimSpace(spec) != "" {
+		return ParseListeners(spec)
+	}
+	return legacyListeners(), nil
+}
+
+// ParseListeners parses a listener list of the form
+// "label=public,address=:8080;label=admin,address=127.0.0.1:9443,tls=true".
+// Listeners are separated by semicolons and their options by commas. Options are
+// label, address, network (tcp, tcp4, tcp6, unix), tls, cert, key, proxy-protocol
+// (true/required, optional, false) and mode (octal file mode for unix sockets).
+func ParseListeners(spec string) ([]ListenerConfig, error) {
+	var listeners []ListenerConfig
+	labels := make(map[string]bool)
+
+	for i, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		listener := ListenerConfig{Network: "tcp", SocketMode: DefaultUnixSocketMode}
+		for _, option := range strings.Split(entry, ",") {
+			key, value, found := strings.Cut(strings.TrimSpace(option), "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.TrimSpace(value)
+			if key == "" {
+				continue
+			}
+			if !found {
+				return nil, fmt.Errorf("listener %d: option %q is not of the form key=value", i+1, option)
+			}
+
+			if err := listener.set(key, value); err != nil {
+				return nil, fmt.Errorf("listener %d: %w", i+1, err)
+			}
+		}
+
+		if listener.Address == "" {
+			return nil, fmt.Errorf("listener %d: address is required", i+1)
+		}
+		if listener.Label == "" {
+			listener.Label = listener.Address
+		}
+		if labels[listener.Label] {
+			return nil, fmt.Errorf("listener %d: duplicate label %q", i+1, listener.Label)
+		}
+		labels[listener.Label] = true
+
+		listeners = append(listeners, listener)
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no listeners configured")
+	}
+	return listeners, nil
+}
+
+func (l *ListenerConfig) set(key, value string) error {
+	switch key {
+	case "label", "name":
+		l.Label = value
+	case "address", "addr":
+		l.Address = value
+	case "network", "protocol":
+		switch value {
+		case "tcp", "tcp4", "tcp6", "unix":
+			l.Network = value
+		default:
+			return fmt.Errorf("unsupported network %q", value)
+		}
+	case "tls":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid tls value %q", value)
+		}
+		l.TLS = enabled
+	case "cert":
+		l.CertFile = value
+	case "key":
+		l.KeyFile = value
+	case "proxy-protocol", "proxyprotocol", "proxy":
+		switch strings.ToLower(value) {
+		case "true", "required":
+			l.ProxyProtocol = ProxyProtocolRequired
+		case "optional":
+			l.ProxyProtocol = ProxyProtocolOptional
+		case "false", "":
+			l.ProxyProtocol = ProxyProtocolOff
+		default:
+			return fmt.Errorf("invalid proxy-protocol value %q", value)
+		}
+	case "mode":
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid socket mode %q", value)
+		}
+		l.SocketMode = os.FileMode(mode)
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+	return nil
+}
+
+// legacyListeners maps PORT, TLS_ENABLED/TLS_PORT and UNIX_SOCKET_* to listeners
+func legacyListeners() []ListenerConfig {
+	var listeners []ListenerConfig
+
+	if socketPath := os.Getenv("UNIX_SOCKET_PATH"); socketPath != "" {
+		// Get socket file mode from environment (octal, default: 0660)
+		mode := DefaultUnixSocketMode
+		if modeEnv := os.Getenv("UNIX_SOCKET_MODE"); modeEnv != "" {
+			if parsed, err := strconv.ParseUint(modeEnv, 8, 32); err == nil {
+				mode = os.FileMode(parsed)
+			} else {
+				log.Printf("Warning: Invalid UNIX_SOCKET_MODE %q, using %o", modeEnv, mode)
+			}
+		}
+
+		listeners = append(listeners, ListenerConfig{
+			Label:      "unix",
+			Network:    "unix",
+			Address:    socketPath,
+			SocketMode: mode,
+		})
+
+		if socketOnly, err := strconv.ParseBool(os.Getenv("UNIX_SOCKET_ONLY")); err == nil && socketOnly {
+			return listeners
+		}
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	listeners = append(listeners, ListenerConfig{Label: "http", Network: "tcp", Address: ":" + port})
+
+	if tlsEnabled, err := strconv.ParseBool(os.Getenv("TLS_ENABLED")); err == nil && tlsEnabled {
+		tlsPort := os.Getenv("TLS_PORT")
+		if tlsPort == "" {
+			tlsPort = "8443"
+		}
+		listeners = append(listeners, ListenerConfig{
+			Label:    "https",
+			Network:  "tcp",
+			Address:  ":" + tlsPort,
+			TLS:      true,
+			CertFile: os.Getenv("TLS_CERT_FILE"),
+			KeyFile:  os.Getenv("TLS_KEY_FILE"),
+		})
+	}
+
+	return listeners
+}
+
+// OpenListener starts listening as configured. Connections are accepted through the
+// PROXY protocol (when enabled) and then TLS (when tlsConfig is set), and are tagged
+// with the listener so handlers can report where a request arrived.
+func OpenListener(config ListenerConfig, tlsConfig *tls.Config) (net.Listener, error) {
+	var ln net.Listener
+	var err error
+
+	if config.Network == "unix" {
+		ln, err = ListenUnix(config.Address, config.SocketMode)
+	} else {
+		ln, err = net.Listen(config.Network, config.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The PROXY header precedes the TLS handshake on the wire
+	if config.ProxyProtocol != ProxyProtocolOff {
+		policy := proxyproto.REQUIRE
+		if config.ProxyProtocol == ProxyProtocolOptional {
+			policy = proxyproto.USE
+		}
+		ln = &proxyproto.Listener{
+			Listener: ln,
+			ConnPolicy: func(proxyproto.ConnPolicyOptions) (proxyproto.Policy, error) {
+				return policy, nil
+			},
+		}
+	}
+
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	return &labeledListener{Listener: ln, info: config.Info()}, nil
+}
+
+// labeledListener tags accepted connections with the listener they arrived on
+type labeledListener struct {
+	net.Listener
+	info *models.ListenerInfo
+}
+
+func (l *labeledListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	labeled := &labeledConn{Conn: conn, info: l.info}
+	// fasthttp detects TLS through the connection's Handshake and ConnectionState methods
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return &labeledTLSConn{labeledConn: labeled, tlsConn: tlsConn}, nil
+	}
+	return labeled, nil
+}
+
+// labeledConn is a connection accepted on a labeled listener
+type labeledConn struct {
+	net.Conn
+	info *models.ListenerInfo
+}
+
+// Listener returns the listener the connection was accepted on
+func (c *labeledConn) Listener() *models.ListenerInfo {
+	return c.info
+}
+
+// NetConn returns the wrapped connection
+func (c *labeledConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// labeledTLSConn is a TLS connection accepted on a labeled listener
+type labeledTLSConn struct {
+	*labeledConn
+	tlsConn *tls.Conn
+}
+
+func (c *labeledTLSConn) Handshake() error {
+	return c.tlsConn.Handshake()
+}
+
+func (c *labeledTLSConn) ConnectionState() tls.ConnectionState {
+	return c.tlsConn.ConnectionState()
+}
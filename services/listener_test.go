@@ -0,0 +1,239 @@
+package services
+
+import (
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	proxyproto "github.com/pires/go-proxyproto"
+)
+
+func TestParseListeners(t *testing.T) {
+	listeners, err := ParseListeners("label=public, address=:8080; label=admin,address=127.0.0.1:9443,tls=true,cert=/tls/admin.crt,key=/tls/admin.key;" +
+		"address=:8081,network=tcp4,proxy-protocol=optional; label=local,network=unix,address=/tmp/echo.sock,mode=0600;")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(listeners) != 4 {
+		t.Fatalf("Expected 4 listeners, got %d", len(listeners))
+	}
+
+	public := listeners[0]
+	if public.Label != "public" || public.Address != ":8080" || public.Network != "tcp" || public.TLS {
+		t.Errorf("Unexpected public listener: %+v", public)
+	}
+
+	admin := listeners[1]
+	if !admin.TLS || admin.CertFile != "/tls/admin.crt" || admin.KeyFile != "/tls/admin.key" {
+		t.Errorf("Unexpected admin listener: %+v", admin)
+	}
+
+	lb := listeners[2]
+	if lb.Label != ":8081" || lb.Network != "tcp4" || lb.ProxyProtocol != ProxyProtocolOptional {
+		t.Errorf("Unexpected listener without label: %+v", lb)
+	}
+
+	local := listeners[3]
+	if local.Network != "unix" || local.SocketMode != 0o600 {
+		t.Errorf("Unexpected unix listener: %+v", local)
+	}
+}
+
+func TestParseListeners_Errors(t *testing.T) {
+	tests := []string{
+		"",
+		";;",
+		"label=a",
+		"address=:8080,tls=maybe",
+		"address=:8080,network=udp",
+		"address=:8080,proxy-protocol=sometimes",
+		"address=:8080,colour=blue",
+		"address=:8080,tls",
+		"address=/tmp/s,network=unix,mode=999",
+		"label=a,address=:8080;label=a,address=:8081",
+	}
+
+	for _, spec := range tests {
+		if _, err := ParseListeners(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestListenersFromEnv_Legacy(t *testing.T) {
+	t.Setenv("LISTENERS", "")
+	t.Setenv("PORT", "9090")
+	t.Setenv("TLS_ENABLED", "true")
+	t.Setenv("TLS_PORT", "9443")
+	t.Setenv("TLS_CERT_FILE", "/c.crt")
+	t.Setenv("TLS_KEY_FILE", "/c.key")
+	t.Setenv("UNIX_SOCKET_PATH", "/tmp/echo.sock")
+	t.Setenv("UNIX_SOCKET_MODE", "600")
+	t.Setenv("UNIX_SOCKET_ONLY", "")
+
+	listeners, err := ListenersFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(listeners) != 3 {
+		t.Fatalf("Expected unix, http and https listeners, got %+v", listeners)
+	}
+
+	if listeners[0].Label != "unix" || listeners[0].Address != "/tmp/echo.sock" || listeners[0].SocketMode != 0o600 {
+		t.Errorf("Unexpected unix listener: %+v", listeners[0])
+	}
+	if listeners[1].Label != "http" || listeners[1].Address != ":9090" || listeners[1].TLS {
+		t.Errorf("Unexpected http listener: %+v", listeners[1])
+	}
+	if listeners[2].Label != "https" || listeners[2].Address != ":9443" || !listeners[2].TLS || listeners[2].CertFile != "/c.crt" {
+		t.Errorf("Unexpected https listener: %+v", listeners[2])
+	}
+
+	t.Setenv("UNIX_SOCKET_ONLY", "true")
+	listeners, _ = ListenersFromEnv()
+	if len(listeners) != 1 || listeners[0].Network != "unix" {
+		t.Errorf("Expected only the unix listener, got %+v", listeners)
+	}
+}
+
+func TestListenersFromEnv_Defaults(t *testing.T) {
+	t.Setenv("LISTENERS", "")
+	t.Setenv("PORT", "")
+	t.Setenv("TLS_ENABLED", "")
+	t.Setenv("UNIX_SOCKET_PATH", "")
+
+	listeners, err := ListenersFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Address != ":8080" || listeners[0].Label != "http" {
+		t.Errorf("Expected a single HTTP listener on :8080, got %+v", listeners)
+	}
+}
+
+func TestListenersFromEnv_ListenersTakePrecedence(t *testing.T) {
+	t.Setenv("LISTENERS", "label=only,address=:7000")
+	t.Setenv("TLS_ENABLED", "true")
+
+	listeners, err := ListenersFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Label != "only" {
+		t.Errorf("Expected LISTENERS to replace the legacy settings, got %+v", listeners)
+	}
+}
+
+// acceptOne accepts a single connection on ln and sends it on the returned channel
+func acceptOne(ln net.Listener) <-chan net.Conn {
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+	return accepted
+}
+
+func TestOpenListener_ReportsListenerAndProxyHeader(t *testing.T) {
+	ln, err := OpenListener(ListenerConfig{
+		Label:         "lb",
+		Network:       "tcp",
+		Address:       "127.0.0.1:0",
+		ProxyProtocol: ProxyProtocolRequired,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to open listener: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := acceptOne(ln)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	header := proxyproto.HeaderProxyFromAddrs(1,
+		&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51000},
+		&net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 443})
+	if _, err = header.WriteTo(client); err != nil {
+		t.Fatalf("Failed to write PROXY header: %v", err)
+	}
+	if _, err = client.Write([]byte("x")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	conn := <-accepted
+	if conn == nil {
+		t.Fatal("Expected a connection to be accepted")
+	}
+	defer conn.Close()
+
+	// Reading consumes the PROXY header
+	buf := make([]byte, 1)
+	if _, err = conn.Read(buf); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+
+	if conn.RemoteAddr().String() != "203.0.113.7:51000" {
+		t.Errorf("Expected remote address from PROXY header, got %s", conn.RemoteAddr())
+	}
+
+	info := GetConnectionInfo(conn)
+	if info.Listener == nil || info.Listener.Label != "lb" || info.Listener.ProxyProtocol != ProxyProtocolRequired {
+		t.Errorf("Unexpected listener info: %+v", info.Listener)
+	}
+	if info.LocalAddress != ln.Addr().String() {
+		t.Errorf("Expected local socket address %s, got %s", ln.Addr(), info.LocalAddress)
+	}
+
+	pp := info.ProxyProtocol
+	if pp == nil {
+		t.Fatal("Expected PROXY protocol info")
+	}
+	if pp.Version != 1 || pp.Command != "PROXY" || pp.SourceAddress != "203.0.113.7:51000" || pp.DestinationAddress != "10.0.0.5:443" {
+		t.Errorf("Unexpected PROXY protocol info: %+v", pp)
+	}
+	if pp.TransportAddress != client.LocalAddr().String() {
+		t.Errorf("Expected transport address %s, got %s", client.LocalAddr(), pp.TransportAddress)
+	}
+}
+
+func TestOpenListener_Unix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix domain sockets are not supported on this platform")
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "echo.sock")
+	ln, err := OpenListener(ListenerConfig{Label: "local", Network: "unix", Address: socketPath, SocketMode: 0o600}, nil)
+	if err != nil {
+		t.Fatalf("Failed to open listener: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := acceptOne(ln)
+
+	client, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+
+	info := GetConnectionInfo(conn)
+	if info.Network != "unix" || info.Listener == nil || info.Listener.Label != "local" {
+		t.Errorf("Unexpected connection info: %+v", info)
+	}
+	if runtime.GOOS == "linux" && info.PeerCredentials == nil {
+		t.Error("Expected peer credentials through the listener wrapper")
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// DefaultLongPollTimeout is how long a poll is held when no timeout is requested
+	DefaultLongPollTimeout = 30 * time.Second
+
+	// DefaultLongPollMaxTimeout caps the hold time a client may request
+	DefaultLongPollMaxTimeout = 5 * time.Minute
+)
+
+// LongPollService delivers events published to a channel to the requests polling it.
+// Channels live in memory, so publishers and pollers must reach the same server process.
+type LongPollService struct {
+	subscribers    map[string]map[chan *models.PollEvent]struct{}
+	defaultTimeout time.Duration
+	maxTimeout     time.Duration
+	mu             sync.Mutex
+}
+
+// NewLongPollService creates a new long-poll service configured from the environment
+func NewLongPollService() *LongPollService {
+	defaultTimeout := DefaultLongPollTimeout
+	if timeoutEnv := os.Getenv("LONG_POLL_DEFAULT_TIMEOUT_SECONDS"); timeoutEnv != "" {
+		if parsed, err := strconv.Atoi(timeoutEnv); err == nil && parsed > 0 {
+			defaultTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+
+	maxTimeout := DefaultLongPollMaxTimeout
+	if maxEnv := os.Getenv("LONG_POLL_MAX_TIMEOUT_SECONDS"); maxEnv != "" {
+		if parsed, err := strconv.Atoi(maxEnv); err == nil && parsed > 0 {
+			maxTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+
+	if defaultTimeout > maxTimeout {
+		defaultTimeout = maxTimeout
+	}
+
+	return &LongPollService{
+		subscribers:    make(map[string]map[chan *models.PollEvent]struct{}),
+		defaultTimeout: defaultTimeout,
+		maxTimeout:     maxTimeout,
+	}
+}
+
+// Timeout returns the hold time for a requested timeout, applying the default and the maximum.
+// The request may be in seconds ("45", "0.5") or a Go duration ("90s", "1m30s").
+func (s *LongPollService) Timeout(requested string) time.Duration {
+	if requested == "" {
+		return s.defaultTimeout
+	}
+
+	timeout, err := time.ParseDuration(requested)
+	if err != nil {
+		seconds, parseErr := strconv.ParseFloat(requested, 64)
+		if parseErr != nil {
+			return s.defaultTimeout
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	if timeout < 0 {
+		return 0
+	}
+	if timeout > s.maxTimeout {
+		return s.maxTimeout
+	}
+	return timeout
+}
+
+// Wait blocks until an event is published to the channel, the timeout elapses or the context is done.
+// It returns nil when no event arrived.
+func (s *LongPollService) Wait(ctx context.Context, channel string, timeout time.Duration) *models.PollEvent {
+	events := make(chan *models.PollEvent, 1)
+
+	s.mu.Lock()
+	if s.subscribers[channel] == nil {
+		s.subscribers[channel] = make(map[chan *models.PollEvent]struct{})
+	}
+	s.subscribers[channel][events] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers[channel], events)
+		if len(s.subscribers[channel]) == 0 {
+			delete(s.subscribers, channel)
+		}
+		s.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case event := <-events:
+		return event
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	// An event may have been delivered right as the wait ended
+	select {
+	case event := <-events:
+		return event
+	default:
+		return nil
+	}
+}
+
+// Publish delivers an event to every request currently polling the channel and
+// returns the number of pollers that received it. Events are not retained.
+func (s *LongPollService) Publish(channel string, event *models.PollEvent) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivered := 0
+	for events := range s.subscribers[channel] {
+		// Each poller receives at most one event; later ones go to the next poll
+		select {
+		case events <- event:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
+
+// Waiting returns the number of requests currently polling the channel
+func (s *LongPollService) Waiting(channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers[channel])
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+func TestLongPollService_Timeout(t *testing.T) {
+	t.Setenv("LONG_POLL_DEFAULT_TIMEOUT_SECONDS", "20")
+	t.Setenv("LONG_POLL_MAX_TIMEOUT_SECONDS", "60")
+	service := NewLongPollService()
+
+	tests := map[string]time.Duration{
+		"":        20 * time.Second,
+		"5":       5 * time.Second,
+		"0.5":     500 * time.Millisecond,
+		"1m30s":   60 * time.Second,
+		"45s":     45 * time.Second,
+		"120":     60 * time.Second,
+		"-3":      0,
+		"invalid": 20 * time.Second,
+	}
+
+	for input, expected := range tests {
+		if got := service.Timeout(input); got != expected {
+			t.Errorf("Timeout(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
+
+func TestLongPollService_DefaultCappedByMax(t *testing.T) {
+	t.Setenv("LONG_POLL_DEFAULT_TIMEOUT_SECONDS", "600")
+	t.Setenv("LONG_POLL_MAX_TIMEOUT_SECONDS", "10")
+
+	if got := NewLongPollService().Timeout(""); got != 10*time.Second {
+		t.Errorf("Expected default timeout to be capped at 10s, got %v", got)
+	}
+}
+
+// waitForPollers blocks until the expected number of requests are polling the channel
+func waitForPollers(t *testing.T, service *LongPollService, channel string, expected int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for service.Waiting(channel) < expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d pollers on %s", expected, channel)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLongPollService_PublishWakesAllPollers(t *testing.T) {
+	service := NewLongPollService()
+
+	results := make(chan *models.PollEvent, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- service.Wait(context.Background(), "orders", 5*time.Second)
+		}()
+	}
+	waitForPollers(t, service, "orders", 2)
+
+	if delivered := service.Publish("other", &models.PollEvent{}); delivered != 0 {
+		t.Errorf("Expected no delivery on another channel, got %d", delivered)
+	}
+
+	event := &models.PollEvent{PublishedAt: "now"}
+	if delivered := service.Publish("orders", event); delivered != 2 {
+		t.Errorf("Expected event delivered to 2 pollers, got %d", delivered)
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := <-results; got != event {
+			t.Errorf("Expected published event, got %+v", got)
+		}
+	}
+
+	if waiting := service.Waiting("orders"); waiting != 0 {
+		t.Errorf("Expected pollers to be removed after returning, got %d", waiting)
+	}
+}
+
+func TestLongPollService_WaitTimesOut(t *testing.T) {
+	service := NewLongPollService()
+
+	start := time.Now()
+	if event := service.Wait(context.Background(), "idle", 20*time.Millisecond); event != nil {
+		t.Errorf("Expected no event, got %+v", event)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected wait to last the timeout, returned after %v", elapsed)
+	}
+
+	// Nothing is retained for later polls
+	if delivered := service.Publish("idle", &models.PollEvent{}); delivered != 0 {
+		t.Errorf("Expected no pollers left, got %d deliveries", delivered)
+	}
+}
+
+func TestLongPollService_WaitCanceled(t *testing.T) {
+	service := NewLongPollService()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if event := service.Wait(ctx, "canceled", time.Minute); event != nil {
+		t.Errorf("Expected no event, got %+v", event)
+	}
+}
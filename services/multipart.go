@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// maxMultipartParts is the largest number of parts described in a multipart body
+	maxMultipartParts = 1000
+
+	// maxMultipartDepth is the deepest nesting of multipart parts that is expanded
+	maxMultipartDepth = 5
+
+	// maxPartContentSize is the largest part content included in a part description
+	maxPartContentSize = 64 * 1024
+)
+
+// parseMultipart parses a multipart body into its parts. Form data is also returned as a map of fields;
+// other multipart types such as multipart/mixed and multipart/related have no content besides the parts.
+func (s *BodyService) parseMultipart(data []byte, mediaType string, params map[string]string) (interface{}, []models.MultipartPart, error) {
+	parts, err := s.parseMultipartParts(data, params, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mediaType != "multipart/form-data" {
+		return nil, parts, nil
+	}
+	return multipartFormFields(parts), parts, nil
+}
+
+// multipartFormFields collects form data parts into a map of fields.
+// Fields with a repeated name are collected into an array in the order they were sent.
+func multipartFormFields(parts []models.MultipartPart) map[string]interface{} {
+	result := make(map[string]interface{})
+	for i := range parts {
+		part := &parts[i]
+		if part.Name == "" {
+			continue
+		}
+
+		var value interface{}
+		if part.Filename != "" {
+			// File upload
+			fileInfo := map[string]interface{}{
+				"filename": part.Filename,
+				"size":     part.Size,
+				"content":  part.Content,
+			}
+			if part.ContentType != "" {
+				fileInfo["contentType"] = part.ContentType
+			}
+			if part.Encoding != "" {
+				fileInfo["encoding"] = part.Encoding
+			}
+			value = fileInfo
+		} else {
+			// Regular form field
+			value = part.Content
+		}
+
+		switch existing := result[part.Name].(type) {
+		case nil:
+			result[part.Name] = value
+		case []interface{}:
+			result[part.Name] = append(existing, value)
+		default:
+			result[part.Name] = []interface{}{existing, value}
+		}
+	}
+
+	return result
+}
+
+// parseMultipartParts describes each part of a multipart body in order.
+// Parts that are multipart themselves are expanded up to maxMultipartDepth.
+func (s *BodyService) parseMultipartParts(data []byte, params map[string]string, depth int) ([]models.MultipartPart, error) {
+	boundary, ok := params["boundary"]
+	if !ok || boundary == "" {
+		return nil, errors.New("multipart: missing boundary")
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(data), boundary)
+	parts := []models.MultipartPart{}
+
+	for len(parts) < maxMultipartParts {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partData, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, s.describePart(part, partData, depth))
+	}
+
+	return parts, nil
+}
+
+// describePart describes a single part of a multipart body
+func (s *BodyService) describePart(part *multipart.Part, data []byte, depth int) models.MultipartPart {
+	sum := sha256.Sum256(data)
+	description := models.MultipartPart{
+		Headers:      make(map[string]string, len(part.Header)),
+		ContentType:  part.Header.Get("Content-Type"),
+		DetectedType: http.DetectContentType(data),
+		SHA256:       hex.EncodeToString(sum[:]),
+		Size:         len(data),
+	}
+
+	for key, values := range part.Header {
+		description.Headers[key] = strings.Join(values, ", ")
+	}
+
+	if disposition := part.Header.Get("Content-Disposition"); disposition != "" {
+		if dispositionType, dispositionParams, err := mime.ParseMediaType(disposition); err == nil {
+			description.Disposition = dispositionType
+			description.DispositionParams = dispositionParams
+			description.Name = dispositionParams["name"]
+			description.Filename = dispositionParams["filename"]
+		}
+	}
+
+	// Nested multipart parts are expanded instead of shown as content
+	if mediaType, params, err := mime.ParseMediaType(description.ContentType); err == nil &&
+		strings.HasPrefix(mediaType, "multipart/") && depth+1 < maxMultipartDepth {
+		if nested, nestedErr := s.parseMultipartParts(data, params, depth+1); nestedErr == nil {
+			description.Parts = nested
+			return description
+		}
+	}
+
+	// Binary parts are sniffed before their content is cut off
+	isBinary := s.isBinaryData(data)
+	if isBinary {
+		partType, _, err := mime.ParseMediaType(description.ContentType)
+		if err != nil {
+			partType = description.ContentType
+		}
+		description.FileType = DetectFileType(data, partType)
+	}
+
+	if len(data) > maxPartContentSize {
+		// Cut at a character boundary so text content stays valid UTF-8
+		cut := maxPartContentSize
+		for cut > maxPartContentSize-utf8.UTFMax && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		data = data[:cut]
+		description.Truncated = true
+	}
+	if isBinary {
+		description.Content = base64.StdEncoding.EncodeToString(data)
+		description.Encoding = "base64"
+	} else {
+		description.Content = string(data)
+	}
+
+	return description
+}
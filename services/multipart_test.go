@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestBodyService_MultipartParts(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("title", "Quarterly report")
+
+	pdf := []byte("%PDF-1.4\n\x00\x01binary")
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="documents"; filename="`+name+`"`)
+		header.Set("Content-Type", "application/octet-stream")
+		header.Set("X-Document-Id", name)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			t.Fatalf("Failed to create part: %v", err)
+		}
+		_, _ = part.Write(pdf)
+	}
+	_ = writer.Close()
+
+	bodyInfo := NewBodyService().ParseBody(body.Bytes(), writer.FormDataContentType())
+
+	if bodyInfo.IsBinary {
+		t.Error("Expected multipart body with binary files not to be marked as binary")
+	}
+	if len(bodyInfo.Parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(bodyInfo.Parts))
+	}
+
+	title := bodyInfo.Parts[0]
+	if title.Name != "title" || title.Content != "Quarterly report" || title.Disposition != "form-data" {
+		t.Errorf("Unexpected title part: %+v", title)
+	}
+
+	doc := bodyInfo.Parts[2]
+	sum := sha256.Sum256(pdf)
+	if doc.Filename != "b.pdf" || doc.ContentType != "application/octet-stream" || doc.DetectedType != "application/pdf" {
+		t.Errorf("Unexpected document part: %+v", doc)
+	}
+	if doc.SHA256 != hex.EncodeToString(sum[:]) || doc.Size != len(pdf) || doc.Encoding != "base64" {
+		t.Errorf("Unexpected document size, hash or encoding: %+v", doc)
+	}
+	if doc.Headers["X-Document-Id"] != "b.pdf" || doc.DispositionParams["filename"] != "b.pdf" {
+		t.Errorf("Expected part headers and disposition parameters, got %+v", doc)
+	}
+
+	// Repeated field names are kept in order
+	fields := bodyInfo.Content.(map[string]interface{})
+	documents, ok := fields["documents"].([]interface{})
+	if !ok || len(documents) != 2 {
+		t.Fatalf("Expected both documents under one field, got %v", fields["documents"])
+	}
+	if documents[0].(map[string]interface{})["filename"] != "a.pdf" {
+		t.Errorf("Expected documents in order, got %v", documents)
+	}
+	if fields["title"] != "Quarterly report" {
+		t.Errorf("Expected single field to stay a value, got %v", fields["title"])
+	}
+}
+
+func TestBodyService_MultipartMixedAndRelated(t *testing.T) {
+	body := strings.Join([]string{
+		"--outer",
+		"Content-Type: application/json",
+		"",
+		`{"id":1}`,
+		"--outer",
+		`Content-Type: multipart/alternative; boundary="inner"`,
+		"",
+		"--inner",
+		"Content-Type: text/plain",
+		"",
+		"plain",
+		"--inner",
+		"Content-Type: text/html",
+		"",
+		"<p>html</p>",
+		"--inner--",
+		"--outer--",
+	}, "\r\n")
+
+	for _, mediaType := range []string{"multipart/mixed", "multipart/related"} {
+		t.Run(mediaType, func(t *testing.T) {
+			bodyInfo := NewBodyService().ParseBody([]byte(body), mediaType+"; boundary=outer")
+
+			if bodyInfo.Content != nil {
+				t.Errorf("Expected no field map for %s, got %v", mediaType, bodyInfo.Content)
+			}
+			if len(bodyInfo.Parts) != 2 {
+				t.Fatalf("Expected 2 parts, got %d", len(bodyInfo.Parts))
+			}
+			if bodyInfo.Parts[0].Content != `{"id":1}` {
+				t.Errorf("Unexpected first part content: %v", bodyInfo.Parts[0].Content)
+			}
+			nested := bodyInfo.Parts[1].Parts
+			if len(nested) != 2 || nested[1].Content != "<p>html</p>" {
+				t.Errorf("Expected nested multipart to be expanded, got %+v", bodyInfo.Parts[1])
+			}
+		})
+	}
+}
+
+func TestBodyService_MultipartPartTruncated(t *testing.T) {
+	// A multi-byte character straddles the cut-off point
+	content := strings.Repeat("a", maxPartContentSize-1) + "é" + "tail"
+	body := "--b\r\nContent-Disposition: form-data; name=\"big\"\r\n\r\n" + content + "\r\n--b--"
+
+	part := NewBodyService().ParseBody([]byte(body), "multipart/form-data; boundary=b").Parts[0]
+
+	if !part.Truncated || part.Size != len(content) {
+		t.Errorf("Expected truncated part of size %d, got %+v", len(content), part.Size)
+	}
+	text, ok := part.Content.(string)
+	if !ok || len(text) != maxPartContentSize-1 {
+		t.Errorf("Expected text content cut before the multi-byte character, got %d bytes", len(text))
+	}
+}
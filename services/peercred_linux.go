@@ -0,0 +1,34 @@
+//go:build linux
+
+package services
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// peerCredentials reads SO_PEERCRED from a Unix domain socket connection
+func peerCredentials(conn *net.UnixConn) *models.PeerCredentials {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	var ucred *syscall.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		// #nosec G115 -- file descriptors always fit in an int
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil || credErr != nil || ucred == nil {
+		return nil
+	}
+
+	return &models.PeerCredentials{
+		UID: ucred.Uid,
+		GID: ucred.Gid,
+		PID: ucred.Pid,
+	}
+}
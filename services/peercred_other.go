@@ -0,0 +1,14 @@
+//go:build !linux
+
+package services
+
+import (
+	"net"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// peerCredentials is not supported on this platform
+func peerCredentials(_ *net.UnixConn) *models.PeerCredentials {
+	return nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// ProtobufMessageTypeHeader names the message type of a protobuf request body
+	ProtobufMessageTypeHeader = "X-Protobuf-Message-Type"
+
+	// maxProtobufWireDepth is the deepest nesting explored when dumping protobuf wire format
+	maxProtobufWireDepth = 32
+)
+
+// ProtobufService decodes protobuf bodies using message types loaded from descriptor sets
+type ProtobufService struct {
+	files        *protoregistry.Files
+	types        *dynamicpb.Types
+	contentTypes map[string]string
+}
+
+// NewProtobufService creates a new protobuf service configured from the environment.
+// PROTOBUF_DESCRIPTOR_SETS lists FileDescriptorSet files (as written by protoc --descriptor_set_out
+// --include_imports) and PROTOBUF_MESSAGE_TYPES maps media types to message types.
+func NewProtobufService() (*ProtobufService, error) {
+	var paths []string
+	for _, path := range strings.Split(os.Getenv("PROTOBUF_DESCRIPTOR_SETS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	files, err := LoadDescriptorSets(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	contentTypes, err := parseProtobufMessageTypes(os.Getenv("PROTOBUF_MESSAGE_TYPES"))
+	if err != nil {
+		return nil, err
+	}
+
+	for mediaType, messageType := range contentTypes {
+		if _, findErr := files.FindDescriptorByName(protoreflect.FullName(messageType)); findErr != nil {
+			return nil, fmt.Errorf("message type %s for %s not found in descriptor sets", messageType, mediaType)
+		}
+	}
+
+	return &ProtobufService{
+		files:        files,
+		types:        dynamicpb.NewTypes(files),
+		contentTypes: contentTypes,
+	}, nil
+}
+
+// LoadDescriptorSets reads FileDescriptorSet files into a single registry.
+// Files present in several sets are registered once.
+func LoadDescriptorSets(paths []string) (*protoregistry.Files, error) {
+	combined := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path) // #nosec G304 -- path comes from server configuration
+		if err != nil {
+			return nil, fmt.Errorf("failed to read descriptor set %s: %w", path, err)
+		}
+
+		set := &descriptorpb.FileDescriptorSet{}
+		if err = proto.Unmarshal(data, set); err != nil {
+			return nil, fmt.Errorf("failed to parse descriptor set %s: %w", path, err)
+		}
+
+		for _, file := range set.GetFile() {
+			if !seen[file.GetName()] {
+				seen[file.GetName()] = true
+				combined.File = append(combined.File, file)
+			}
+		}
+	}
+
+	files, err := protodesc.NewFiles(combined)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load descriptor sets: %w", err)
+	}
+	return files, nil
+}
+
+// parseProtobufMessageTypes parses a "media/type=package.Message,..." mapping
+func parseProtobufMessageTypes(spec string) (map[string]string, error) {
+	contentTypes := make(map[string]string)
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		mediaType, messageType, found := strings.Cut(entry, "=")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		messageType = strings.TrimPrefix(strings.TrimSpace(messageType), ".")
+		if !found || mediaType == "" || messageType == "" {
+			return nil, fmt.Errorf("invalid protobuf message type mapping %q, expected media/type=package.Message", entry)
+		}
+		contentTypes[mediaType] = messageType
+	}
+
+	return contentTypes, nil
+}
+
+// IsProtobufMediaType reports whether the media type denotes a protobuf body
+func IsProtobufMediaType(mediaType string) bool {
+	switch mediaType {
+	case "application/protobuf", "application/x-protobuf", "application/vnd.google.protobuf", "application/x-google-protobuf":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+proto") || strings.HasSuffix(mediaType, "+protobuf")
+}
+
+// Handles reports whether the media type is mapped to a message type
+func (s *ProtobufService) Handles(mediaType string) bool {
+	_, ok := s.contentTypes[mediaType]
+	return ok
+}
+
+// MessageType returns the message type for a body from its media type parameters
+// or the configured media type mapping
+func (s *ProtobufService) MessageType(mediaType string, params map[string]string) string {
+	if messageType := protobufMessageTypeParam(params); messageType != "" {
+		return messageType
+	}
+	return s.contentTypes[mediaType]
+}
+
+// protobufMessageTypeParam returns the message type named by the messageType or proto media type parameter
+func protobufMessageTypeParam(params map[string]string) string {
+	for _, param := range []string{"messagetype", "proto"} {
+		if messageType := params[param]; messageType != "" {
+			return strings.TrimPrefix(messageType, ".")
+		}
+	}
+	return ""
+}
+
+// Decode decodes a protobuf message of the named type and renders it as JSON-compatible values
+func (s *ProtobufService) Decode(data []byte, messageType string) (interface{}, error) {
+	messageType = strings.TrimPrefix(messageType, ".")
+	descriptor, err := s.files.FindDescriptorByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, fmt.Errorf("unknown message type %s", messageType)
+	}
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", messageType)
+	}
+
+	message := dynamicpb.NewMessage(messageDescriptor)
+	if err = (proto.UnmarshalOptions{Resolver: s.types}).Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+
+	jsonBytes, err := (protojson.MarshalOptions{Resolver: s.types}).Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if err = json.Unmarshal(jsonBytes, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DecodeProtobufWire dumps protobuf wire format without a schema. Length-delimited fields
+// that parse as a message are expanded, as the wire format cannot tell them apart from bytes.
+func DecodeProtobufWire(data []byte) ([]models.ProtobufWireField, error) {
+	return decodeProtobufWire(data, 0)
+}
+
+func decodeProtobufWire(data []byte, depth int) ([]models.ProtobufWireField, error) {
+	if depth >= maxProtobufWireDepth {
+		return nil, errors.New("protobuf: nesting too deep")
+	}
+
+	fields := []models.ProtobufWireField{}
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		field := models.ProtobufWireField{Number: int32(number)}
+		switch wireType {
+		case protowire.VarintType:
+			field.WireType = "varint"
+			field.Value, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed32Type:
+			field.WireType = "fixed32"
+			field.Value, n = protowire.ConsumeFixed32(data)
+		case protowire.Fixed64Type:
+			field.WireType = "fixed64"
+			field.Value, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(data)
+			field.WireType = "bytes"
+			field.Value, field.Message = describeProtobufBytes(value, depth)
+		case protowire.StartGroupType:
+			var value []byte
+			value, n = protowire.ConsumeGroup(number, data)
+			field.WireType = "group"
+			if n >= 0 {
+				field.Message, _ = decodeProtobufWire(value, depth+1)
+			}
+		default:
+			return nil, fmt.Errorf("protobuf: unexpected wire type %d", wireType)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// describeProtobufBytes renders a length-delimited value as text when it is printable, and otherwise
+// as raw bytes alongside its fields when it parses as a nested message
+func describeProtobufBytes(value []byte, depth int) (interface{}, []models.ProtobufWireField) {
+	if utf8.Valid(value) && isPrintableText(value) {
+		return string(value), nil
+	}
+	if nested, err := decodeProtobufWire(value, depth+1); err == nil {
+		return value, nested
+	}
+	return value, nil
+}
+
+// isPrintableText reports whether the data contains no control characters other than whitespace
+func isPrintableText(data []byte) bool {
+	for _, b := range data {
+		if b < minPrintableChar && b != charNewline && b != charCarriageReturn && b != charTab {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// orderDescriptorFile describes acme.v1.Order { string id = 1; int32 quantity = 2; repeated string tags = 3; }
+func orderDescriptorFile() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     fieldType.Enum(),
+			Label:    label.Enum(),
+		}
+	}
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("acme/v1/order.proto"),
+		Package: proto.String("acme.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+				field("quantity", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+				field("tags", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+			},
+		}},
+	}
+}
+
+// writeOrderDescriptorSet writes a descriptor set holding acme.v1.Order and returns its path
+func writeOrderDescriptorSet(t *testing.T) string {
+	t.Helper()
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{orderDescriptorFile()}})
+	if err != nil {
+		t.Fatalf("Failed to marshal descriptor set: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "order.pb")
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write descriptor set: %v", err)
+	}
+	return path
+}
+
+// encodeOrder encodes an acme.v1.Order message, writing the fields in field number order
+func encodeOrder() []byte {
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendString(data, "A-1")
+	data = protowire.AppendTag(data, 2, protowire.VarintType)
+	data = protowire.AppendVarint(data, 3)
+	data = protowire.AppendTag(data, 3, protowire.BytesType)
+	data = protowire.AppendString(data, "rush")
+	return data
+}
+
+func TestNewProtobufService(t *testing.T) {
+	path := writeOrderDescriptorSet(t)
+
+	t.Setenv("PROTOBUF_DESCRIPTOR_SETS", path+", "+path)
+	t.Setenv("PROTOBUF_MESSAGE_TYPES", "application/vnd.acme.order=.acme.v1.Order")
+	service, err := NewProtobufService()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !service.Handles("application/vnd.acme.order") {
+		t.Error("Expected mapped media type to be handled")
+	}
+	if got := service.MessageType("application/vnd.acme.order", nil); got != "acme.v1.Order" {
+		t.Errorf("Expected mapped message type, got %q", got)
+	}
+	if got := service.MessageType("application/x-protobuf", map[string]string{"messagetype": "acme.v1.Other"}); got != "acme.v1.Other" {
+		t.Errorf("Expected message type from parameter, got %q", got)
+	}
+
+	tests := map[string][2]string{
+		"missing file":     {filepath.Join(t.TempDir(), "missing.pb"), ""},
+		"unknown type":     {path, "application/vnd.acme.order=acme.v1.Missing"},
+		"invalid mapping":  {path, "application/vnd.acme.order"},
+		"not a descriptor": {writeFile(t, "garbage.pb", []byte{0xff, 0xff}), ""},
+	}
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("PROTOBUF_DESCRIPTOR_SETS", env[0])
+			t.Setenv("PROTOBUF_MESSAGE_TYPES", env[1])
+			if _, newErr := NewProtobufService(); newErr == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func newOrderBodyService(t *testing.T) *BodyService {
+	t.Helper()
+	t.Setenv("PROTOBUF_DESCRIPTOR_SETS", writeOrderDescriptorSet(t))
+	t.Setenv("PROTOBUF_MESSAGE_TYPES", "application/vnd.acme.order=acme.v1.Order")
+	protobuf, err := NewProtobufService()
+	if err != nil {
+		t.Fatalf("Failed to create protobuf service: %v", err)
+	}
+
+	service := NewBodyService()
+	service.SetProtobufService(protobuf)
+	return service
+}
+
+func TestBodyService_ParseProtobufWithDescriptor(t *testing.T) {
+	service := newOrderBodyService(t)
+	body := encodeOrder()
+
+	tests := []struct {
+		contentType string
+		messageType string
+	}{
+		{"application/x-protobuf; messageType=acme.v1.Order", ""},
+		{`application/protobuf; proto=".acme.v1.Order"`, ""},
+		{"application/vnd.acme.order", ""},
+		{"application/octet-stream", "acme.v1.Order"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			bodyInfo := service.ParseBodyAs(body, tt.contentType, tt.messageType)
+			if bodyInfo.Protobuf == nil || bodyInfo.Protobuf.MessageType != "acme.v1.Order" || bodyInfo.Protobuf.Schemaless {
+				t.Fatalf("Expected body decoded as acme.v1.Order, got %+v", bodyInfo.Protobuf)
+			}
+			content, ok := bodyInfo.Content.(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected content to be a map, got %T", bodyInfo.Content)
+			}
+			if content["id"] != "A-1" || content["quantity"] != float64(3) {
+				t.Errorf("Unexpected decoded message: %v", content)
+			}
+			if tags, isList := content["tags"].([]interface{}); !isList || len(tags) != 1 {
+				t.Errorf("Expected repeated field, got %v", content["tags"])
+			}
+		})
+	}
+}
+
+func TestBodyService_ParseProtobufUnknownType(t *testing.T) {
+	bodyInfo := newOrderBodyService(t).ParseBody(encodeOrder(), "application/x-protobuf; messageType=acme.v1.Missing")
+
+	if bodyInfo.Protobuf == nil || bodyInfo.Protobuf.Error == "" || !bodyInfo.Protobuf.Schemaless {
+		t.Errorf("Expected unknown type to fall back to the wire format with an error, got %+v", bodyInfo.Protobuf)
+	}
+	if _, ok := bodyInfo.Content.([]models.ProtobufWireField); !ok {
+		t.Errorf("Expected wire format dump, got %T", bodyInfo.Content)
+	}
+}
+
+func TestBodyService_ParseProtobufSchemaless(t *testing.T) {
+	// Without a protobuf service the body is dumped from the wire format
+	bodyInfo := NewBodyService().ParseBody(encodeOrder(), "application/x-protobuf")
+
+	if bodyInfo.Protobuf == nil || !bodyInfo.Protobuf.Schemaless || bodyInfo.Protobuf.Error != "" {
+		t.Fatalf("Expected schemaless decoding, got %+v", bodyInfo.Protobuf)
+	}
+	fields, ok := bodyInfo.Content.([]models.ProtobufWireField)
+	if !ok || len(fields) != 3 {
+		t.Fatalf("Expected 3 wire fields, got %v", bodyInfo.Content)
+	}
+	if fields[0].Number != 1 || fields[0].WireType != "bytes" || fields[0].Value != "A-1" {
+		t.Errorf("Unexpected first field: %+v", fields[0])
+	}
+	if fields[1].Number != 2 || fields[1].WireType != "varint" || fields[1].Value != uint64(3) {
+		t.Errorf("Unexpected second field: %+v", fields[1])
+	}
+}
+
+func TestDecodeProtobufWire(t *testing.T) {
+	var nested []byte
+	nested = protowire.AppendTag(nested, 1, protowire.VarintType)
+	nested = protowire.AppendVarint(nested, 150)
+
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendBytes(data, nested)
+	data = protowire.AppendTag(data, 2, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 7)
+	data = protowire.AppendTag(data, 3, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 9)
+	data = protowire.AppendTag(data, 4, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte{0xff, 0xfe})
+
+	fields, err := DecodeProtobufWire(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fields) != 4 {
+		t.Fatalf("Expected 4 fields, got %d", len(fields))
+	}
+	if len(fields[0].Message) != 1 || fields[0].Message[0].Value != uint64(150) {
+		t.Errorf("Expected nested message to be expanded, got %+v", fields[0])
+	}
+	if fields[1].WireType != "fixed32" || fields[1].Value != uint32(7) {
+		t.Errorf("Unexpected fixed32 field: %+v", fields[1])
+	}
+	if fields[2].WireType != "fixed64" || fields[2].Value != uint64(9) {
+		t.Errorf("Unexpected fixed64 field: %+v", fields[2])
+	}
+	if _, isBytes := fields[3].Value.([]byte); !isBytes || fields[3].Message != nil {
+		t.Errorf("Expected raw bytes, got %+v", fields[3])
+	}
+
+	if _, err = DecodeProtobufWire([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("Expected error for truncated length-delimited field")
+	}
+}
+
+func TestBodyService_ParseProtobufInvalid(t *testing.T) {
+	bodyInfo := NewBodyService().ParseBody([]byte{0x0a, 0x05, 0x01}, "application/x-protobuf")
+
+	if !bodyInfo.IsBinary {
+		t.Error("Expected invalid protobuf to fall back to binary data")
+	}
+	if bodyInfo.Protobuf == nil || bodyInfo.Protobuf.Error == "" {
+		t.Errorf("Expected wire format error to be reported, got %+v", bodyInfo.Protobuf)
+	}
+}
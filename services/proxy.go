@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ullbergm/echo-server/models"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// DefaultProxyTimeout bounds dialing tunnel targets and forwarded requests
+	DefaultProxyTimeout = 10 * time.Second
+)
+
+// hopByHopHeaders are connection-specific headers that are not forwarded (RFC 9110, 7.6.1)
+var hopByHopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "TE", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// ProxyService implements the optional forward proxy mode
+type ProxyService struct {
+	allowedTargets map[string]bool
+	client         *fasthttp.Client
+	timeout        time.Duration
+	enabled        bool
+}
+
+// NewProxyService creates a new proxy service configured from the environment
+func NewProxyService() *ProxyService {
+	enabled := false
+	if enabledEnv := os.Getenv("PROXY_MODE_ENABLED"); enabledEnv != "" {
+		if parsed, err := strconv.ParseBool(enabledEnv); err == nil {
+			enabled = parsed
+		}
+	}
+
+	allowed := make(map[string]bool)
+	if targets := os.Getenv("PROXY_ALLOWED_TARGETS"); targets != "" {
+		for _, target := range strings.Split(targets, ",") {
+			if target = strings.ToLower(strings.TrimSpace(target)); target != "" {
+				allowed[target] = true
+			}
+		}
+	}
+
+	return &ProxyService{
+		enabled:        enabled,
+		allowedTargets: allowed,
+		timeout:        DefaultProxyTimeout,
+		client: &fasthttp.Client{
+			NoDefaultUserAgentHeader:      true,
+			DisableHeaderNamesNormalizing: true,
+			DisablePathNormalizing:        true,
+		},
+	}
+}
+
+// Enabled reports whether forward proxy mode is enabled
+func (s *ProxyService) Enabled() bool {
+	return s.enabled
+}
+
+// IsAllowed reports whether a host:port target may be tunneled or forwarded to
+func (s *ProxyService) IsAllowed(target string) bool {
+	return s.allowedTargets[strings.ToLower(target)]
+}
+
+// Dial connects to a tunnel target
+func (s *ProxyService) Dial(target string) (net.Conn, error) {
+	if !s.IsAllowed(target) {
+		return nil, fmt.Errorf("target %s is not allowed", target)
+	}
+	return net.DialTimeout("tcp", target, s.timeout)
+}
+
+// Tunnel copies data in both directions until either side is done
+func (s *ProxyService) Tunnel(client, upstream net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// The tunnel is long-lived, so clear any deadline the server set for the request
+	_ = client.SetDeadline(time.Time{})
+
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+
+		// Hijacked connections ignore Close, so act on the underlying connection
+		if unwrapped, ok := dst.(interface{ UnsafeConn() net.Conn }); ok {
+			dst = unwrapped.UnsafeConn()
+		}
+		// Propagate the half-close so the other direction can finish
+		if halfCloser, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = halfCloser.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+
+	go pipe(upstream, client)
+	go pipe(client, upstream)
+	wg.Wait()
+}
+
+// Forward sends an absolute-form request to its allowed target and stores the upstream response
+func (s *ProxyService) Forward(req *fasthttp.Request, resp *fasthttp.Response) error {
+	target := ProxyTarget(req.URI())
+	if !s.IsAllowed(target) {
+		return fmt.Errorf("target %s is not allowed", target)
+	}
+
+	outReq := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(outReq)
+	req.CopyTo(outReq)
+	for _, header := range hopByHopHeaders {
+		outReq.Header.Del(header)
+	}
+
+	if err := s.client.DoTimeout(outReq, resp, s.timeout); err != nil {
+		return err
+	}
+	for _, header := range hopByHopHeaders {
+		resp.Header.Del(header)
+	}
+	return nil
+}
+
+// ProxyTarget returns the host:port an absolute-form URI points at, adding the scheme's default port
+func ProxyTarget(uri *fasthttp.URI) string {
+	host := string(uri.Host())
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	port := "80"
+	if strings.EqualFold(string(uri.Scheme()), "https") {
+		port = "443"
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// ParseProxyAuthorization decodes a Proxy-Authorization (or Authorization) header value.
+// Basic credentials are split into username and password; other schemes keep their credentials.
+func ParseProxyAuthorization(value string) *models.ProxyAuthInfo {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	scheme, credentials, _ := strings.Cut(value, " ")
+	info := &models.ProxyAuthInfo{
+		Scheme:      scheme,
+		Credentials: strings.TrimSpace(credentials),
+	}
+
+	if !strings.EqualFold(scheme, "basic") {
+		return info
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(info.Credentials)
+	if err != nil {
+		info.Error = "invalid base64 in basic credentials"
+		return info
+	}
+
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		info.Error = "basic credentials missing ':' separator"
+		return info
+	}
+	info.Username = username
+	info.Password = password
+
+	return info
+}
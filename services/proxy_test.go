@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestNewProxyService(t *testing.T) {
+	t.Setenv("PROXY_MODE_ENABLED", "true")
+	t.Setenv("PROXY_ALLOWED_TARGETS", " Backend.local:8080 , 127.0.0.1:9000,")
+
+	service := NewProxyService()
+	if !service.Enabled() {
+		t.Error("Expected proxy mode to be enabled")
+	}
+
+	tests := map[string]bool{
+		"backend.local:8080": true,
+		"BACKEND.LOCAL:8080": true,
+		"127.0.0.1:9000":     true,
+		"127.0.0.1:9001":     false,
+		"backend.local":      false,
+		"":                   false,
+	}
+	for target, expected := range tests {
+		if got := service.IsAllowed(target); got != expected {
+			t.Errorf("IsAllowed(%q) = %v, expected %v", target, got, expected)
+		}
+	}
+}
+
+func TestNewProxyService_DisabledByDefault(t *testing.T) {
+	t.Setenv("PROXY_MODE_ENABLED", "")
+	t.Setenv("PROXY_ALLOWED_TARGETS", "")
+
+	service := NewProxyService()
+	if service.Enabled() {
+		t.Error("Expected proxy mode to be disabled by default")
+	}
+	if service.IsAllowed("localhost:80") {
+		t.Error("Expected no targets to be allowed by default")
+	}
+}
+
+func TestParseProxyAuthorization(t *testing.T) {
+	// dXNlcjpwYXNz = user:pass
+	info := ParseProxyAuthorization("Basic dXNlcjpwYXNz")
+	if info == nil || info.Scheme != "Basic" || info.Username != "user" || info.Password != "pass" {
+		t.Errorf("Unexpected basic credentials: %+v", info)
+	}
+
+	info = ParseProxyAuthorization("Bearer abc.def")
+	if info == nil || info.Scheme != "Bearer" || info.Credentials != "abc.def" || info.Username != "" {
+		t.Errorf("Unexpected bearer credentials: %+v", info)
+	}
+
+	info = ParseProxyAuthorization("Basic not-base64!")
+	if info == nil || info.Error == "" {
+		t.Errorf("Expected error for invalid base64, got %+v", info)
+	}
+
+	// dXNlcg== = user
+	info = ParseProxyAuthorization("Basic dXNlcg==")
+	if info == nil || info.Error == "" {
+		t.Errorf("Expected error for missing separator, got %+v", info)
+	}
+
+	if empty := ParseProxyAuthorization("  "); empty != nil {
+		t.Errorf("Expected nil for empty header, got %+v", empty)
+	}
+}
+
+func TestProxyTarget(t *testing.T) {
+	tests := map[string]string{
+		"http://example.com/a":       "example.com:80",
+		"https://example.com/a":      "example.com:443",
+		"http://example.com:8080/a":  "example.com:8080",
+		"http://[::1]/a":             "[::1]:80",
+		"https://[2001:db8::1]:8443": "[2001:db8::1]:8443",
+	}
+
+	for input, expected := range tests {
+		uri := fasthttp.AcquireURI()
+		if err := uri.Parse(nil, []byte(input)); err != nil {
+			t.Fatalf("Failed to parse %s: %v", input, err)
+		}
+		if got := ProxyTarget(uri); got != expected {
+			t.Errorf("ProxyTarget(%s) = %s, expected %s", input, got, expected)
+		}
+		fasthttp.ReleaseURI(uri)
+	}
+}
+
+func TestProxyService_DialRejectsUnlistedTarget(t *testing.T) {
+	t.Setenv("PROXY_ALLOWED_TARGETS", "")
+
+	if _, err := NewProxyService().Dial("127.0.0.1:1"); err == nil {
+		t.Error("Expected error dialing a target that is not allowed")
+	}
+}
+
+func TestProxyService_Tunnel(t *testing.T) {
+	clientSide, proxyClientSide := net.Pipe()
+	proxyUpstreamSide, upstreamSide := net.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		NewProxyService().Tunnel(proxyClientSide, proxyUpstreamSide)
+		close(done)
+	}()
+
+	// Upstream echoes one line back and closes
+	go func() {
+		line, _ := bufio.NewReader(upstreamSide).ReadString('\n')
+		_, _ = upstreamSide.Write([]byte("echo: " + line))
+		upstreamSide.Close()
+	}()
+
+	if _, err := clientSide.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Failed to write to tunnel: %v", err)
+	}
+	reply, err := io.ReadAll(clientSide)
+	if err != nil {
+		t.Fatalf("Failed to read from tunnel: %v", err)
+	}
+	if string(reply) != "echo: hello\n" {
+		t.Errorf("Expected tunneled reply, got %q", reply)
+	}
+
+	clientSide.Close()
+	<-done
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// SchemaRoute binds a JSON Schema to requests matching a method and path pattern
+type SchemaRoute struct {
+	schema     *jsonschema.Schema
+	Method     string
+	Pattern    string
+	SchemaFile string
+}
+
+// SchemaService validates JSON request bodies against the JSON Schema bound to their route
+type SchemaService struct {
+	routes        []SchemaRoute
+	failureStatus int
+}
+
+// NewSchemaService creates a new schema service configured from the environment.
+// JSON_SCHEMA_ROUTES binds schema files to "[METHOD] /path/pattern" routes and
+// JSON_SCHEMA_FAILURE_STATUS sets the status returned for bodies that fail validation.
+func NewSchemaService() (*SchemaService, error) {
+	routes, err := parseSchemaRoutes(os.Getenv("JSON_SCHEMA_ROUTES"))
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	for i := range routes {
+		routes[i].schema, err = compiler.Compile(routes[i].SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile JSON schema %s: %w", routes[i].SchemaFile, err)
+		}
+	}
+
+	failureStatus := 0
+	if statusEnv := os.Getenv("JSON_SCHEMA_FAILURE_STATUS"); statusEnv != "" {
+		failureStatus, err = strconv.Atoi(statusEnv)
+		if err != nil || failureStatus < 100 || failureStatus > 599 {
+			return nil, fmt.Errorf("invalid JSON_SCHEMA_FAILURE_STATUS %q", statusEnv)
+		}
+	}
+
+	return &SchemaService{
+		routes:        routes,
+		failureStatus: failureStatus,
+	}, nil
+}
+
+// ValidateSchema validates a body against the JSON Schema bound to the request's route.
+// The result is nil when no schema service is set or no schema applies to the route.
+func (s *BodyService) ValidateSchema(method, requestPath, contentType string, body []byte) *models.SchemaValidation {
+	if s.schema == nil {
+		return nil
+	}
+	return s.schema.Validate(method, requestPath, contentType, body)
+}
+
+// SchemaFailureStatus returns the status to respond with when a body fails schema validation, or 0 to respond as usual
+func (s *BodyService) SchemaFailureStatus() int {
+	if s.schema == nil {
+		return 0
+	}
+	return s.schema.FailureStatus()
+}
+
+// parseSchemaRoutes parses a "[METHOD] /path/pattern=schema.json,..." list of routes
+func parseSchemaRoutes(spec string) ([]SchemaRoute, error) {
+	var routes []SchemaRoute
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, schemaFile, found := strings.Cut(entry, "=")
+		schemaFile = strings.TrimSpace(schemaFile)
+		fields := strings.Fields(route)
+		if !found || schemaFile == "" || len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid JSON schema route %q, expected [METHOD] /path=schema.json", entry)
+		}
+
+		schemaRoute := SchemaRoute{Pattern: fields[len(fields)-1], SchemaFile: schemaFile}
+		if len(fields) == 2 {
+			schemaRoute.Method = strings.ToUpper(fields[0])
+		}
+		if !strings.HasPrefix(schemaRoute.Pattern, "/") {
+			return nil, fmt.Errorf("invalid JSON schema route %q, path pattern must start with /", entry)
+		}
+		if _, err := path.Match(schemaRoute.Pattern, "/"); err != nil {
+			return nil, fmt.Errorf("invalid JSON schema route %q: %w", entry, err)
+		}
+
+		routes = append(routes, schemaRoute)
+	}
+
+	return routes, nil
+}
+
+// FailureStatus returns the status to respond with when validation fails, or 0 to respond as usual
+func (s *SchemaService) FailureStatus() int {
+	return s.failureStatus
+}
+
+// Route returns the first route matching the method and path, or nil when none does
+func (s *SchemaService) Route(method, requestPath string) *SchemaRoute {
+	for i := range s.routes {
+		route := &s.routes[i]
+		if route.Method != "" && route.Method != method {
+			continue
+		}
+		if matchRoutePattern(route.Pattern, requestPath) {
+			return route
+		}
+	}
+	return nil
+}
+
+// matchRoutePattern matches a path against a glob pattern in which * matches a single segment
+// and a trailing /** matches any number of segments
+func matchRoutePattern(pattern, requestPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		segments := strings.Count(prefix, "/")
+		// Match the prefix against the same number of leading segments
+		cut := len(requestPath)
+		for i, slashes := 0, 0; i < len(requestPath); i++ {
+			if requestPath[i] == '/' {
+				if slashes == segments {
+					cut = i
+					break
+				}
+				slashes++
+			}
+		}
+		matched, _ := path.Match(prefix, requestPath[:cut])
+		return matched
+	}
+
+	matched, _ := path.Match(pattern, requestPath)
+	return matched
+}
+
+// Validate validates a body against the schema bound to the request's route.
+// The result is nil when no schema applies to the route.
+func (s *SchemaService) Validate(method, requestPath, contentType string, body []byte) *models.SchemaValidation {
+	route := s.Route(method, requestPath)
+	if route == nil {
+		return nil
+	}
+
+	result := &models.SchemaValidation{
+		Route:  strings.TrimSpace(route.Method + " " + route.Pattern),
+		Schema: route.SchemaFile,
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		result.Errors = []models.SchemaError{{Message: fmt.Sprintf("expected a JSON body, got %q", mediaType)}}
+		return result
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		result.Errors = []models.SchemaError{{Message: "body is not valid JSON: " + err.Error()}}
+		return result
+	}
+
+	err = route.schema.Validate(instance)
+	var validationErr *jsonschema.ValidationError
+	switch {
+	case err == nil:
+		result.Valid = true
+	case errors.As(err, &validationErr):
+		result.Errors = schemaErrors(validationErr.BasicOutput())
+	default:
+		result.Errors = []models.SchemaError{{Message: err.Error()}}
+	}
+
+	return result
+}
+
+// schemaErrors flattens validation output into errors located by JSON pointers
+func schemaErrors(output *jsonschema.OutputUnit) []models.SchemaError {
+	errs := []models.SchemaError{}
+	for _, unit := range output.Errors {
+		if unit.Error == nil {
+			continue
+		}
+		errs = append(errs, models.SchemaError{
+			InstanceLocation: unit.InstanceLocation,
+			KeywordLocation:  unit.KeywordLocation,
+			Message:          unit.Error.String(),
+		})
+	}
+	if len(errs) == 0 && output.Error != nil {
+		errs = append(errs, models.SchemaError{
+			InstanceLocation: output.InstanceLocation,
+			KeywordLocation:  output.KeywordLocation,
+			Message:          output.Error.String(),
+		})
+	}
+	return errs
+}
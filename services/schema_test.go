@@ -0,0 +1,176 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const orderSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "items"],
+	"properties": {
+		"id": {"type": "string"},
+		"items": {"type": "array", "items": {"type": "integer", "minimum": 1}}
+	}
+}`
+
+// writeOrderSchema writes the order schema to a temporary file and returns its path
+func writeOrderSchema(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "order.json")
+	if err := os.WriteFile(path, []byte(orderSchema), 0o600); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+	return path
+}
+
+func TestParseSchemaRoutes(t *testing.T) {
+	routes, err := parseSchemaRoutes(" post /orders = order.json, /users/*/profile=profile.json ,")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %d", len(routes))
+	}
+	if routes[0].Method != "POST" || routes[0].Pattern != "/orders" || routes[0].SchemaFile != "order.json" {
+		t.Errorf("Unexpected first route %+v", routes[0])
+	}
+	if routes[1].Method != "" || routes[1].Pattern != "/users/*/profile" || routes[1].SchemaFile != "profile.json" {
+		t.Errorf("Unexpected second route %+v", routes[1])
+	}
+
+	for _, spec := range []string{"/orders", "/orders=", "=order.json", "POST orders=order.json", "POST /a /b=x.json", "/[=x.json"} {
+		if _, specErr := parseSchemaRoutes(spec); specErr == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestMatchRoutePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/orders", "/orders", true},
+		{"/orders", "/orders/1", false},
+		{"/orders/*", "/orders/1", true},
+		{"/orders/*", "/orders/1/items", false},
+		{"/orders/**", "/orders", true},
+		{"/orders/**", "/orders/1/items", true},
+		{"/orders/**", "/ordersx/1", false},
+		{"/*/items/**", "/orders/items/2", true},
+		{"/*/items/**", "/orders/lines/2", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchRoutePattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchRoutePattern(%q, %q) = %t, want %t", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNewSchemaService(t *testing.T) {
+	t.Setenv("JSON_SCHEMA_ROUTES", "POST /orders/**="+writeOrderSchema(t))
+	t.Setenv("JSON_SCHEMA_FAILURE_STATUS", "422")
+
+	service, err := NewSchemaService()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if service.FailureStatus() != 422 {
+		t.Errorf("Expected failure status 422, got %d", service.FailureStatus())
+	}
+	if service.Route("POST", "/orders/1") == nil {
+		t.Error("Expected POST /orders/1 to match")
+	}
+	if service.Route("PUT", "/orders/1") != nil {
+		t.Error("Expected PUT /orders/1 not to match")
+	}
+}
+
+func TestNewSchemaServiceErrors(t *testing.T) {
+	tests := map[string][2]string{
+		"missing schema": {"/orders=" + filepath.Join(t.TempDir(), "missing.json"), ""},
+		"invalid route":  {"orders", ""},
+		"invalid status": {"", "abc"},
+		"status range":   {"", "99"},
+	}
+
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("JSON_SCHEMA_ROUTES", env[0])
+			t.Setenv("JSON_SCHEMA_FAILURE_STATUS", env[1])
+			if _, err := NewSchemaService(); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestBodyService_ValidateSchema(t *testing.T) {
+	t.Setenv("JSON_SCHEMA_ROUTES", "POST /orders="+writeOrderSchema(t))
+	schemaService, err := NewSchemaService()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	service := NewBodyService()
+	if result := service.ValidateSchema("POST", "/orders", "application/json", []byte(`{}`)); result != nil {
+		t.Errorf("Expected no validation without a schema service, got %+v", result)
+	}
+	service.SetSchemaService(schemaService)
+
+	t.Run("valid", func(t *testing.T) {
+		result := service.ValidateSchema("POST", "/orders", "application/json; charset=utf-8", []byte(`{"id":"A1","items":[1,2]}`))
+		if result == nil || !result.Valid || len(result.Errors) != 0 {
+			t.Errorf("Expected valid result, got %+v", result)
+		}
+		if result != nil && result.Route != "POST /orders" {
+			t.Errorf("Expected route POST /orders, got %q", result.Route)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		result := service.ValidateSchema("POST", "/orders", "application/vnd.acme+json", []byte(`{"id":7,"items":[1,0]}`))
+		if result == nil || result.Valid {
+			t.Fatalf("Expected invalid result, got %+v", result)
+		}
+		locations := make(map[string]string)
+		for _, schemaErr := range result.Errors {
+			locations[schemaErr.InstanceLocation] = schemaErr.KeywordLocation
+		}
+		if locations["/id"] != "/properties/id/type" {
+			t.Errorf("Expected type error at /id, got %+v", result.Errors)
+		}
+		if locations["/items/1"] != "/properties/items/items/minimum" {
+			t.Errorf("Expected minimum error at /items/1, got %+v", result.Errors)
+		}
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		result := service.ValidateSchema("POST", "/orders", "application/json", []byte(`{"id":`))
+		if result == nil || result.Valid || len(result.Errors) != 1 {
+			t.Errorf("Expected a single parse error, got %+v", result)
+		}
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		result := service.ValidateSchema("POST", "/orders", "text/plain", []byte(`{"id":"A1","items":[]}`))
+		if result == nil || result.Valid || len(result.Errors) != 1 {
+			t.Errorf("Expected a content type error, got %+v", result)
+		}
+	})
+
+	t.Run("unbound route", func(t *testing.T) {
+		if result := service.ValidateSchema("POST", "/users", "application/json", []byte(`{}`)); result != nil {
+			t.Errorf("Expected no validation, got %+v", result)
+		}
+	})
+
+	if service.SchemaFailureStatus() != 0 {
+		t.Errorf("Expected no failure status, got %d", service.SchemaFailureStatus())
+	}
+}
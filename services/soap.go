@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// SOAP envelope namespaces
+const (
+	SOAP11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	SOAP12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// SOAPFault describes a simulated SOAP fault
+type SOAPFault struct {
+	Code   string
+	String string
+}
+
+// ParseSOAPEnvelope inspects an XML document and describes it if it is a SOAP 1.1 or 1.2 envelope.
+// It returns nil for any other document.
+func ParseSOAPEnvelope(data []byte) *models.SOAPInfo {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var info *models.SOAPInfo
+	depth := 0
+	section := ""
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 1:
+				// Root element must be a SOAP envelope
+				if t.Name.Local != "Envelope" {
+					return nil
+				}
+				switch t.Name.Space {
+				case SOAP11Namespace:
+					info = &models.SOAPInfo{Version: "1.1"}
+				case SOAP12Namespace:
+					info = &models.SOAPInfo{Version: "1.2"}
+				default:
+					return nil
+				}
+			case 2:
+				if t.Name.Space == soapNamespace(info.Version) {
+					section = t.Name.Local
+				}
+			case 3:
+				switch section {
+				case "Header":
+					info.HeaderBlocks = append(info.HeaderBlocks, soapHeaderBlock(t, info.Version))
+				case "Body":
+					if info.Operation == "" {
+						info.Operation = t.Name.Local
+						info.OperationNamespace = t.Name.Space
+					}
+				}
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				section = ""
+			}
+			depth--
+		}
+	}
+
+	return info
+}
+
+// soapHeaderBlock describes a header block element
+func soapHeaderBlock(el xml.StartElement, version string) models.SOAPHeaderBlock {
+	block := models.SOAPHeaderBlock{
+		Name:      el.Name.Local,
+		Namespace: el.Name.Space,
+	}
+
+	for _, attr := range el.Attr {
+		if attr.Name.Space != soapNamespace(version) {
+			continue
+		}
+		switch attr.Name.Local {
+		case "mustUnderstand":
+			block.MustUnderstand = attr.Value == "1" || attr.Value == "true"
+		case "actor", "role":
+			block.Role = attr.Value
+		}
+	}
+
+	return block
+}
+
+// soapNamespace returns the envelope namespace for a SOAP version
+func soapNamespace(version string) string {
+	if version == "1.2" {
+		return SOAP12Namespace
+	}
+	return SOAP11Namespace
+}
+
+// SOAPContentType returns the response Content-Type for a SOAP version
+func SOAPContentType(version string) string {
+	if version == "1.2" {
+		return "application/soap+xml; charset=utf-8"
+	}
+	return "text/xml; charset=utf-8"
+}
+
+// SOAPFaultStatus returns the HTTP status code for a fault under the SOAP HTTP binding:
+// 400 for SOAP 1.2 Sender faults and 500 for everything else
+func SOAPFaultStatus(version, faultCode string) int {
+	if version == "1.2" && normalizeSOAPFaultCode(version, faultCode) == "Sender" {
+		return 400
+	}
+	return 500
+}
+
+// normalizeSOAPFaultCode maps a requested fault code onto the standard codes of a SOAP version.
+// Client/Sender and Server/Receiver are interchangeable; unknown codes are returned unchanged.
+func normalizeSOAPFaultCode(version, code string) string {
+	if idx := strings.Index(code, ":"); idx != -1 {
+		code = code[idx+1:]
+	}
+
+	base, suffix := code, ""
+	if idx := strings.Index(code, "."); idx != -1 {
+		base, suffix = code[:idx], code[idx:]
+	}
+
+	var standard string
+	switch strings.ToLower(base) {
+	case "client", "sender":
+		standard = "Client"
+		if version == "1.2" {
+			standard = "Sender"
+		}
+	case "server", "receiver":
+		standard = "Server"
+		if version == "1.2" {
+			standard = "Receiver"
+		}
+	case "versionmismatch":
+		standard = "VersionMismatch"
+	case "mustunderstand":
+		standard = "MustUnderstand"
+	case "dataencodingunknown":
+		if version == "1.2" {
+			standard = "DataEncodingUnknown"
+		}
+	}
+
+	if standard == "" {
+		return code
+	}
+	// Dotted fault code extensions are only defined for SOAP 1.1
+	if version == "1.2" {
+		return standard
+	}
+	return standard + suffix
+}
+
+// BuildSOAPResponse renders a SOAP envelope echoing the request, or a fault when fault is set
+func BuildSOAPResponse(info *models.SOAPInfo, fault *SOAPFault) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<soap:Envelope xmlns:soap="`)
+	buf.WriteString(soapNamespace(info.Version))
+	buf.WriteString(`"><soap:Body>`)
+
+	if fault != nil {
+		writeSOAPFault(&buf, info.Version, fault)
+	} else {
+		writeSOAPEcho(&buf, info)
+	}
+
+	buf.WriteString(`</soap:Body></soap:Envelope>`)
+	return buf.Bytes()
+}
+
+// writeSOAPEcho writes the <OperationResponse> element echoing the request envelope
+func writeSOAPEcho(buf *bytes.Buffer, info *models.SOAPInfo) {
+	operation := info.Operation
+	if operation == "" {
+		operation = "Echo"
+	}
+
+	buf.WriteString("<" + operation + "Response")
+	if info.OperationNamespace != "" {
+		buf.WriteString(` xmlns="`)
+		writeEscapedXML(buf, info.OperationNamespace)
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+
+	writeSOAPElement(buf, "soapVersion", info.Version)
+	writeSOAPElement(buf, "soapAction", info.Action)
+	writeSOAPElement(buf, "operation", info.Operation)
+	writeSOAPElement(buf, "operationNamespace", info.OperationNamespace)
+
+	buf.WriteString("<headerBlocks>")
+	for _, block := range info.HeaderBlocks {
+		buf.WriteString("<headerBlock>")
+		writeSOAPElement(buf, "name", block.Name)
+		writeSOAPElement(buf, "namespace", block.Namespace)
+		writeSOAPElement(buf, "role", block.Role)
+		if block.MustUnderstand {
+			writeSOAPElement(buf, "mustUnderstand", "true")
+		}
+		buf.WriteString("</headerBlock>")
+	}
+	buf.WriteString("</headerBlocks>")
+
+	buf.WriteString("</" + operation + "Response>")
+}
+
+// writeSOAPFault writes a Fault element in the format of the SOAP version
+func writeSOAPFault(buf *bytes.Buffer, version string, fault *SOAPFault) {
+	code := normalizeSOAPFaultCode(version, fault.Code)
+	reason := fault.String
+	if reason == "" {
+		reason = "Simulated fault"
+	}
+
+	if version != "1.2" {
+		buf.WriteString("<soap:Fault><faultcode>")
+		if !strings.Contains(code, ":") {
+			buf.WriteString("soap:")
+		}
+		writeEscapedXML(buf, code)
+		buf.WriteString("</faultcode><faultstring>")
+		writeEscapedXML(buf, reason)
+		buf.WriteString("</faultstring></soap:Fault>")
+		return
+	}
+
+	// SOAP 1.2 only allows standard values in Code/Value; anything else becomes a Receiver subcode
+	buf.WriteString("<soap:Fault><soap:Code><soap:Value>")
+	switch code {
+	case "Sender", "Receiver", "VersionMismatch", "MustUnderstand", "DataEncodingUnknown":
+		buf.WriteString("soap:" + code + "</soap:Value>")
+	default:
+		buf.WriteString("soap:Receiver</soap:Value><soap:Subcode><soap:Value>")
+		writeEscapedXML(buf, code)
+		buf.WriteString("</soap:Value></soap:Subcode>")
+	}
+	buf.WriteString(`</soap:Code><soap:Reason><soap:Text xml:lang="en">`)
+	writeEscapedXML(buf, reason)
+	buf.WriteString("</soap:Text></soap:Reason></soap:Fault>")
+}
+
+// writeSOAPElement writes <name>value</name>, skipping empty values
+func writeSOAPElement(buf *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	buf.WriteString("<" + name + ">")
+	writeEscapedXML(buf, value)
+	buf.WriteString("</" + name + ">")
+}
+
+// writeEscapedXML writes text with XML special characters escaped
+func writeEscapedXML(buf *bytes.Buffer, text string) {
+	_ = xml.EscapeText(buf, []byte(text))
+}
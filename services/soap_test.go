@@ -0,0 +1,214 @@
+package services
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const soap11Request = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:sec="urn:security">
+  <soap:Header>
+    <sec:Token soap:mustUnderstand="1" soap:actor="urn:gateway">abc</sec:Token>
+    <Trace xmlns="urn:trace">123</Trace>
+  </soap:Header>
+  <soap:Body>
+    <m:GetOrder xmlns:m="urn:orders"><m:Id>42</m:Id></m:GetOrder>
+  </soap:Body>
+</soap:Envelope>`
+
+const soap12Request = `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+  <env:Body><CreateUser xmlns="urn:users"/></env:Body>
+</env:Envelope>`
+
+func TestParseSOAPEnvelope_SOAP11(t *testing.T) {
+	info := ParseSOAPEnvelope([]byte(soap11Request))
+	if info == nil {
+		t.Fatal("Expected SOAP envelope to be detected")
+	}
+
+	if info.Version != "1.1" {
+		t.Errorf("Expected version 1.1, got %s", info.Version)
+	}
+	if info.Operation != "GetOrder" || info.OperationNamespace != "urn:orders" {
+		t.Errorf("Expected operation {urn:orders}GetOrder, got {%s}%s", info.OperationNamespace, info.Operation)
+	}
+
+	if len(info.HeaderBlocks) != 2 {
+		t.Fatalf("Expected 2 header blocks, got %d", len(info.HeaderBlocks))
+	}
+
+	token := info.HeaderBlocks[0]
+	if token.Name != "Token" || token.Namespace != "urn:security" {
+		t.Errorf("Unexpected first header block: %+v", token)
+	}
+	if !token.MustUnderstand {
+		t.Error("Expected mustUnderstand to be true")
+	}
+	if token.Role != "urn:gateway" {
+		t.Errorf("Expected actor urn:gateway, got %s", token.Role)
+	}
+
+	if info.HeaderBlocks[1].Name != "Trace" || info.HeaderBlocks[1].MustUnderstand {
+		t.Errorf("Unexpected second header block: %+v", info.HeaderBlocks[1])
+	}
+}
+
+func TestParseSOAPEnvelope_SOAP12(t *testing.T) {
+	info := ParseSOAPEnvelope([]byte(soap12Request))
+	if info == nil {
+		t.Fatal("Expected SOAP envelope to be detected")
+	}
+
+	if info.Version != "1.2" {
+		t.Errorf("Expected version 1.2, got %s", info.Version)
+	}
+	if info.Operation != "CreateUser" {
+		t.Errorf("Expected operation CreateUser, got %s", info.Operation)
+	}
+}
+
+func TestParseSOAPEnvelope_NotSOAP(t *testing.T) {
+	tests := []string{
+		`<root><child/></root>`,
+		`<Envelope xmlns="urn:other"><Body/></Envelope>`,
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`,
+		`not xml`,
+	}
+
+	for _, input := range tests {
+		if info := ParseSOAPEnvelope([]byte(input)); info != nil {
+			t.Errorf("Expected nil for %q, got %+v", input, info)
+		}
+	}
+}
+
+func TestBuildSOAPResponse_Echo(t *testing.T) {
+	info := ParseSOAPEnvelope([]byte(soap11Request))
+	info.Action = "urn:orders/GetOrder"
+
+	out := BuildSOAPResponse(info, nil)
+
+	var envelope struct {
+		XMLName xml.Name
+		Body    struct {
+			Response struct {
+				XMLName      xml.Name
+				SOAPAction   string   `xml:"soapAction"`
+				Operation    string   `xml:"operation"`
+				HeaderBlocks []string `xml:"headerBlocks>headerBlock>name"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(out, &envelope); err != nil {
+		t.Fatalf("Expected well-formed XML, got error %v:\n%s", err, out)
+	}
+
+	if envelope.XMLName.Space != SOAP11Namespace || envelope.XMLName.Local != "Envelope" {
+		t.Errorf("Expected SOAP 1.1 envelope, got %v", envelope.XMLName)
+	}
+	resp := envelope.Body.Response
+	if resp.XMLName.Local != "GetOrderResponse" || resp.XMLName.Space != "urn:orders" {
+		t.Errorf("Expected {urn:orders}GetOrderResponse, got %v", resp.XMLName)
+	}
+	if resp.SOAPAction != "urn:orders/GetOrder" {
+		t.Errorf("Expected soapAction echoed, got %q", resp.SOAPAction)
+	}
+	if resp.Operation != "GetOrder" {
+		t.Errorf("Expected operation echoed, got %q", resp.Operation)
+	}
+	if len(resp.HeaderBlocks) != 2 || resp.HeaderBlocks[0] != "Token" {
+		t.Errorf("Expected header blocks echoed, got %v", resp.HeaderBlocks)
+	}
+}
+
+func TestBuildSOAPResponse_EscapesValues(t *testing.T) {
+	info := &models.SOAPInfo{Version: "1.1", Operation: "Op", Action: `"a<b>&c"`}
+
+	out := BuildSOAPResponse(info, nil)
+	if err := xml.Unmarshal(out, new(interface{})); err != nil {
+		t.Fatalf("Expected well-formed XML, got error %v:\n%s", err, out)
+	}
+	if strings.Contains(string(out), "a<b>") {
+		t.Error("Expected action to be escaped")
+	}
+}
+
+func TestBuildSOAPResponse_Fault11(t *testing.T) {
+	info := &models.SOAPInfo{Version: "1.1", Operation: "GetOrder"}
+
+	out := string(BuildSOAPResponse(info, &SOAPFault{Code: "Client.Authentication", String: "bad token"}))
+
+	if !strings.Contains(out, "<faultcode>soap:Client.Authentication</faultcode>") {
+		t.Errorf("Expected SOAP 1.1 faultcode, got:\n%s", out)
+	}
+	if !strings.Contains(out, "<faultstring>bad token</faultstring>") {
+		t.Errorf("Expected faultstring, got:\n%s", out)
+	}
+}
+
+func TestBuildSOAPResponse_Fault12(t *testing.T) {
+	info := &models.SOAPInfo{Version: "1.2", Operation: "CreateUser"}
+
+	out := string(BuildSOAPResponse(info, &SOAPFault{Code: "Client"}))
+	if !strings.Contains(out, "<soap:Value>soap:Sender</soap:Value>") {
+		t.Errorf("Expected Client to map to SOAP 1.2 Sender, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Simulated fault") {
+		t.Errorf("Expected default reason, got:\n%s", out)
+	}
+
+	out = string(BuildSOAPResponse(info, &SOAPFault{Code: "app:QuotaExceeded"}))
+	if !strings.Contains(out, "<soap:Subcode><soap:Value>QuotaExceeded</soap:Value></soap:Subcode>") {
+		t.Errorf("Expected custom code as Receiver subcode, got:\n%s", out)
+	}
+}
+
+func TestSOAPFaultStatus(t *testing.T) {
+	tests := []struct {
+		version  string
+		code     string
+		expected int
+	}{
+		{"1.1", "Client", 500},
+		{"1.1", "Server", 500},
+		{"1.2", "Sender", 400},
+		{"1.2", "soap:Client", 400},
+		{"1.2", "Receiver", 500},
+		{"1.2", "MustUnderstand", 500},
+	}
+
+	for _, tt := range tests {
+		if got := SOAPFaultStatus(tt.version, tt.code); got != tt.expected {
+			t.Errorf("SOAPFaultStatus(%s, %s) = %d, expected %d", tt.version, tt.code, got, tt.expected)
+		}
+	}
+}
+
+func TestSOAPContentType(t *testing.T) {
+	if ct := SOAPContentType("1.1"); ct != "text/xml; charset=utf-8" {
+		t.Errorf("Unexpected SOAP 1.1 content type %s", ct)
+	}
+	if ct := SOAPContentType("1.2"); ct != "application/soap+xml; charset=utf-8" {
+		t.Errorf("Unexpected SOAP 1.2 content type %s", ct)
+	}
+}
+
+func TestBodyService_SOAPDetection(t *testing.T) {
+	service := NewBodyService()
+
+	bodyInfo := service.ParseBody([]byte(soap12Request), `application/soap+xml; charset=utf-8; action="urn:users/CreateUser"`)
+	if bodyInfo.SOAP == nil {
+		t.Fatal("Expected SOAP info for application/soap+xml body")
+	}
+	if bodyInfo.SOAP.Action != "urn:users/CreateUser" {
+		t.Errorf("Expected action from Content-Type, got %q", bodyInfo.SOAP.Action)
+	}
+
+	bodyInfo = service.ParseBody([]byte(`<root/>`), "application/xml")
+	if bodyInfo.SOAP != nil {
+		t.Error("Expected no SOAP info for plain XML")
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+
+	proxyproto "github.com/pires/go-proxyproto"
+	"github.com/ullbergm/echo-server/models"
+)
+
+// DefaultUnixSocketMode is the default file mode applied to Unix domain sockets
+const DefaultUnixSocketMode os.FileMode = 0o660
+
+// ListenUnix creates a Unix domain socket listener at path and applies the given file mode.
+// A stale socket file left behind by a previous run is removed before listening.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("refusing to replace non-socket file %s", path)
+		}
+		if removeErr := os.Remove(path); removeErr != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, removeErr)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat socket path %s: %w", path, err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %s: %w", path, err)
+	}
+
+	if err = os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("failed to set mode %o on socket %s: %w", mode, path, err)
+	}
+
+	return ln, nil
+}
+
+// GetConnectionInfo describes the transport a connection was accepted on.
+// Peer credentials are included for Unix domain sockets on platforms that support them,
+// and the listener and PROXY protocol header for connections accepted via OpenListener.
+func GetConnectionInfo(conn net.Conn) *models.ConnectionInfo {
+	if conn == nil || conn.LocalAddr() == nil {
+		return nil
+	}
+
+	info := &models.ConnectionInfo{}
+
+	// Peel off the listener, TLS and PROXY protocol layers down to the socket
+	raw := conn
+	for unwrapped := true; unwrapped; {
+		switch c := raw.(type) {
+		case labeledConnection:
+			info.Listener = c.Listener()
+			raw = c.NetConn()
+		case *tls.Conn:
+			raw = c.NetConn()
+		case *proxyproto.Conn:
+			info.ProxyProtocol = getProxyProtocolInfo(c)
+			raw = c.Raw()
+		default:
+			unwrapped = false
+		}
+	}
+
+	info.Network = raw.LocalAddr().Network()
+	info.LocalAddress = raw.LocalAddr().String()
+
+	if unixConn, ok := raw.(*net.UnixConn); ok {
+		info.Network = "unix"
+		info.PeerCredentials = peerCredentials(unixConn)
+	}
+
+	return info
+}
+
+// labeledConnection is a connection accepted on a listener opened by OpenListener
+type labeledConnection interface {
+	Listener() *models.ListenerInfo
+	NetConn() net.Conn
+}
+
+// getProxyProtocolInfo describes the PROXY protocol header received on a connection, if any
+func getProxyProtocolInfo(conn *proxyproto.Conn) *models.ProxyProtocolInfo {
+	header := conn.ProxyHeader()
+	if header == nil {
+		return nil
+	}
+
+	info := &models.ProxyProtocolInfo{
+		Version:          int(header.Version),
+		Command:          "PROXY",
+		TransportAddress: conn.Raw().RemoteAddr().String(),
+	}
+	if header.Command.IsLocal() {
+		info.Command = "LOCAL"
+	}
+	if header.SourceAddr != nil {
+		info.SourceAddress = header.SourceAddr.String()
+	}
+	if header.DestinationAddr != nil {
+		info.DestinationAddress = header.DestinationAddr.String()
+	}
+
+	return info
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListenUnix_CreatesSocketWithMode(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "echo.sock")
+
+	ln, err := ListenUnix(socketPath, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	defer ln.Close()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		t.Error("Expected path to be a socket")
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestListenUnix_RemovesStaleSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "echo.sock")
+
+	// Leave a socket file behind without unlinking it
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	if unixLn, ok := stale.(*net.UnixListener); ok {
+		unixLn.SetUnlinkOnClose(false)
+	}
+	stale.Close()
+
+	ln, err := ListenUnix(socketPath, DefaultUnixSocketMode)
+	if err != nil {
+		t.Fatalf("Expected stale socket to be replaced, got error: %v", err)
+	}
+	ln.Close()
+}
+
+func TestListenUnix_RefusesRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := ListenUnix(path, DefaultUnixSocketMode); err == nil {
+		t.Error("Expected error when path is a regular file")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Error("Expected regular file to be left in place")
+	}
+}
+
+func TestGetConnectionInfo_UnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "echo.sock")
+
+	ln, err := ListenUnix(socketPath, DefaultUnixSocketMode)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, acceptErr := ln.Accept()
+		if acceptErr != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to dial unix socket: %v", err)
+	}
+	defer client.Close()
+
+	server := <-accepted
+	if server == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer server.Close()
+
+	info := GetConnectionInfo(server)
+	if info == nil {
+		t.Fatal("Expected connection info")
+	}
+
+	if info.Network != "unix" {
+		t.Errorf("Expected network unix, got %s", info.Network)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	if info.PeerCredentials == nil {
+		t.Fatal("Expected peer credentials on Linux")
+	}
+
+	if int(info.PeerCredentials.PID) != os.Getpid() {
+		t.Errorf("Expected peer pid %d, got %d", os.Getpid(), info.PeerCredentials.PID)
+	}
+
+	if int(info.PeerCredentials.UID) != os.Getuid() {
+		t.Errorf("Expected peer uid %d, got %d", os.Getuid(), info.PeerCredentials.UID)
+	}
+}
+
+func TestGetConnectionInfo_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, dialErr := net.Dial("tcp", ln.Addr().String())
+		if dialErr == nil {
+			defer conn.Close()
+		}
+	}()
+
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer server.Close()
+
+	info := GetConnectionInfo(server)
+	if info == nil {
+		t.Fatal("Expected connection info")
+	}
+
+	if info.Network != "tcp" {
+		t.Errorf("Expected network tcp, got %s", info.Network)
+	}
+
+	if info.PeerCredentials != nil {
+		t.Error("Expected no peer credentials for TCP connection")
+	}
+}
+
+func TestGetConnectionInfo_Nil(t *testing.T) {
+	if info := GetConnectionInfo(nil); info != nil {
+		t.Errorf("Expected nil for nil connection, got %+v", info)
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"io"
+	"time"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+// previewBuffer keeps the first bytes written to it and discards the rest
+type previewBuffer struct {
+	data  []byte
+	limit int
+}
+
+// Write keeps as much of p as fits under the limit, always reporting all of it as written
+func (b *previewBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.data); room > 0 {
+		b.data = append(b.data, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// ReadBodyStream reads a streamed request body without buffering all of it. The whole body is written
+// to the hasher and measured, while only the first MAX_BODY_SIZE bytes are kept as a preview for parsing.
+func (s *BodyService) ReadBodyStream(stream io.Reader, hasher io.Writer) ([]byte, *models.StreamInfo) {
+	preview := &previewBuffer{limit: s.maxBodySize}
+
+	start := time.Now()
+	bytesRead, err := io.Copy(io.MultiWriter(hasher, preview), stream)
+	elapsed := time.Since(start)
+
+	info := &models.StreamInfo{
+		BytesRead:   bytesRead,
+		PreviewSize: len(preview.data),
+		DurationMs:  float64(elapsed.Microseconds()) / 1000,
+	}
+	if elapsed > 0 {
+		info.ThroughputBytesPerSec = float64(bytesRead) / elapsed.Seconds()
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	return preview.data, info
+}
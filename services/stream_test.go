@@ -0,0 +1,78 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+func TestBodyService_ReadBodyStream(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "16")
+	service := NewBodyService()
+
+	body := bytes.Repeat([]byte("0123456789"), 1000)
+	hasher := NewBodyHasher(nil)
+
+	// One byte at a time exercises the preview filling up across writes
+	preview, info := service.ReadBodyStream(iotest.OneByteReader(bytes.NewReader(body)), hasher)
+
+	if string(preview) != "0123456789012345" {
+		t.Errorf("Expected a 16 byte preview, got %q", preview)
+	}
+	if info.BytesRead != int64(len(body)) || info.PreviewSize != 16 || info.Error != "" {
+		t.Errorf("Unexpected stream info %+v", info)
+	}
+	if info.DurationMs < 0 || info.ThroughputBytesPerSec < 0 {
+		t.Errorf("Expected non-negative timings, got %+v", info)
+	}
+
+	bodyInfo := &models.BodyInfo{}
+	hasher.Finish(bodyInfo)
+	wholeBody := &models.BodyInfo{}
+	checkIntegrity(wholeBody, body, nil)
+	if *bodyInfo.Digests != *wholeBody.Digests {
+		t.Errorf("Expected digests of the whole stream, got %+v", bodyInfo.Digests)
+	}
+}
+
+func TestBodyService_ReadBodyStreamError(t *testing.T) {
+	service := NewBodyService()
+
+	stream := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	preview, info := service.ReadBodyStream(stream, io.Discard)
+
+	if string(preview) != "partial" || info.BytesRead != 7 {
+		t.Errorf("Expected the bytes read before the error, got %q (%+v)", preview, info)
+	}
+	if info.Error != "connection reset" {
+		t.Errorf("Expected read error to be reported, got %q", info.Error)
+	}
+}
+
+func TestBodyHasher_StreamedChecks(t *testing.T) {
+	hasher := NewBodyHasher(map[string]string{
+		"Content-Digest":           "sha-512=:" + "AAAA" + ":",
+		"X-Amz-Checksum-Crc64nvme": "jSnVw/bqjr4=",
+	})
+	for _, chunk := range []string{"hello", " ", "world"} {
+		_, _ = hasher.Write([]byte(chunk))
+	}
+
+	bodyInfo := &models.BodyInfo{}
+	hasher.Finish(bodyInfo)
+
+	if len(bodyInfo.Integrity) != 2 {
+		t.Fatalf("Expected 2 checks, got %+v", bodyInfo.Integrity)
+	}
+	if bodyInfo.Integrity[0].Match || bodyInfo.Integrity[0].Actual == "" {
+		t.Errorf("Expected sha-512 mismatch with computed digest, got %+v", bodyInfo.Integrity[0])
+	}
+	if !bodyInfo.Integrity[1].Match {
+		t.Errorf("Expected crc64nvme of chunked body to match, got %+v", bodyInfo.Integrity[1])
+	}
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// DefaultTeeTimeout bounds a forwarded request including reading the upstream response
+	DefaultTeeTimeout = 30 * time.Second
+)
+
+// TeeRequest is an incoming request to be forwarded to the tee upstream
+type TeeRequest struct {
+	Headers http.Header
+	// BodyStream, when set, is forwarded instead of Body as it is read. StreamLength is its length,
+	// or -1 when it is not known in advance.
+	BodyStream    io.Reader
+	Method        string
+	RequestURI    string
+	Host          string
+	Scheme        string
+	RemoteAddress string
+	Body          []byte
+	StreamLength  int
+}
+
+// TeeService forwards requests to an upstream and records both sides of the exchange
+type TeeService struct {
+	upstream    *url.URL
+	client      *http.Client
+	maxBodySize int
+}
+
+// NewTeeService creates a new tee service configured from the environment.
+// Tee mode is enabled when TEE_UPSTREAM_URL is set to a valid http(s) URL.
+// Upstream response bodies are read up to MAX_BODY_SIZE.
+func NewTeeService() *TeeService {
+	service := &TeeService{maxBodySize: maxBodySizeFromEnv()}
+
+	upstreamEnv := os.Getenv("TEE_UPSTREAM_URL")
+	if upstreamEnv == "" {
+		return service
+	}
+
+	upstream, parseErr := url.Parse(upstreamEnv)
+	if parseErr != nil || (upstream.Scheme != "http" && upstream.Scheme != "https") || upstream.Host == "" {
+		return service
+	}
+
+	timeout := DefaultTeeTimeout
+	if timeoutEnv := os.Getenv("TEE_UPSTREAM_TIMEOUT_SECONDS"); timeoutEnv != "" {
+		if parsed, err := strconv.Atoi(timeoutEnv); err == nil && parsed > 0 {
+			timeout = time.Duration(parsed) * time.Second
+		}
+	}
+
+	insecure := false
+	if insecureEnv := os.Getenv("TEE_UPSTREAM_INSECURE_SKIP_VERIFY"); insecureEnv != "" {
+		if parsed, err := strconv.ParseBool(insecureEnv); err == nil {
+			insecure = parsed
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Forward exactly what the client asked for instead of negotiating compression ourselves
+	transport.DisableCompression = true
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure, // #nosec G402 -- opt-in for self-signed upstreams
+	}
+
+	service.upstream = upstream
+	service.client = &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		// Redirects are part of what crosses the wire, so hand them back unfollowed
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return service
+}
+
+// Enabled reports whether tee mode is enabled
+func (s *TeeService) Enabled() bool {
+	return s.upstream != nil
+}
+
+// Upstream returns the configured upstream URL
+func (s *TeeService) Upstream() string {
+	if s.upstream == nil {
+		return ""
+	}
+	return s.upstream.String()
+}
+
+// Forward sends the request to the upstream and returns the exchange along with the raw upstream body
+func (s *TeeService) Forward(ctx context.Context, in TeeRequest) (*models.TeeInfo, []byte) {
+	tee := &models.TeeInfo{
+		Upstream: s.upstream.String(),
+		Timings:  &models.TeeTimings{},
+	}
+
+	var reqBody io.Reader = bytes.NewReader(in.Body)
+	bodySize := int64(len(in.Body))
+	var streamed *countingReader
+	if in.BodyStream != nil {
+		streamed = &countingReader{reader: in.BodyStream}
+		reqBody = streamed
+		bodySize = int64(in.StreamLength)
+		if bodySize == 0 {
+			// A request with a body of length zero would be sent chunked
+			reqBody = http.NoBody
+		}
+	}
+
+	target := s.targetURL(in.RequestURI)
+	req, err := http.NewRequestWithContext(ctx, in.Method, target, reqBody)
+	if err != nil {
+		tee.Error = fmt.Sprintf("failed to build upstream request: %v", err)
+		return tee, nil
+	}
+
+	for name, values := range in.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	for _, header := range hopByHopHeaders {
+		req.Header.Del(header)
+	}
+	req.Header.Del("Host")
+	req.Header.Del("Content-Length")
+	appendForwardedHeaders(req.Header, in)
+	req.ContentLength = bodySize
+
+	tee.Forwarded = &models.TeeForwardedRequest{
+		Method:   req.Method,
+		URL:      target,
+		Host:     req.URL.Host,
+		Headers:  flattenHeaders(req.Header),
+		BodySize: len(in.Body),
+	}
+
+	// Record connection phases of the forwarded request. Trace hooks may fire from
+	// transport goroutines, so all timing updates go through the mutex.
+	var mu sync.Mutex
+	timings := models.TeeTimings{}
+	record := func(update func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		update()
+	}
+
+	var start, dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { record(func() { dnsStart = time.Now() }) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { timings.DNSMs = elapsedMs(dnsStart) })
+		},
+		ConnectStart: func(string, string) { record(func() { connectStart = time.Now() }) },
+		ConnectDone: func(string, string, error) {
+			record(func() { timings.ConnectMs = elapsedMs(connectStart) })
+		},
+		TLSHandshakeStart: func() { record(func() { tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { timings.TLSHandshakeMs = elapsedMs(tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() { timings.ReusedConnection = info.Reused })
+		},
+		GotFirstResponseByte: func() {
+			record(func() { timings.TimeToFirstByteMs = elapsedMs(start) })
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	record(func() { start = time.Now() })
+	resp, err := s.client.Do(req)
+	if streamed != nil {
+		// The size of a streamed body is known once it has been sent
+		tee.Forwarded.BodySize = int(streamed.count.Load())
+	}
+	if err != nil {
+		record(func() {
+			timings.TotalMs = elapsedMs(start)
+			*tee.Timings = timings
+		})
+		tee.Error = fmt.Sprintf("upstream request failed: %v", err)
+		return tee, nil
+	}
+	defer resp.Body.Close()
+
+	// One byte past the limit tells a body cut off at the limit from one that fits exactly
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(s.maxBodySize)+1))
+	truncated := len(body) > s.maxBodySize
+	if truncated {
+		body = body[:s.maxBodySize]
+	}
+	record(func() {
+		timings.TotalMs = elapsedMs(start)
+		*tee.Timings = timings
+	})
+
+	tee.Response = &models.TeeUpstreamResponse{
+		Status:     resp.StatusCode,
+		StatusText: http.StatusText(resp.StatusCode),
+		Protocol:   resp.Proto,
+		Headers:    flattenHeaders(resp.Header),
+		Truncated:  truncated,
+	}
+	if err != nil {
+		tee.Error = fmt.Sprintf("failed to read upstream response: %v", err)
+	}
+
+	return tee, body
+}
+
+// targetURL joins the upstream base URL with the path and query of the incoming request
+func (s *TeeService) targetURL(requestURI string) string {
+	path, query, _ := strings.Cut(requestURI, "?")
+
+	target := *s.upstream
+	target.RawPath = strings.TrimSuffix(s.upstream.EscapedPath(), "/") + path
+	if unescaped, err := url.PathUnescape(target.RawPath); err == nil {
+		target.Path = unescaped
+	} else {
+		target.Path = target.RawPath
+	}
+	if query != "" {
+		target.RawQuery = query
+	}
+	return target.String()
+}
+
+// appendForwardedHeaders adds the de-facto X-Forwarded-* headers describing the original request
+func appendForwardedHeaders(header http.Header, in TeeRequest) {
+	if in.RemoteAddress != "" {
+		if prior := header.Get("X-Forwarded-For"); prior != "" {
+			header.Set("X-Forwarded-For", prior+", "+in.RemoteAddress)
+		} else {
+			header.Set("X-Forwarded-For", in.RemoteAddress)
+		}
+	}
+	if in.Host != "" {
+		header.Set("X-Forwarded-Host", in.Host)
+	}
+	if in.Scheme != "" {
+		header.Set("X-Forwarded-Proto", in.Scheme)
+	}
+}
+
+// flattenHeaders joins repeated header values the way they would be folded on the wire
+func flattenHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+func elapsedMs(since time.Time) float64 {
+	return float64(time.Since(since).Microseconds()) / 1000
+}
+
+// countingReader counts the bytes read through it. The count may be read while the transport is still reading.
+type countingReader struct {
+	reader io.Reader
+	count  atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewTeeService(t *testing.T) {
+	tests := []struct {
+		upstream string
+		enabled  bool
+	}{
+		{"", false},
+		{"http://localhost:9000", true},
+		{"https://backend.internal/api", true},
+		{"ftp://backend.internal", false},
+		{"localhost:9000", false},
+		{"://bad", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("TEE_UPSTREAM_URL", tt.upstream)
+		if got := NewTeeService().Enabled(); got != tt.enabled {
+			t.Errorf("TEE_UPSTREAM_URL=%q: Enabled() = %v, expected %v", tt.upstream, got, tt.enabled)
+		}
+	}
+}
+
+func TestTeeService_TargetURL(t *testing.T) {
+	tests := []struct {
+		upstream   string
+		requestURI string
+		expected   string
+	}{
+		{"http://backend:9000", "/users/1?expand=true", "http://backend:9000/users/1?expand=true"},
+		{"http://backend:9000/", "/users", "http://backend:9000/users"},
+		{"http://backend:9000/api/", "/users", "http://backend:9000/api/users"},
+		{"http://backend:9000", "/a%2Fb", "http://backend:9000/a%2Fb"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("TEE_UPSTREAM_URL", tt.upstream)
+		if got := NewTeeService().targetURL(tt.requestURI); got != tt.expected {
+			t.Errorf("targetURL(%s) with upstream %s = %s, expected %s", tt.requestURI, tt.upstream, got, tt.expected)
+		}
+	}
+}
+
+func TestTeeService_Forward(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Upstream", "a")
+		w.Header().Add("X-Upstream", "b")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	t.Setenv("TEE_UPSTREAM_URL", upstream.URL+"/base")
+	service := NewTeeService()
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Connection", "keep-alive")
+	headers.Set("Proxy-Authorization", "Basic abc")
+	headers.Set("X-Request-Id", "42")
+	headers.Set("Host", "echo.example.com")
+
+	tee, body := service.Forward(context.Background(), TeeRequest{
+		Method:        http.MethodPost,
+		RequestURI:    "/orders?id=7",
+		Headers:       headers,
+		Body:          []byte(`{"item":"x"}`),
+		Host:          "echo.example.com",
+		Scheme:        "http",
+		RemoteAddress: "10.0.0.1",
+	})
+
+	if tee.Error != "" {
+		t.Fatalf("Unexpected error: %s", tee.Error)
+	}
+
+	// What the upstream actually received
+	if received.Method != http.MethodPost || received.URL.RequestURI() != "/base/orders?id=7" {
+		t.Errorf("Unexpected upstream request line: %s %s", received.Method, received.URL.RequestURI())
+	}
+	if string(receivedBody) != `{"item":"x"}` {
+		t.Errorf("Expected body to be forwarded, got %s", receivedBody)
+	}
+	if received.Header.Get("X-Request-Id") != "42" {
+		t.Error("Expected end-to-end headers to be forwarded")
+	}
+	if received.Header.Get("Proxy-Authorization") != "" {
+		t.Error("Expected hop-by-hop headers to be stripped")
+	}
+	if received.Header.Get("X-Forwarded-For") != "10.0.0.1" || received.Header.Get("X-Forwarded-Host") != "echo.example.com" {
+		t.Errorf("Expected X-Forwarded headers, got %v", received.Header)
+	}
+
+	// What the envelope reports
+	if tee.Forwarded.URL != upstream.URL+"/base/orders?id=7" || tee.Forwarded.BodySize != 12 {
+		t.Errorf("Unexpected forwarded request: %+v", tee.Forwarded)
+	}
+	if tee.Forwarded.Headers["X-Forwarded-Proto"] != "http" {
+		t.Errorf("Expected forwarded headers to be reported, got %v", tee.Forwarded.Headers)
+	}
+	if tee.Response.Status != http.StatusCreated || tee.Response.StatusText != "Created" {
+		t.Errorf("Unexpected upstream status: %d %s", tee.Response.Status, tee.Response.StatusText)
+	}
+	if tee.Response.Headers["X-Upstream"] != "a, b" {
+		t.Errorf("Expected repeated upstream headers to be joined, got %q", tee.Response.Headers["X-Upstream"])
+	}
+	if string(body) != `{"ok":true}` {
+		t.Errorf("Unexpected upstream body: %s", body)
+	}
+	if tee.Timings.TotalMs <= 0 || tee.Timings.TimeToFirstByteMs <= 0 {
+		t.Errorf("Expected timings to be recorded, got %+v", tee.Timings)
+	}
+}
+
+func TestTeeService_DoesNotFollowRedirects(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer upstream.Close()
+
+	t.Setenv("TEE_UPSTREAM_URL", upstream.URL)
+	tee, _ := NewTeeService().Forward(context.Background(), TeeRequest{Method: http.MethodGet, RequestURI: "/"})
+
+	if tee.Response == nil || tee.Response.Status != http.StatusFound {
+		t.Fatalf("Expected redirect to be returned as is, got %+v", tee.Response)
+	}
+	if tee.Response.Headers["Location"] != "/elsewhere" {
+		t.Errorf("Expected Location header, got %v", tee.Response.Headers)
+	}
+}
+
+func TestTeeService_UpstreamUnavailable(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstreamURL := upstream.URL
+	upstream.Close()
+
+	t.Setenv("TEE_UPSTREAM_URL", upstreamURL)
+	tee, body := NewTeeService().Forward(context.Background(), TeeRequest{Method: http.MethodGet, RequestURI: "/"})
+
+	if tee.Error == "" {
+		t.Error("Expected error for unavailable upstream")
+	}
+	if tee.Response != nil || body != nil {
+		t.Error("Expected no upstream response")
+	}
+	if tee.Forwarded == nil {
+		t.Error("Expected forwarded request to be reported even when the upstream fails")
+	}
+}
+
+func TestTeeService_ResponseBodyLimit(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer upstream.Close()
+
+	t.Setenv("TEE_UPSTREAM_URL", upstream.URL)
+	t.Setenv("MAX_BODY_SIZE", "1024")
+	tee, body := NewTeeService().Forward(context.Background(), TeeRequest{Method: http.MethodGet, RequestURI: "/"})
+
+	if tee.Response == nil || !tee.Response.Truncated {
+		t.Fatalf("Expected truncated upstream response, got %+v", tee.Response)
+	}
+	if len(body) != 1024 {
+		t.Errorf("Expected body to be cut at 1024 bytes, got %d", len(body))
+	}
+
+	t.Setenv("MAX_BODY_SIZE", "2048")
+	tee, body = NewTeeService().Forward(context.Background(), TeeRequest{Method: http.MethodGet, RequestURI: "/"})
+	if tee.Response == nil || tee.Response.Truncated || len(body) != 2048 {
+		t.Errorf("Expected a body of exactly the limit to be kept whole, got %d bytes", len(body))
+	}
+}
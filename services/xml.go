@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ullbergm/echo-server/models"
+)
+
+const (
+	// maxXMLDepth is the deepest element nesting accepted when parsing an XML body
+	maxXMLDepth = 100
+
+	// maxXMLElements is the largest number of elements accepted when parsing an XML body
+	maxXMLElements = 10000
+)
+
+// ParseXMLTree parses an XML document into a generic tree of elements.
+// Documents declaring entities, nesting deeper than maxXMLDepth or holding more than
+// maxXMLElements elements are rejected, as is anything that is not a single well-formed root element.
+func ParseXMLTree(data []byte) (*models.XMLElement, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	var root *models.XMLElement
+	var stack []*models.XMLElement
+	var text []*strings.Builder
+	elements := 0
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && root != nil {
+				return nil, errors.New("xml: multiple root elements")
+			}
+			if len(stack) >= maxXMLDepth {
+				return nil, fmt.Errorf("xml: nesting exceeds %d levels", maxXMLDepth)
+			}
+			elements++
+			if elements > maxXMLElements {
+				return nil, fmt.Errorf("xml: more than %d elements", maxXMLElements)
+			}
+
+			element := newXMLElement(t)
+			if len(stack) == 0 {
+				root = element
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, element)
+			}
+			stack = append(stack, element)
+			text = append(text, &strings.Builder{})
+		case xml.EndElement:
+			// The decoder guarantees end elements match their start element
+			last := len(stack) - 1
+			stack[last].Text = strings.TrimSpace(text[last].String())
+			stack = stack[:last]
+			text = text[:last]
+		case xml.CharData:
+			if len(stack) == 0 {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, errors.New("xml: text outside the root element")
+				}
+				continue
+			}
+			text[len(text)-1].Write(t)
+		case xml.Directive:
+			// Entity declarations are refused outright to rule out expansion attacks
+			if bytes.Contains(t, []byte("ENTITY")) {
+				return nil, errors.New("xml: entity declarations are not allowed")
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("xml: no root element")
+	}
+	return root, nil
+}
+
+// newXMLElement creates a tree element from a start element, separating namespace declarations from attributes
+func newXMLElement(start xml.StartElement) *models.XMLElement {
+	element := &models.XMLElement{
+		Name:      start.Name.Local,
+		Namespace: start.Name.Space,
+	}
+
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			element.Namespaces = append(element.Namespaces, models.XMLNamespace{URI: attr.Value})
+		case attr.Name.Space == "xmlns":
+			element.Namespaces = append(element.Namespaces, models.XMLNamespace{Prefix: attr.Name.Local, URI: attr.Value})
+		default:
+			element.Attributes = append(element.Attributes, models.XMLAttribute{
+				Name:      attr.Name.Local,
+				Namespace: attr.Name.Space,
+				Value:     attr.Value,
+			})
+		}
+	}
+
+	return element
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseXMLTree(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<!-- order export -->
+<ord:Order xmlns:ord="urn:orders" xmlns="urn:default" id="7" ord:priority="high">
+  <Customer>Ada</Customer>
+  <Items>
+    <Item sku="A1">2</Item>
+    <Item sku="B2"><![CDATA[1 & more]]></Item>
+  </Items>
+  note
+</ord:Order>`
+
+	root, err := ParseXMLTree([]byte(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if root.Name != "Order" || root.Namespace != "urn:orders" {
+		t.Errorf("Unexpected root name %s in %s", root.Name, root.Namespace)
+	}
+	if root.Text != "note" {
+		t.Errorf("Expected root text note, got %q", root.Text)
+	}
+	if len(root.Namespaces) != 2 || root.Namespaces[0].Prefix != "ord" || root.Namespaces[1].Prefix != "" || root.Namespaces[1].URI != "urn:default" {
+		t.Errorf("Unexpected namespace declarations: %+v", root.Namespaces)
+	}
+	if len(root.Attributes) != 2 || root.Attributes[0].Name != "id" || root.Attributes[1].Namespace != "urn:orders" {
+		t.Errorf("Unexpected attributes: %+v", root.Attributes)
+	}
+
+	if len(root.Children) != 2 {
+		t.Fatalf("Expected 2 children, got %d", len(root.Children))
+	}
+	customer := root.Children[0]
+	if customer.Name != "Customer" || customer.Namespace != "urn:default" || customer.Text != "Ada" {
+		t.Errorf("Unexpected customer element: %+v", customer)
+	}
+	items := root.Children[1].Children
+	if len(items) != 2 || items[0].Attributes[0].Value != "A1" || items[1].Text != "1 & more" {
+		t.Errorf("Unexpected items: %+v", items)
+	}
+	if root.Children[1].Text != "" {
+		t.Errorf("Expected whitespace-only text to be dropped, got %q", root.Children[1].Text)
+	}
+}
+
+func TestParseXMLTree_Rejected(t *testing.T) {
+	tests := map[string]string{
+		"empty":            ``,
+		"not xml":          `not xml at all`,
+		"unclosed":         `<root><unclosed>`,
+		"mismatched":       `<root>mismatched</other>`,
+		"multiple roots":   `<a/><b/>`,
+		"trailing text":    `<?xml version="1.0"?><a/>trailing`,
+		"entity":           `<!DOCTYPE r [<!ENTITY a "aaaa">]><r>&a;</r>`,
+		"external entity":  `<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><root>&xxe;</root>`,
+		"undefined entity": `<root>&undefined;</root>`,
+		"too deep":         strings.Repeat("<a>", maxXMLDepth+1) + strings.Repeat("</a>", maxXMLDepth+1),
+		"too many":         "<r>" + strings.Repeat("<a/>", maxXMLElements) + "</r>",
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if root, err := ParseXMLTree([]byte(doc)); err == nil {
+				t.Errorf("Expected error, got %+v", root)
+			}
+		})
+	}
+}
+
+func TestParseXMLTree_Limits(t *testing.T) {
+	deep := strings.Repeat("<a>", maxXMLDepth) + "x" + strings.Repeat("</a>", maxXMLDepth)
+	if _, err := ParseXMLTree([]byte(deep)); err != nil {
+		t.Errorf("Expected nesting of %d levels to be accepted: %v", maxXMLDepth, err)
+	}
+
+	wide := "<r>" + strings.Repeat("<a/>", maxXMLElements-1) + "</r>"
+	if _, err := ParseXMLTree([]byte(wide)); err != nil {
+		t.Errorf("Expected %d elements to be accepted: %v", maxXMLElements, err)
+	}
+}
+
+func TestBodyService_XMLFallsBackToString(t *testing.T) {
+	doc := `<!DOCTYPE r [<!ENTITY a "aaaa">]><r>&a;</r>`
+	bodyInfo := NewBodyService().ParseBody([]byte(doc), "application/xml")
+	if bodyInfo.Content != doc {
+		t.Errorf("Expected rejected XML to be returned as a string, got %v", bodyInfo.Content)
+	}
+}